@@ -59,6 +59,11 @@ type Battery interface {
 	SoC() (float64, error)
 }
 
+// BatteryCapacity provides the battery capacity in kWh
+type BatteryCapacity interface {
+	Capacity() float64
+}
+
 // ChargeState provides current charging status
 type ChargeState interface {
 	Status() (ChargeStatus, error)
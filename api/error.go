@@ -8,6 +8,9 @@ var ErrNotAvailable = errors.New("not available")
 // ErrMustRetry indicates that a rate-limited operation should be retried
 var ErrMustRetry = errors.New("must retry")
 
+// ErrOutdated indicates that a value has not been updated within its timeout
+var ErrOutdated = errors.New("outdated")
+
 // ErrTimeout is the error returned when a timeout happened.
 // Modeled after context.DeadlineError
 var ErrTimeout error = errTimeoutError{}
@@ -23,7 +23,10 @@ func init() {
 
 // NewConfigurableFromConfig creates a new configurable charger
 func NewConfigurableFromConfig(other map[string]interface{}) (api.Charger, error) {
-	cc := struct{ Status, Enable, Enabled, MaxCurrent provider.Config }{}
+	cc := struct {
+		Status, Enable, Enabled, MaxCurrent provider.Config
+		MaxCurrentMillis                    *provider.Config
+	}{}
 	if err := util.DecodeOther(other, &cc); err != nil {
 		return nil, err
 	}
@@ -59,16 +62,32 @@ func NewConfigurableFromConfig(other map[string]interface{}) (api.Charger, error
 		return nil, fmt.Errorf("maxcurrent: %w", err)
 	}
 
-	return NewConfigurable(status, enabled, enable, maxcurrent)
+	c, err := NewConfigurable(status, enabled, enable, maxcurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	// decorate charger with milliamp current
+	var maxcurrentmillis func(float64) error
+	if cc.MaxCurrentMillis != nil {
+		maxcurrentmillis, err = provider.NewFloatSetterFromConfig("maxcurrentmillis", *cc.MaxCurrentMillis)
+		if err != nil {
+			return nil, fmt.Errorf("maxcurrentmillis: %w", err)
+		}
+	}
+
+	return decorateCustom(c, maxcurrentmillis), nil
 }
 
+//go:generate go run ../cmd/tools/decorate.go -f decorateCustom -b *Charger -r api.Charger -t "api.ChargerEx,MaxCurrentMillis,func(current float64) error"
+
 // NewConfigurable creates a new charger
 func NewConfigurable(
 	statusG func() (string, error),
 	enabledG func() (bool, error),
 	enableS func(bool) error,
 	maxCurrentS func(int64) error,
-) (api.Charger, error) {
+) (*Charger, error) {
 	c := &Charger{
 		statusG:     statusG,
 		enabledG:    enabledG,
@@ -0,0 +1,71 @@
+package charger
+
+import (
+	"testing"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/provider"
+)
+
+func jsConfig(script string) map[string]interface{} {
+	return map[string]interface{}{
+		"source": "js",
+		"vm":     "chargertest",
+		"script": script,
+	}
+}
+
+func TestConfigurableChargerEx(t *testing.T) {
+	other := map[string]interface{}{
+		"status":     jsConfig("'C'"),
+		"enabled":    jsConfig("true"),
+		"enable":     jsConfig("enabled = enable"),
+		"maxcurrent": jsConfig("current = maxcurrent"),
+	}
+
+	c, err := NewConfigurableFromConfig(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := c.(api.ChargerEx); ok {
+		t.Error("unexpected api.ChargerEx without maxcurrentmillis")
+	}
+
+	other["maxcurrentmillis"] = jsConfig("current = maxcurrentmillis")
+
+	if c, err = NewConfigurableFromConfig(other); err != nil {
+		t.Fatal(err)
+	}
+
+	cx, ok := c.(api.ChargerEx)
+	if !ok {
+		t.Fatal("missing api.ChargerEx")
+	}
+
+	current, err := provider.NewFloatGetterFromConfig(provider.Config{
+		Source: "js",
+		Other:  map[string]interface{}{"vm": "chargertest", "script": "current"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := []struct {
+		set      func() error
+		expected float64
+	}{
+		{func() error { return c.MaxCurrent(16) }, 16},
+		{func() error { return cx.MaxCurrentMillis(6.5) }, 6.5},
+	}
+
+	for _, tc := range tc {
+		if err := tc.set(); err != nil {
+			t.Fatal(err)
+		}
+
+		if res, err := current(); err != nil || res != tc.expected {
+			t.Errorf("expected %.1f, got %.1f (%v)", tc.expected, res, err)
+		}
+	}
+}
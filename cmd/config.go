@@ -19,12 +19,17 @@ type config struct {
 	Log          string
 	SponsorToken string
 	Metrics      bool
+	Auth         server.AuthConfig
+	TLS          server.TLSConfig
+	History      server.HistoryConfig
+	DataDir      string
 	Profile      bool
 	Levels       map[string]string
 	Interval     time.Duration
 	Mqtt         mqttConfig
 	Javascript   map[string]interface{}
 	Influx       server.InfluxConfig
+	ModbusServer server.ModbusServerConfig
 	HEMS         typedConfig
 	Messaging    messagingConfig
 	Meters       []qualifiedConfig
@@ -35,8 +40,10 @@ type config struct {
 }
 
 type mqttConfig struct {
-	mqtt.Config `mapstructure:",squash"`
-	Topic       string
+	mqtt.Config       `mapstructure:",squash"`
+	server.MQTTConfig `mapstructure:",squash"`
+	Topic             string
+	Discovery         string // home assistant discovery prefix
 }
 
 func (conf *mqttConfig) RootTopic() string {
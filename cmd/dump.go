@@ -46,15 +46,18 @@ func runDump(cmd *cobra.Command, args []string) {
 	d.Header("config", "=")
 	fmt.Println("")
 
-	if name := site.Meters.GridMeterRef; name != "" {
+	for _, name := range site.Meters.GridMeterRefs {
 		d.DumpWithHeader(fmt.Sprintf("grid: %s", name), cp.Meter(name))
 	}
-	if name := site.Meters.PVMeterRef; name != "" {
+	for _, name := range site.Meters.PVMeterRefs {
 		d.DumpWithHeader(fmt.Sprintf("pv: %s", name), cp.Meter(name))
 	}
-	if name := site.Meters.BatteryMeterRef; name != "" {
+	for _, name := range site.Meters.BatteryMeterRefs {
 		d.DumpWithHeader(fmt.Sprintf("battery: %s", name), cp.Meter(name))
 	}
+	for _, name := range site.Meters.ConsumerMeterRefs {
+		d.DumpWithHeader(fmt.Sprintf("consumer: %s", name), cp.Meter(name))
+	}
 
 	for id, lpI := range site.LoadPoints() {
 		lp := lpI.(*core.LoadPoint)
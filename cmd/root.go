@@ -24,8 +24,9 @@ var (
 	log     = util.NewLogger("main")
 	cfgFile string
 
-	ignoreErrors = []string{"warn", "error", "fatal"} // don't add to cache
-	ignoreMqtt   = []string{"releaseNotes"}           // excessive size may crash certain brokers
+	ignoreErrors = []string{"warn", "error", "fatal"}                 // don't add to cache
+	ignoreMqtt   = []string{"releaseNotes"}                           // excessive size may crash certain brokers
+	ignoreInflux = []string{"releaseNotes", "warn", "error", "fatal"} // text not suited for time series
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -173,27 +174,85 @@ func run(cmd *cobra.Command, args []string) {
 
 	// setup database
 	if conf.Influx.URL != "" {
-		configureDatabase(conf.Influx, site.LoadPoints(), tee.Attach())
+		configureDatabase(conf.Influx, site.LoadPoints(), pipe.NewDropper(ignoreInflux...).Pipe(tee.Attach()))
 	}
 
 	// setup mqtt publisher
 	if conf.Mqtt.Broker != "" {
-		publisher := server.NewMQTT(conf.Mqtt.RootTopic())
+		publisher := server.NewMQTT(conf.Mqtt.RootTopic()).WithConfig(conf.Mqtt.MQTTConfig, cache)
+		if conf.Mqtt.Discovery != "" {
+			publisher.WithDiscovery(conf.Mqtt.Discovery)
+		}
 		go publisher.Run(site, pipe.NewDropper(ignoreMqtt...).Pipe(tee.Attach()))
 	}
 
+	// setup modbus server
+	if conf.ModbusServer.Port != 0 {
+		modbusServer, err := server.NewModbusServer(fmt.Sprintf(":%d", conf.ModbusServer.Port), site, cache)
+		if err != nil {
+			log.FATAL.Fatal(err)
+		}
+		go modbusServer.Run()
+	}
+
 	// create webserver
 	socketHub := server.NewSocketHub()
 	httpd := server.NewHTTPd(uri, site, socketHub, cache)
 
+	// https
+	if conf.TLS.Enabled() {
+		err := conf.TLS.Validate(conf.Auth)
+
+		var dataDir string
+		if err == nil {
+			dataDir, err = dataDirectory(conf)
+		}
+		if err == nil {
+			err = httpd.WithTLS(conf.TLS, dataDir)
+		}
+		if err != nil {
+			log.FATAL.Fatal(err)
+		}
+	}
+
+	// authentication
+	if conf.Auth.Enabled() {
+		if err := httpd.WithAuth(conf.Auth); err != nil {
+			log.FATAL.Fatal(err)
+		}
+	}
+
+	// history
+	var dataDir string
+	if conf.History.Persist {
+		if dataDir, err = dataDirectory(conf); err != nil {
+			log.FATAL.Fatal(err)
+		}
+	}
+
+	history := server.NewHistory(conf.History, dataDir)
+	httpd.WithHistory(history)
+	go history.Run(tee.Attach())
+
+	// energy statistics
+	stats := configureStatistics(conf)
+	site.WithStatistics(stats)
+	httpd.WithStatistics(stats)
+
+	// household baseload
+	baseload := configureBaseload(conf)
+	site.WithBaseload(baseload)
+	httpd.WithBaseload(baseload)
+
 	// metrics
 	if viper.GetBool("metrics") {
-		httpd.Router().Handle("/metrics", promhttp.Handler())
+		httpd.Router().Handle("/metrics", httpd.Authorize(server.RoleRead)(promhttp.Handler()))
+		go server.NewPrometheus(site.LoadPoints()).Run(tee.Attach())
 	}
 
 	// pprof
 	if viper.GetBool("profile") {
-		httpd.Router().PathPrefix("/debug/").Handler(http.DefaultServeMux)
+		httpd.Router().PathPrefix("/debug/").Handler(httpd.Authorize(server.RoleControl)(http.DefaultServeMux))
 	}
 
 	// start HEMS server
@@ -247,6 +306,18 @@ func run(cmd *cobra.Command, args []string) {
 		close(stopC) // signal loop to end
 		<-exitC      // wait for loop to end
 
+		if err := history.Persist(); err != nil {
+			log.ERROR.Printf("history: %v", err)
+		}
+
+		if err := stats.Persist(); err != nil {
+			log.ERROR.Printf("statistics: %v", err)
+		}
+
+		if err := baseload.Persist(); err != nil {
+			log.ERROR.Printf("baseload: %v", err)
+		}
+
 		os.Exit(1)
 	}()
 
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -17,6 +19,7 @@ import (
 	"github.com/andig/evcc/server"
 	"github.com/andig/evcc/util"
 	"github.com/andig/evcc/util/cloud"
+	"github.com/andig/evcc/util/oauth"
 	"github.com/andig/evcc/util/pipe"
 	"github.com/andig/evcc/util/sponsor"
 	paho "github.com/eclipse/paho.mqtt.golang"
@@ -58,9 +61,71 @@ func configureEnvironment(conf config) (err error) {
 		err = configureJavascript(conf.Javascript)
 	}
 
+	// persist oauth tokens of plugins
+	if err == nil {
+		configureTokenDir(conf)
+	}
+
 	return
 }
 
+// configureTokenDir persists oauth tokens to the data directory
+func configureTokenDir(conf config) {
+	dataDir, err := dataDirectory(conf)
+	if err != nil {
+		log.ERROR.Printf("tokens not persisted: %v", err)
+		return
+	}
+
+	oauth.TokenDir = dataDir
+}
+
+// dataDirectory returns the directory for persisting data, defaulting to ~/.evcc.
+// The directory is not created before data is actually written.
+func dataDirectory(conf config) (string, error) {
+	dir := conf.DataDir
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".evcc")
+	}
+
+	return dir, nil
+}
+
+// dataFile returns the named file in the data directory or empty string if the directory is not available
+func dataFile(conf config, name string) string {
+	dataDir, err := dataDirectory(conf)
+	if err != nil {
+		log.ERROR.Printf("%s not persisted: %v", name, err)
+		return ""
+	}
+
+	return filepath.Join(dataDir, name)
+}
+
+// configureStatistics creates the energy statistics persisted in the data directory
+func configureStatistics(conf config) *core.Statistics {
+	stats, err := core.NewStatistics(dataFile(conf, "statistics.json"))
+	if err != nil {
+		log.ERROR.Printf("statistics: %v", err)
+	}
+
+	return stats
+}
+
+// configureBaseload creates the household baseload profile persisted in the data directory
+func configureBaseload(conf config) *core.Baseload {
+	baseload, err := core.NewBaseload(dataFile(conf, "baseload.json"))
+	if err != nil {
+		log.ERROR.Printf("baseload: %v", err)
+	}
+
+	return baseload
+}
+
 func configureSponsorship(token string) error {
 	host := util.Getenv("GRPC_URI", cloud.Host)
 	conn, err := cloud.Connection(host)
@@ -96,7 +161,11 @@ func configureDatabase(conf server.InfluxConfig, loadPoints []core.LoadPointAPI,
 		conf.User,
 		conf.Password,
 		conf.Database,
-	)
+	).WithMeasurement(conf.Measurement)
+
+	if len(conf.Tags) > 0 {
+		influx.WithTags(conf.Tags)
+	}
 
 	// eliminate duplicate values
 	dedupe := pipe.NewDeduplicator(30*time.Minute, "socCharge")
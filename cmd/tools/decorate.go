@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"go/ast"
 	"go/format"
+	"go/parser"
 	"io"
 	"os"
 	"strings"
@@ -50,7 +52,7 @@ import (
 		}
 {{- end -}}
 
-func {{.Function}}(base {{.BaseType}}{{range ordered}}, {{.VarName}} func() {{slice .Signature 7}}{{end}}) {{.ReturnType}} {
+func {{.Function}}(base {{.BaseType}}{{range ordered}}, {{.VarName}} {{.Signature}}{{end}}) {{.ReturnType}} {
 {{- $basetype := .BaseType}}
 {{- $shortbase := .ShortBase}}
 {{- $prefix := .Function}}
@@ -74,7 +76,7 @@ type {{$prefix}}{{.ShortType}}Impl struct {
 }
 
 func (impl *{{$prefix}}{{.ShortType}}Impl) {{.Function}}{{slice .Signature 4}} {
-	return impl.{{.VarName}}()
+	return impl.{{.VarName}}({{.Params}})
 }
 
 {{end}}
@@ -85,7 +87,33 @@ type dynamicType struct {
 }
 
 type typeStruct struct {
-	Type, ShortType, Signature, Function, VarName string
+	Type, ShortType, Signature, Function, VarName, Params string
+}
+
+// params returns the comma-separated parameter names of the function signature
+func params(signature string) (string, error) {
+	expr, err := parser.ParseExpr(signature)
+	if err != nil {
+		return "", err
+	}
+
+	fun, ok := expr.(*ast.FuncType)
+	if !ok {
+		return "", fmt.Errorf("invalid function signature: %s", signature)
+	}
+
+	var names []string
+	for _, field := range fun.Params.List {
+		if len(field.Names) == 0 {
+			return "", fmt.Errorf("unnamed parameter in function signature: %s", signature)
+		}
+
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+	}
+
+	return strings.Join(names, ", "), nil
 }
 
 func generate(out io.Writer, packageName, functionName, baseType string, dynamicTypes ...dynamicType) error {
@@ -135,12 +163,18 @@ func generate(out io.Writer, packageName, functionName, baseType string, dynamic
 	for _, dt := range dynamicTypes {
 		parts := strings.SplitN(dt.typ, ".", 2)
 
+		params, err := params(dt.signature)
+		if err != nil {
+			return err
+		}
+
 		types[dt.typ] = typeStruct{
 			Type:      dt.typ,
 			ShortType: parts[1],
 			VarName:   strings.ToLower(parts[1][:1]) + parts[1][1:],
 			Signature: dt.signature,
 			Function:  dt.function,
+			Params:    params,
 		}
 
 		combos = append(combos, dt.typ)
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/andig/evcc/util"
+	"github.com/benbjohnson/clock"
+)
+
+const (
+	baseloadSlots      = 7 * 24 // hourly slots per week
+	baseloadAlpha      = 0.005  // minimum weight of new samples, about 2 weeks at 30s interval
+	baseloadMinSamples = 30     // samples required before a slot is used
+)
+
+// AdaptiveResidualConfig configures the residual power learned from the household baseload
+type AdaptiveResidualConfig struct {
+	Enable bool    // adjust residual power to the learned household baseload
+	Factor float64 // multiple of the baseload's standard deviation, default 1
+	Min    float64 // lower limit of the residual power, may be below the static residual power
+	Max    float64 // upper limit of the residual power
+}
+
+// BaseloadSlot is the learned household consumption of one hour of the week
+type BaseloadSlot struct {
+	Mean    float64 `json:"mean"`
+	StdDev  float64 `json:"stdDev"`
+	Samples int     `json:"samples"`
+
+	variance float64
+}
+
+// add adds the sample using an exponentially weighted mean and variance
+func (s *BaseloadSlot) add(power float64) {
+	s.Samples++
+	alpha := math.Max(1/float64(s.Samples), baseloadAlpha)
+
+	delta := power - s.Mean
+	s.Mean += alpha * delta
+	s.variance = (1 - alpha) * (s.variance + alpha*delta*delta)
+	s.StdDev = math.Sqrt(s.variance)
+}
+
+// Baseload learns the household consumption by hour of the week
+type Baseload struct {
+	mu        sync.Mutex
+	clock     clock.Clock
+	file      string
+	persisted time.Time
+	slots     [baseloadSlots]BaseloadSlot
+}
+
+// NewBaseload creates a baseload profile. If file is given, the profile is
+// loaded from and periodically stored to file.
+func NewBaseload(file string) (*Baseload, error) {
+	b := &Baseload{
+		clock: clock.New(),
+		file:  file,
+	}
+
+	if file != "" {
+		if err := b.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return b, err
+		}
+	}
+
+	return b, nil
+}
+
+// slot returns the index of the slot for the given time
+func (b *Baseload) slot(ts time.Time) int {
+	return int(ts.Weekday())*24 + ts.Hour()
+}
+
+// add adds the household consumption to the current slot
+func (b *Baseload) add(power float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.slots[b.slot(b.clock.Now())].add(power)
+}
+
+// Current returns the learned baseload of the current slot and if enough samples have been collected
+func (b *Baseload) Current() (BaseloadSlot, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := b.slots[b.slot(b.clock.Now())]
+	return s, s.Samples >= baseloadMinSamples
+}
+
+// Profile returns the learned baseload starting with Sunday 0:00
+func (b *Baseload) Profile() []BaseloadSlot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]BaseloadSlot{}, b.slots[:]...)
+}
+
+// residualPower returns the residual power adjusted to the learned baseload fluctuation.
+// Once learned, the static residual power is replaced by the configured minimum plus
+// the baseload's standard deviation, allowing lower margins for stable consumption.
+func (b *Baseload) residualPower(residual float64, conf AdaptiveResidualConfig) float64 {
+	s, ok := b.Current()
+	if !ok {
+		return residual
+	}
+
+	factor := conf.Factor
+	if factor == 0 {
+		factor = 1
+	}
+
+	res := conf.Min + factor*s.StdDev
+	if conf.Max > 0 {
+		res = math.Min(res, conf.Max)
+	}
+
+	return res
+}
+
+// baseloadSlotJSON is the persisted slot including its variance
+type baseloadSlotJSON struct {
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+	Samples  int     `json:"samples"`
+}
+
+// persistDue returns true if the persistence interval has elapsed
+func (b *Baseload) persistDue() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.file != "" && b.clock.Since(b.persisted) >= statsPersist
+}
+
+// Persist stores the profile to file
+func (b *Baseload) Persist() error {
+	if b.file == "" {
+		return nil
+	}
+
+	b.mu.Lock()
+	b.persisted = b.clock.Now()
+	data := make([]baseloadSlotJSON, len(b.slots))
+	for i, s := range b.slots {
+		data[i] = baseloadSlotJSON{Mean: s.Mean, Variance: s.variance, Samples: s.Samples}
+	}
+	b.mu.Unlock()
+
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFileAtomic(b.file, buf, 0600)
+}
+
+// load restores the profile from file
+func (b *Baseload) load() error {
+	buf, err := os.ReadFile(b.file)
+	if err != nil {
+		return err
+	}
+
+	var data []baseloadSlotJSON
+	if err := json.Unmarshal(buf, &data); err != nil {
+		return err
+	}
+
+	if len(data) != baseloadSlots {
+		return errors.New("invalid baseload profile")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, s := range data {
+		b.slots[i] = BaseloadSlot{
+			Mean:     s.Mean,
+			StdDev:   math.Sqrt(s.Variance),
+			Samples:  s.Samples,
+			variance: s.Variance,
+		}
+	}
+
+	b.persisted = b.clock.Now()
+
+	return nil
+}
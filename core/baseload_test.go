@@ -0,0 +1,119 @@
+package core
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andig/evcc/api"
+	"github.com/benbjohnson/clock"
+)
+
+func newTestBaseload(t *testing.T, file string) (*Baseload, *clock.Mock) {
+	b, err := NewBaseload(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clck := clock.NewMock()
+	clck.Set(time.Date(2021, 5, 2, 12, 0, 0, 0, time.Local)) // Sunday
+	b.clock = clck
+
+	return b, clck
+}
+
+func TestBaseloadSlot(t *testing.T) {
+	var s BaseloadSlot
+
+	// alternating 100W and 300W
+	for i := 0; i < 1000; i++ {
+		s.add(100 + 200*float64(i%2))
+	}
+
+	if math.Abs(s.Mean-200) > 5 {
+		t.Errorf("expected mean 200, got %.1f", s.Mean)
+	}
+
+	if math.Abs(s.StdDev-100) > 5 {
+		t.Errorf("expected stddev 100, got %.1f", s.StdDev)
+	}
+}
+
+func TestBaseloadResidualPower(t *testing.T) {
+	b, clck := newTestBaseload(t, "")
+
+	if res := b.residualPower(100, AdaptiveResidualConfig{Enable: true}); res != 100 {
+		t.Errorf("expected static residual power without samples, got %.0f", res)
+	}
+
+	// noon: fluctuating consumption
+	for i := 0; i < baseloadMinSamples; i++ {
+		b.add(500 + 400*float64(i%2))
+	}
+
+	// night: stable consumption
+	clck.Set(time.Date(2021, 5, 2, 3, 0, 0, 0, time.Local))
+	for i := 0; i < baseloadMinSamples; i++ {
+		b.add(150)
+	}
+
+	tc := []struct {
+		hour     int
+		conf     AdaptiveResidualConfig
+		expected float64
+	}{
+		{3, AdaptiveResidualConfig{}, 0}, // below static residual power
+		{3, AdaptiveResidualConfig{Min: 50}, 50},
+		{12, AdaptiveResidualConfig{}, 200},
+		{12, AdaptiveResidualConfig{Min: 100}, 300},
+		{12, AdaptiveResidualConfig{Factor: 2}, 400},
+		{12, AdaptiveResidualConfig{Factor: 2, Max: 300}, 300},
+		{13, AdaptiveResidualConfig{}, 100}, // not learned
+	}
+
+	for _, tc := range tc {
+		clck.Set(time.Date(2021, 5, 2, tc.hour, 0, 0, 0, time.Local))
+
+		if res := b.residualPower(100, tc.conf); math.Abs(res-tc.expected) > 1e-6 {
+			t.Errorf("%d:00 %+v: expected %.0f, got %.0f", tc.hour, tc.conf, tc.expected, res)
+		}
+	}
+
+	if p := b.Profile(); len(p) != baseloadSlots || p[12].Samples != baseloadMinSamples {
+		t.Errorf("unexpected profile: %v", p[12])
+	}
+}
+
+func TestBaseloadPersist(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "evcc", "baseload.json") // data directory is created on save
+
+	b, _ := newTestBaseload(t, file)
+	for i := 0; i < baseloadMinSamples; i++ {
+		b.add(100 + 200*float64(i%2))
+	}
+
+	if err := b.Persist(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, _ := newTestBaseload(t, file)
+	if s, r := b.slots[12], restored.slots[12]; s != r {
+		t.Errorf("expected %+v, got %+v", s, r)
+	}
+}
+
+func TestSiteResidualPower(t *testing.T) {
+	site := NewSite()
+	site.ResidualPower = 100
+	site.pvMeters = []api.Meter{&testMeter{}}
+
+	if res := site.residualPower(); res != 0 {
+		t.Errorf("expected no residual power without grid meter, got %.0f", res)
+	}
+
+	site.gridMeters = []api.Meter{&testMeter{}}
+	if res := site.residualPower(); res != 100 {
+		t.Errorf("expected residual power, got %.0f", res)
+	}
+}
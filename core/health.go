@@ -33,6 +33,21 @@ func (health *Health) Healthy() bool {
 	return false
 }
 
+// Fail marks the health as failed until the next update, e.g. if meter values are outdated
+func (health *Health) Fail() {
+	start := time.Now()
+
+	for time.Since(start) < time.Second {
+		if atomic.CompareAndSwapUint32(&health.locker, 0, 1) {
+			health.updated = time.Time{}
+			atomic.StoreUint32(&health.locker, 0)
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
 // Update updates the health timer on each loadpoint update
 func (health *Health) Update() {
 	start := time.Now()
@@ -1,6 +1,8 @@
 package core
 
 import (
+	"math"
+
 	"github.com/avast/retry-go"
 )
 
@@ -20,10 +22,10 @@ func powerToCurrent(power float64, phases int64) float64 {
 	return power / (float64(phases) * Voltage)
 }
 
-// consumedPower estimates how much power the charger might have consumed given it was the only load
-// func consumedPower(pv, battery, grid float64) float64 {
-// 	return math.Abs(pv) + battery + grid
-// }
+// consumedPower returns the total power consumed on site including loadpoints
+func consumedPower(pv, battery, grid float64) float64 {
+	return math.Abs(pv) + battery + grid
+}
 
 // sitePower returns the available delta power that the charger might additionally consume
 // negative value: available power (grid export), positive value: grid import
@@ -109,7 +109,10 @@ type LoadPoint struct {
 	vehicles       []api.Vehicle // Assigned vehicles
 	socEstimator   *soc.Estimator
 	socTimer       *soc.Timer
-	vehicleIdError error // state of last vehicle identification
+	vehicleIdError error       // state of last vehicle identification
+	vehicleRequest api.Vehicle // vehicle selected via api, guarded by mutex
+	phasesRequest  int64       // phases set via api, guarded by mutex
+	phasesFixed    bool        // phases set via api are not overridden by detection
 
 	// cached state
 	status         api.ChargeStatus // Charger status
@@ -119,9 +122,12 @@ type LoadPoint struct {
 	connectedTime  time.Time        // Time when vehicle was connected
 	pvTimer        time.Time        // PV enabled/disable timer
 
-	socCharge      float64       // Vehicle SoC
-	chargedEnergy  float64       // Charged energy while connected in Wh
-	chargeDuration time.Duration // Charge duration
+	socCharge         float64       // Vehicle SoC
+	chargedEnergy     float64       // Charged energy while connected in Wh
+	chargeTotalEnergy float64       // Charge meter total energy in kWh
+	energyFailed      bool          // Charge meter total energy read failed, logged once
+	chargeDuration    time.Duration // Charge duration
+	readErrors        int64         // Charger, meter and vehicle read errors
 }
 
 // NewLoadPointFromConfig creates a new loadpoint
@@ -288,6 +294,12 @@ func (lp *LoadPoint) publish(key string, val interface{}) {
 	}
 }
 
+// countReadError increments and publishes the device read error counter
+func (lp *LoadPoint) countReadError() {
+	lp.readErrors++
+	lp.publish("readErrors", lp.readErrors)
+}
+
 // evChargeStartHandler sends external start event
 func (lp *LoadPoint) evChargeStartHandler() {
 	lp.log.INFO.Println("start charging ->")
@@ -465,6 +477,7 @@ func (lp *LoadPoint) syncCharger() {
 
 	if err != nil {
 		lp.log.ERROR.Printf("charger: %v", err)
+		lp.countReadError()
 	}
 }
 
@@ -613,6 +626,46 @@ func (lp *LoadPoint) setActiveVehicle(vehicle api.Vehicle) {
 	lp.publish("socCapacity", lp.vehicle.Capacity())
 }
 
+// applyVehicleRequest activates the vehicle selected via api. It must only be called from
+// the loadpoint's update as the active vehicle is not guarded by the mutex.
+func (lp *LoadPoint) applyVehicleRequest() {
+	lp.Lock()
+	vehicle := lp.vehicleRequest
+	lp.vehicleRequest = nil
+	lp.Unlock()
+
+	if vehicle != nil && vehicle != lp.vehicle {
+		lp.setActiveVehicle(vehicle)
+
+		// soc update reset
+		lp.socUpdated = time.Time{}
+	}
+}
+
+// setPhases updates the active phases. It must only be called from the loadpoint's update.
+func (lp *LoadPoint) setPhases(phases int64) {
+	lp.Lock()
+	lp.Phases = phases
+	lp.Unlock()
+
+	lp.publish("activePhases", phases)
+}
+
+// applyPhasesRequest activates the phases set via api. Once set, phases are no longer
+// overridden by detection. It must only be called from the loadpoint's update.
+func (lp *LoadPoint) applyPhasesRequest() {
+	lp.Lock()
+	phases := lp.phasesRequest
+	lp.phasesRequest = 0
+	lp.Unlock()
+
+	if phases > 0 {
+		lp.phasesFixed = true
+		lp.setPhases(phases)
+		lp.publish("phases", phases)
+	}
+}
+
 // vehicleIdentificationAllowed returns true if active vehicle has not yet been identified
 func (lp *LoadPoint) vehicleIdentificationAllowed() bool {
 	return errors.Is(lp.vehicleIdError, api.ErrMustRetry)
@@ -868,6 +921,29 @@ func (lp *LoadPoint) updateChargePower() {
 
 	if err != nil {
 		lp.log.ERROR.Printf("charge meter: %v", err)
+		lp.countReadError()
+	}
+
+	if m, ok := lp.chargeMeter.(api.MeterEnergy); ok {
+		if f, err := m.TotalEnergy(); err == nil {
+			if lp.energyFailed {
+				lp.log.INFO.Println("charge meter energy: recovered")
+				lp.energyFailed = false
+			}
+
+			lp.chargeTotalEnergy = f
+			lp.log.DEBUG.Printf("charge total energy: %.3fkWh", f)
+			lp.publish("chargeTotalEnergy", f)
+		} else {
+			// log failures only once
+			logf := lp.log.ERROR.Printf
+			if lp.energyFailed {
+				logf = lp.log.DEBUG.Printf
+			}
+			lp.energyFailed = true
+
+			logf("charge meter energy: %v", err)
+		}
 	}
 }
 
@@ -882,6 +958,7 @@ func (lp *LoadPoint) updateChargeCurrents() {
 	i1, i2, i3, err := phaseMeter.Currents()
 	if err != nil {
 		lp.log.ERROR.Printf("charge meter: %v", err)
+		lp.countReadError()
 		return
 	}
 
@@ -889,7 +966,7 @@ func (lp *LoadPoint) updateChargeCurrents() {
 	lp.log.DEBUG.Printf("charge currents: %.3gA", lp.chargeCurrents)
 	lp.publish("chargeCurrents", lp.chargeCurrents)
 
-	if lp.charging() {
+	if lp.charging() && !lp.phasesFixed {
 		var phases int64
 		for _, i := range lp.chargeCurrents {
 			if i >= minActiveCurrent {
@@ -898,10 +975,8 @@ func (lp *LoadPoint) updateChargeCurrents() {
 		}
 
 		if phases > 0 {
-			lp.Phases = phases
-			lp.log.DEBUG.Printf("detected phases: %dp %.3gA", lp.Phases, lp.chargeCurrents)
-
-			lp.publish("activePhases", lp.Phases)
+			lp.setPhases(phases)
+			lp.log.DEBUG.Printf("detected phases: %dp %.3gA", phases, lp.chargeCurrents)
 		}
 	}
 }
@@ -967,6 +1042,7 @@ func (lp *LoadPoint) publishSoCAndRange() {
 				lp.log.DEBUG.Printf("vehicle: waiting for update")
 			} else {
 				lp.log.ERROR.Printf("vehicle: %v", err)
+				lp.countReadError()
 			}
 		}
 
@@ -996,6 +1072,9 @@ func (lp *LoadPoint) Update(sitePower float64) {
 	mode := lp.GetMode()
 	lp.publish("mode", mode)
 
+	// apply phases set via api before converting power and current
+	lp.applyPhasesRequest()
+
 	// read and publish meters first
 	lp.updateChargePower()
 	lp.updateChargeCurrents()
@@ -1010,6 +1089,7 @@ func (lp *LoadPoint) Update(sitePower float64) {
 	// read and publish status
 	if err := lp.updateChargerStatus(); err != nil {
 		lp.log.ERROR.Printf("charger: %v", err)
+		lp.countReadError()
 		return
 	}
 
@@ -1022,6 +1102,9 @@ func (lp *LoadPoint) Update(sitePower float64) {
 		lp.findActiveVehicle()
 	}
 
+	// apply vehicle selected via api
+	lp.applyVehicleRequest()
+
 	// publish soc after updating charger status to make sure
 	// initial update of connected state matches charger status
 	lp.publishSoCAndRange()
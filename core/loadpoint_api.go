@@ -1,6 +1,8 @@
 package core
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/andig/evcc/api"
@@ -29,6 +31,8 @@ type LoadPointAPI interface {
 	SetMinSoC(int) error
 	SetTargetCharge(time.Time, int)
 	RemoteControl(string, RemoteDemand)
+	GetVehicles() []string
+	SetVehicle(string) error
 
 	// energy
 	GetChargePower() float64
@@ -38,6 +42,8 @@ type LoadPointAPI interface {
 	SetMaxCurrent(float64)
 	GetMinPower() float64
 	GetMaxPower() float64
+	GetPhases() int64
+	SetConfiguredPhases(int64) error
 }
 
 // GetStatus returns the charging status
@@ -165,6 +171,37 @@ func (lp *LoadPoint) RemoteControl(source string, demand RemoteDemand) {
 	}
 }
 
+// GetVehicles returns the titles of the loadpoint's vehicles
+func (lp *LoadPoint) GetVehicles() []string {
+	res := make([]string, 0, len(lp.vehicles))
+	for _, vehicle := range lp.vehicles {
+		res = append(res, vehicle.Title())
+	}
+	return res
+}
+
+// SetVehicle sets the active vehicle by title
+func (lp *LoadPoint) SetVehicle(title string) error {
+	for _, vehicle := range lp.vehicles {
+		if !strings.EqualFold(vehicle.Title(), title) {
+			continue
+		}
+
+		lp.Lock()
+		defer lp.Unlock()
+
+		lp.log.INFO.Println("set vehicle:", vehicle.Title())
+
+		// vehicle is changed by the loadpoint update
+		lp.vehicleRequest = vehicle
+		lp.requestUpdate()
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown vehicle: %s", title)
+}
+
 // HasChargeMeter determines if a physical charge meter is attached
 func (lp *LoadPoint) HasChargeMeter() bool {
 	_, isWrapped := lp.chargeMeter.(*wrapper.ChargeMeter)
@@ -221,5 +258,40 @@ func (lp *LoadPoint) GetMinPower() float64 {
 
 // GetMaxPower returns the max loadpoint power taking active phases into account
 func (lp *LoadPoint) GetMaxPower() float64 {
-	return Voltage * lp.GetMaxCurrent() * float64(lp.Phases)
+	lp.Lock()
+	phases := lp.Phases
+	lp.Unlock()
+
+	return Voltage * lp.GetMaxCurrent() * float64(phases)
+}
+
+// GetPhases returns the loadpoint's number of phases including pending changes set via api
+func (lp *LoadPoint) GetPhases() int64 {
+	lp.Lock()
+	defer lp.Unlock()
+
+	if lp.phasesRequest > 0 {
+		return lp.phasesRequest
+	}
+
+	return lp.Phases
+}
+
+// SetConfiguredPhases sets the number of phases used for converting power and current.
+// It does not switch the phases of the charger.
+func (lp *LoadPoint) SetConfiguredPhases(phases int64) error {
+	if phases < 1 || phases > 3 {
+		return fmt.Errorf("invalid phases: %d", phases)
+	}
+
+	lp.Lock()
+	defer lp.Unlock()
+
+	lp.log.INFO.Println("set phases:", phases)
+
+	// phases are changed by the loadpoint update
+	lp.phasesRequest = phases
+	lp.requestUpdate()
+
+	return nil
 }
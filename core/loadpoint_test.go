@@ -747,3 +747,37 @@ func TestMinSoC(t *testing.T) {
 		}
 	}
 }
+
+func TestSetConfiguredPhases(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	charger := mock.NewMockCharger(ctrl)
+	charger.EXPECT().Status().Return(api.StatusC, nil).AnyTimes()
+
+	lp := NewLoadPoint(util.NewLogger("foo"))
+	lp.charger = charger
+	lp.chargeMeter = &testPhaseMeter{currents: []float64{10, 0, 0}}
+	lp.status = api.StatusC
+
+	if err := lp.SetConfiguredPhases(4); err == nil {
+		t.Error("expected invalid phases error")
+	}
+
+	if err := lp.SetConfiguredPhases(3); err != nil {
+		t.Fatal(err)
+	}
+
+	// pending until applied by update
+	if lp.GetPhases() != 3 || lp.Phases != 1 {
+		t.Errorf("expected pending phases 3, got %d (active %d)", lp.GetPhases(), lp.Phases)
+	}
+
+	lp.applyPhasesRequest()
+
+	// detection does not override phases set via api
+	lp.updateChargeCurrents()
+
+	if lp.GetPhases() != 3 || lp.Phases != 3 {
+		t.Errorf("expected phases 3, got %d (active %d)", lp.GetPhases(), lp.Phases)
+	}
+}
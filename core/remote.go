@@ -1,6 +1,9 @@
 package core
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 // RemoteDemand defines external status demand
 type RemoteDemand string
@@ -23,3 +26,17 @@ func RemoteDemandString(demand string) (RemoteDemand, error) {
 		return RemoteEnable, nil
 	}
 }
+
+// ParseRemoteDemand converts string to RemoteDemand. Unlike RemoteDemandString it fails for unknown demands.
+func ParseRemoteDemand(demand string) (RemoteDemand, error) {
+	switch strings.ToLower(demand) {
+	case string(RemoteHardDisable):
+		return RemoteHardDisable, nil
+	case string(RemoteSoftDisable):
+		return RemoteSoftDisable, nil
+	case string(RemoteEnable), "enable":
+		return RemoteEnable, nil
+	default:
+		return RemoteEnable, fmt.Errorf("invalid remote demand: %s", demand)
+	}
+}
@@ -0,0 +1,33 @@
+package core
+
+import "testing"
+
+func TestRemoteDemandString(t *testing.T) {
+	// unknown demands enable for compatibility
+	for _, in := range []string{"", "enable", "foo"} {
+		if res, err := RemoteDemandString(in); res != RemoteEnable || err != nil {
+			t.Errorf("%s: expected %q, got %q (%v)", in, RemoteEnable, res, err)
+		}
+	}
+}
+
+func TestParseRemoteDemand(t *testing.T) {
+	tc := []struct {
+		in  string
+		out RemoteDemand
+		err bool
+	}{
+		{"", RemoteEnable, false},
+		{"enable", RemoteEnable, false},
+		{"hard", RemoteHardDisable, false},
+		{"Soft", RemoteSoftDisable, false},
+		{"foo", RemoteEnable, true},
+	}
+
+	for _, tc := range tc {
+		res, err := ParseRemoteDemand(tc.in)
+		if res != tc.out || (err != nil) != tc.err {
+			t.Errorf("%s: expected %q (err %v), got %q (%v)", tc.in, tc.out, tc.err, res, err)
+		}
+	}
+}
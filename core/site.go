@@ -37,24 +37,58 @@ type Site struct {
 	Meters        MetersConfig // Meter references
 	PrioritySoC   float64      `mapstructure:"prioritySoC"` // prefer battery up to this SoC
 
+	AdaptiveResidual AdaptiveResidualConfig `mapstructure:"adaptiveResidual"` // Grid meter only: adjust residual power to household baseload
+
 	// meters
-	gridMeter    api.Meter // Grid usage meter
-	pvMeter      api.Meter // PV generation meter
-	batteryMeter api.Meter // Battery charging meter
+	gridMeters     []api.Meter // Grid usage meters
+	pvMeters       []api.Meter // PV generation meters
+	batteryMeters  []api.Meter // Battery charging meters
+	consumerMeters []api.Meter // Household consumer meters
 
 	loadpoints []*LoadPoint // Loadpoints
+	stats      *Statistics  // Energy statistics
+	baseload   *Baseload    // Household baseload profile
 
 	// cached state
-	gridPower    float64 // Grid power
-	pvPower      float64 // PV power
-	batteryPower float64 // Battery charge power
+	gridPower      float64            // Grid power
+	pvPower        float64            // PV power
+	batteryPower   float64            // Battery charge power
+	homePower      float64            // Household consumption excluding loadpoints
+	consumerPowers []float64          // Household consumer power
+	meterPowers    map[string]float64 // Individual power of multiple meters
+	meterEnergy    map[string]float64 // Meter total energy
+	energyFailed   map[string]bool    // Meter total energy read failed, logged once
+	readErrors     int64              // Meter read errors
 }
 
 // MetersConfig contains the loadpoint's meter configuration
 type MetersConfig struct {
-	GridMeterRef    string `mapstructure:"grid"`    // Grid usage meter reference
-	PVMeterRef      string `mapstructure:"pv"`      // PV generation meter reference
-	BatteryMeterRef string `mapstructure:"battery"` // Battery charging meter reference
+	GridMeterRefs     []string `mapstructure:"grid"`      // Grid usage meter references, multiple for split grid connections
+	PVMeterRefs       []string `mapstructure:"pv"`        // PV generation meter references
+	BatteryMeterRefs  []string `mapstructure:"battery"`   // Battery charging meter references
+	ConsumerMeterRefs []string `mapstructure:"consumers"` // Household consumer meter references, e.g. heat pump
+}
+
+// reservedMeterNames are used for publishing site meter totals and cannot be used
+// as names of consumers or multiple meters of the same kind
+var reservedMeterNames = []string{"grid", "pv", "battery", "home"}
+
+// meters returns the meters of the given references. If values of the meters are
+// published individually, their references must not collide with the site totals.
+func meters(cp configProvider, refs []string, individual bool) ([]api.Meter, error) {
+	var res []api.Meter
+
+	for _, ref := range refs {
+		for _, name := range reservedMeterNames {
+			if individual && ref == name {
+				return nil, fmt.Errorf("invalid meter name: %s", ref)
+			}
+		}
+
+		res = append(res, cp.Meter(ref))
+	}
+
+	return res, nil
 }
 
 // NewSiteFromConfig creates a new site
@@ -72,18 +106,23 @@ func NewSiteFromConfig(
 	Voltage = site.Voltage
 	site.loadpoints = loadpoints
 
-	if site.Meters.GridMeterRef != "" {
-		site.gridMeter = cp.Meter(site.Meters.GridMeterRef)
+	// configure meters from references
+	var err error
+	if site.gridMeters, err = meters(cp, site.Meters.GridMeterRefs, len(site.Meters.GridMeterRefs) > 1); err != nil {
+		return nil, err
 	}
-	if site.Meters.PVMeterRef != "" {
-		site.pvMeter = cp.Meter(site.Meters.PVMeterRef)
+	if site.pvMeters, err = meters(cp, site.Meters.PVMeterRefs, len(site.Meters.PVMeterRefs) > 1); err != nil {
+		return nil, err
 	}
-	if site.Meters.BatteryMeterRef != "" {
-		site.batteryMeter = cp.Meter(site.Meters.BatteryMeterRef)
+	if site.batteryMeters, err = meters(cp, site.Meters.BatteryMeterRefs, len(site.Meters.BatteryMeterRefs) > 1); err != nil {
+		return nil, err
+	}
+	if site.consumerMeters, err = meters(cp, site.Meters.ConsumerMeterRefs, true); err != nil {
+		return nil, err
 	}
+	site.consumerPowers = make([]float64, len(site.consumerMeters))
 
-	// configure meter from references
-	if site.gridMeter == nil && site.pvMeter == nil {
+	if len(site.gridMeters) == 0 && len(site.pvMeters) == 0 {
 		return nil, errors.New("missing either grid or pv meter")
 	}
 
@@ -92,15 +131,35 @@ func NewSiteFromConfig(
 
 // NewSite creates a Site with sane defaults
 func NewSite() *Site {
+	stats, _ := NewStatistics("")
+	baseload, _ := NewBaseload("")
+
 	lp := &Site{
-		log:     util.NewLogger("site"),
-		Health:  NewHealth(60 * time.Second),
-		Voltage: 230, // V
+		log:          util.NewLogger("site"),
+		Health:       NewHealth(60 * time.Second),
+		Voltage:      230, // V
+		stats:        stats,
+		baseload:     baseload,
+		meterPowers:  make(map[string]float64),
+		meterEnergy:  make(map[string]float64),
+		energyFailed: make(map[string]bool),
 	}
 
 	return lp
 }
 
+// WithStatistics replaces the site's in-memory energy statistics
+func (site *Site) WithStatistics(stats *Statistics) *Site {
+	site.stats = stats
+	return site
+}
+
+// WithBaseload replaces the site's in-memory baseload profile
+func (site *Site) WithBaseload(baseload *Baseload) *Site {
+	site.baseload = baseload
+	return site
+}
+
 // LoadPoints returns the array of associated loadpoints
 func (site *Site) LoadPoints() []LoadPointAPI {
 	res := make([]LoadPointAPI, len(site.loadpoints))
@@ -110,6 +169,15 @@ func (site *Site) LoadPoints() []LoadPointAPI {
 	return res
 }
 
+// meterName returns the name for publishing a meter's values. A single meter
+// uses the name of its kind, multiple meters use their reference.
+func meterName(kind string, refs []string, i int) string {
+	if len(refs) <= 1 {
+		return kind
+	}
+	return refs[i]
+}
+
 func meterCapabilities(name string, meter interface{}) string {
 	_, power := meter.(api.Meter)
 	_, energy := meter.(api.MeterEnergy)
@@ -130,32 +198,37 @@ func (site *Site) DumpConfig() {
 
 	site.log.INFO.Println("site config:")
 	site.log.INFO.Printf("  meters:    grid %s pv %s battery %s",
-		presence[site.gridMeter != nil],
-		presence[site.pvMeter != nil],
-		presence[site.batteryMeter != nil],
+		presence[len(site.gridMeters) > 0],
+		presence[len(site.pvMeters) > 0],
+		presence[len(site.batteryMeters) > 0],
 	)
 
-	site.publish("gridConfigured", site.gridMeter != nil)
-	if site.gridMeter != nil {
-		site.log.INFO.Println(meterCapabilities("grid", site.gridMeter))
+	site.publish("gridConfigured", len(site.gridMeters) > 0)
+	for i, meter := range site.gridMeters {
+		site.log.INFO.Println(meterCapabilities(meterName("grid", site.Meters.GridMeterRefs, i), meter))
 	}
 
-	site.publish("pvConfigured", site.pvMeter != nil)
-	if site.pvMeter != nil {
-		site.log.INFO.Println(meterCapabilities("pv", site.pvMeter))
+	site.publish("pvConfigured", len(site.pvMeters) > 0)
+	for i, meter := range site.pvMeters {
+		site.log.INFO.Println(meterCapabilities(meterName("pv", site.Meters.PVMeterRefs, i), meter))
 	}
 
-	site.publish("batteryConfigured", site.batteryMeter != nil)
-	if site.batteryMeter != nil {
-		_, ok := site.batteryMeter.(api.Battery)
+	site.publish("batteryConfigured", len(site.batteryMeters) > 0)
+	for i, meter := range site.batteryMeters {
+		_, soc := meter.(api.Battery)
+		_, capacity := meter.(api.BatteryCapacity)
 		site.log.INFO.Println(
-			meterCapabilities("battery", site.batteryMeter),
-			fmt.Sprintf("soc %s", presence[ok]),
+			meterCapabilities(meterName("battery", site.Meters.BatteryMeterRefs, i), meter),
+			fmt.Sprintf("soc %s capacity %s", presence[soc], presence[capacity]),
 		)
+	}
 
-		if ok {
-			site.publish("prioritySoC", site.PrioritySoC)
-		}
+	if site.hasBatterySoC() {
+		site.publish("prioritySoC", site.PrioritySoC)
+	}
+
+	for i, meter := range site.consumerMeters {
+		site.log.INFO.Println(meterCapabilities(site.Meters.ConsumerMeterRefs[i], meter))
 	}
 
 	for i, lp := range site.loadpoints {
@@ -209,6 +282,12 @@ func (site *Site) publish(key string, val interface{}) {
 	}
 }
 
+// countReadError increments and publishes the meter read error counter
+func (site *Site) countReadError() {
+	site.readErrors++
+	site.publish("readErrors", site.readErrors)
+}
+
 // updateMeter updates and publishes single meter
 func (site *Site) updateMeter(name string, meter api.Meter, power *float64) error {
 	value, err := meter.CurrentPower()
@@ -221,61 +300,275 @@ func (site *Site) updateMeter(name string, meter api.Meter, power *float64) erro
 	site.log.DEBUG.Printf("%s power: %.0fW", name, *power)
 	site.publish(name+"Power", *power)
 
+	// energy is optional and not retried
+	if m, ok := meter.(api.MeterEnergy); ok {
+		if f, err := m.TotalEnergy(); err == nil {
+			if site.energyFailed[name] {
+				site.log.INFO.Printf("updating %s meter energy: recovered", name)
+				delete(site.energyFailed, name)
+			}
+
+			site.meterEnergy[name] = f
+			site.log.DEBUG.Printf("%s energy: %.3fkWh", name, f)
+			site.publish(name+"Energy", f)
+		} else {
+			delete(site.meterEnergy, name)
+
+			// log failures only once
+			logf := site.log.ERROR.Printf
+			if site.energyFailed[name] {
+				logf = site.log.DEBUG.Printf
+			}
+			site.energyFailed[name] = true
+
+			logf("updating %s meter energy: %v", name, err)
+		}
+	}
+
 	return nil
 }
 
-// updateMeter updates and publishes single meter
-func (site *Site) updateMeters() error {
-	retryMeter := func(s string, m api.Meter, f *float64) error {
-		if m == nil {
-			return nil
+// retryMeter updates and publishes single meter, retrying on error
+func (site *Site) retryMeter(name string, meter api.Meter, power *float64) error {
+	err := retry.Do(func() error {
+		return site.updateMeter(name, meter, power)
+	}, append(retryOptions, retry.RetryIf(func(err error) bool {
+		// outdated values don't recover by retrying
+		return !errors.Is(err, api.ErrOutdated)
+	}))...)
+
+	if err != nil {
+		err = fmt.Errorf("updating %s meter: %w", name, err)
+		site.log.ERROR.Println(err)
+		site.countReadError()
+	}
+
+	return err
+}
+
+// updateMeterGroup updates all meters of a kind and publishes their total power and energy.
+// Values of multiple meters are additionally published using the meters' references.
+// If a meter fails, its previous value is used for the total.
+func (site *Site) updateMeterGroup(kind string, refs []string, meters []api.Meter, total *float64) error {
+	switch len(meters) {
+	case 0:
+		return nil
+	case 1:
+		return site.retryMeter(kind, meters[0], total)
+	}
+
+	var err error
+	var power, energy float64
+	hasEnergy := true
+
+	for i, meter := range meters {
+		name := meterName(kind, refs, i)
+
+		value := site.meterPowers[name]
+		if e := site.retryMeter(name, meter, &value); e != nil {
+			err = e
 		}
 
-		err := retry.Do(func() error {
-			return site.updateMeter(s, m, f)
-		}, retryOptions...)
+		site.meterPowers[name] = value
+		power += value
+
+		e, ok := site.meterEnergy[name]
+		energy += e
+		hasEnergy = hasEnergy && ok
+	}
+
+	*total = power
 
+	site.log.DEBUG.Printf("%s power: %.0fW", kind, power)
+	site.publish(kind+"Power", power)
+
+	if hasEnergy {
+		site.meterEnergy[kind] = energy
+		site.publish(kind+"Energy", energy)
+	} else {
+		delete(site.meterEnergy, kind)
+	}
+
+	return err
+}
+
+// updateGridCurrents publishes the total grid currents of all grid meters providing currents.
+// Meters failing to provide currents are skipped.
+func (site *Site) updateGridCurrents() {
+	var currents []float64
+
+	for i, meter := range site.gridMeters {
+		phaseMeter, ok := meter.(api.MeterCurrent)
+		if !ok {
+			continue
+		}
+
+		i1, i2, i3, err := phaseMeter.Currents()
 		if err != nil {
-			err = fmt.Errorf("updating %s meter: %v", s, err)
-			site.log.ERROR.Println(err)
+			site.log.ERROR.Printf("%s currents: %v", meterName("grid", site.Meters.GridMeterRefs, i), err)
+			continue
 		}
 
-		return err
+		if currents == nil {
+			currents = make([]float64, 3)
+		}
+
+		for i, current := range []float64{i1, i2, i3} {
+			currents[i] += current
+		}
 	}
 
+	if currents != nil {
+		site.log.TRACE.Printf("grid currents: %.3gA", currents)
+		site.publish("gridCurrents", currents)
+	}
+}
+
+// updateMeters updates and publishes all site meters
+func (site *Site) updateMeters() error {
 	// pv meter is not critical for operation
-	_ = retryMeter("pv", site.pvMeter, &site.pvPower)
+	_ = site.updateMeterGroup("pv", site.Meters.PVMeterRefs, site.pvMeters, &site.pvPower)
 
-	err := retryMeter("grid", site.gridMeter, &site.gridPower)
+	err := site.updateMeterGroup("grid", site.Meters.GridMeterRefs, site.gridMeters, &site.gridPower)
 	if err == nil {
-		err = retryMeter("battery", site.batteryMeter, &site.batteryPower)
+		err = site.updateMeterGroup("battery", site.Meters.BatteryMeterRefs, site.batteryMeters, &site.batteryPower)
 	}
 
 	// currents
-	if phaseMeter, ok := site.gridMeter.(api.MeterCurrent); err == nil && ok {
-		i1, i2, i3, err := phaseMeter.Currents()
-		if err == nil {
-			site.log.TRACE.Printf("grid currents: %.3gA", []float64{i1, i2, i3})
-			site.publish("gridCurrents", []float64{i1, i2, i3})
-		}
+	if err == nil {
+		site.updateGridCurrents()
+	}
+
+	// household consumers are not critical for operation
+	for i, meter := range site.consumerMeters {
+		_ = site.retryMeter(site.Meters.ConsumerMeterRefs[i], meter, &site.consumerPowers[i])
 	}
 
 	// allow using PV as estimate for grid power
-	if site.gridMeter == nil {
-		site.gridPower = -site.pvPower
+	if len(site.gridMeters) == 0 {
+		site.gridPower = site.estimatedHomePower() + site.chargePower() - math.Abs(site.pvPower) - site.batteryPower
+	}
 
-		for _, lp := range site.loadpoints {
-			site.gridPower += lp.GetChargePower()
+	site.homePower = math.Max(0, consumedPower(site.pvPower, site.batteryPower, site.gridPower)-site.chargePower())
+	site.log.DEBUG.Printf("home power: %.0fW", site.homePower)
+	site.publish("homePower", site.homePower)
+
+	return err
+}
+
+// chargePower returns the total charge power of all loadpoints
+func (site *Site) chargePower() float64 {
+	var res float64
+	for _, lp := range site.loadpoints {
+		res += lp.GetChargePower()
+	}
+	return res
+}
+
+// estimatedHomePower estimates household consumption without grid meter
+// as residual power plus the measured household consumers
+func (site *Site) estimatedHomePower() float64 {
+	res := site.ResidualPower
+	for _, power := range site.consumerPowers {
+		res += power
+	}
+	return res
+}
+
+// hasBatterySoC checks if any battery provides its soc
+func (site *Site) hasBatterySoC() bool {
+	for _, meter := range site.batteryMeters {
+		if _, ok := meter.(api.Battery); ok {
+			return true
 		}
 	}
+	return false
+}
 
-	return err
+// batterySoC returns the capacity-weighted soc of all batteries providing their soc.
+// If any capacity is unknown, all batteries are weighted equally.
+func (site *Site) batterySoC() (float64, error) {
+	var socs, capacities []float64
+	weighted := true
+
+	for i, meter := range site.batteryMeters {
+		battery, ok := meter.(api.Battery)
+		if !ok {
+			continue
+		}
+
+		soc, err := battery.SoC()
+		if err != nil {
+			return 0, err
+		}
+
+		if len(site.batteryMeters) > 1 {
+			site.publish(meterName("battery", site.Meters.BatteryMeterRefs, i)+"SoC", math.Trunc(soc))
+		}
+
+		var capacity float64
+		if c, ok := meter.(api.BatteryCapacity); ok {
+			capacity = c.Capacity()
+		}
+
+		weighted = weighted && capacity > 0
+
+		socs = append(socs, soc)
+		capacities = append(capacities, capacity)
+	}
+
+	var sum, total float64
+	for i, soc := range socs {
+		weight := 1.0
+		if weighted {
+			weight = capacities[i]
+		}
+
+		sum += soc * weight
+		total += weight
+	}
+
+	if total == 0 {
+		return 0, errors.New("no battery soc")
+	}
+
+	return sum / total, nil
 }
 
-// consumedPower estimates how much power the charger might have consumed given it was the only load
-// func (site *Site) consumedPower() float64 {
-// 	return consumedPower(lp.pvPower, lp.batteryPower, lp.gridPower)
-// }
+// residualPower returns the static or adaptive household safety margin. Without grid meter
+// the residual power is household usage and already included in the estimated grid power.
+func (site *Site) residualPower() float64 {
+	if len(site.gridMeters) == 0 {
+		return 0
+	}
+
+	if site.AdaptiveResidual.Enable {
+		return site.baseload.residualPower(site.ResidualPower, site.AdaptiveResidual)
+	}
+
+	return site.ResidualPower
+}
+
+// updateBaseload learns the measured household consumption and publishes the current baseload
+func (site *Site) updateBaseload() {
+	// household consumption is only measured with grid meter
+	if len(site.gridMeters) == 0 {
+		return
+	}
+
+	site.baseload.add(site.homePower)
+
+	if slot, ok := site.baseload.Current(); ok {
+		site.publish("baseloadPower", slot.Mean)
+		site.publish("baseloadStdDev", slot.StdDev)
+	}
+
+	if site.baseload.persistDue() {
+		if err := site.baseload.Persist(); err != nil {
+			site.log.ERROR.Printf("baseload: %v", err)
+		}
+	}
+}
 
 // sitePower returns the net power exported by the site minus a residual margin.
 // negative values mean grid: export, battery: charging
@@ -286,10 +579,11 @@ func (site *Site) sitePower() (float64, error) {
 
 	// honour battery priority
 	batteryPower := site.batteryPower
-	if battery, ok := site.batteryMeter.(api.Battery); ok {
-		soc, err := battery.SoC()
+	if site.hasBatterySoC() {
+		soc, err := site.batterySoC()
 		if err != nil {
 			site.log.ERROR.Printf("updating battery soc: %v", err)
+			site.countReadError()
 		} else {
 			site.log.DEBUG.Printf("battery soc: %.0f%%", soc)
 			site.publish("batterySoC", math.Trunc(soc))
@@ -305,18 +599,88 @@ func (site *Site) sitePower() (float64, error) {
 		}
 	}
 
-	sitePower := sitePower(site.gridPower, batteryPower, site.ResidualPower)
+	residualPower := site.residualPower()
+	site.log.DEBUG.Printf("residual power: %.0fW", residualPower)
+	site.publish("residualPower", residualPower)
+
+	sitePower := sitePower(site.gridPower, batteryPower, residualPower)
 	site.log.DEBUG.Printf("site power: %.0fW", sitePower)
 
 	return sitePower, nil
 }
 
+// meterReading returns the total power and energy of the named site meters
+func (site *Site) meterReading(name string, meters []api.Meter, power float64) *energyReading {
+	if len(meters) == 0 {
+		return nil
+	}
+
+	energy, ok := site.meterEnergy[name]
+	return &energyReading{power: power, energy: energy, hasEnergy: ok}
+}
+
+// updateStatistics adds the current meter readings to the energy statistics and publishes today's totals
+func (site *Site) updateStatistics() {
+	loadpoints := make([]energyReading, len(site.loadpoints))
+	for i, lp := range site.loadpoints {
+		loadpoints[i] = energyReading{
+			power:     lp.GetChargePower(),
+			energy:    lp.chargeTotalEnergy,
+			hasEnergy: lp.chargeTotalEnergy > 0,
+		}
+	}
+
+	// grid power is estimated if there is no grid meter
+	grid := &energyReading{power: site.gridPower}
+	if len(site.gridMeters) > 0 {
+		grid = site.meterReading("grid", site.gridMeters, site.gridPower)
+	}
+
+	site.stats.update(
+		site.meterReading("pv", site.pvMeters, site.pvPower),
+		grid,
+		site.meterReading("battery", site.batteryMeters, site.batteryPower),
+		loadpoints,
+	)
+
+	today := site.stats.Today()
+	site.publish("pvEnergyToday", today.PV)
+	site.publish("gridImportEnergyToday", today.GridImport)
+	site.publish("gridExportEnergyToday", today.GridExport)
+	site.publish("batteryChargeEnergyToday", today.BatteryCharge)
+	site.publish("batteryDischargeEnergyToday", today.BatteryDischarge)
+	site.publish("homeEnergyToday", today.Home)
+	site.publish("selfConsumptionToday", today.SelfConsumption)
+	site.publish("autarkyToday", today.Autarky)
+
+	for i, lp := range site.loadpoints {
+		if i < len(today.LoadPoints) {
+			lp.publish("chargeEnergyToday", today.LoadPoints[i])
+		}
+	}
+
+	if site.stats.persistDue() {
+		if err := site.stats.Persist(); err != nil {
+			site.log.ERROR.Printf("statistics: %v", err)
+		}
+	}
+}
+
 func (site *Site) update(lp Updater) {
 	site.log.DEBUG.Println("----")
 
-	if sitePower, err := site.sitePower(); err == nil {
+	sitePower, err := site.sitePower()
+	if err == nil {
+		site.updateStatistics()
+		site.updateBaseload()
 		lp.Update(sitePower)
 		site.Health.Update()
+		return
+	}
+
+	// loadpoints are not updated with outdated meter values
+	if errors.Is(err, api.ErrOutdated) {
+		site.Health.Fail()
 	}
 }
 
@@ -2,14 +2,13 @@ package core
 
 import (
 	"errors"
-
-	"github.com/andig/evcc/api"
 )
 
 // SiteAPI is the external site API
 type SiteAPI interface {
 	Healthy() bool
 	LoadPoints() []LoadPointAPI
+	GetPrioritySoC() float64
 	SetPrioritySoC(float64) error
 }
 
@@ -25,7 +24,7 @@ func (site *Site) SetPrioritySoC(soc float64) error {
 	site.Lock()
 	defer site.Unlock()
 
-	if _, ok := site.batteryMeter.(api.Battery); !ok {
+	if !site.hasBatterySoC() {
 		return errors.New("battery not configured")
 	}
 
@@ -1,7 +1,15 @@
 package core
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
 	"testing"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/mock"
+	"github.com/andig/evcc/util"
+	"github.com/golang/mock/gomock"
 )
 
 func TestSiteApi(t *testing.T) {
@@ -30,3 +38,236 @@ func TestSitePower(t *testing.T) {
 }
 
 // TODO add test case for battery priority charging
+
+func TestHomePower(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	meter := func(power float64) api.Meter {
+		m := mock.NewMockMeter(ctrl)
+		m.EXPECT().CurrentPower().Return(power, nil).AnyTimes()
+		return m
+	}
+
+	// loadpoint is charging with 1kW
+	tc := []struct {
+		gridMeter                  bool
+		grid, pv, battery          float64
+		residual                   float64
+		consumers                  []float64
+		expectedGrid, expectedHome float64
+	}{
+		{gridMeter: true, expectedHome: 0},
+		{gridMeter: true, grid: -1000, pv: 3000, battery: -500, residual: 300, expectedGrid: -1000, expectedHome: 500},
+		{pv: 3000, residual: 300, consumers: []float64{200}, expectedGrid: -1500, expectedHome: 500}, // estimated from pv
+		{pv: -3000, battery: 1000, residual: 300, expectedGrid: -2700, expectedHome: 300},            // estimated from pv
+	}
+
+	for _, tc := range tc {
+		t.Logf("%+v", tc)
+
+		site := NewSite()
+		site.ResidualPower = tc.residual
+		site.pvMeters = []api.Meter{meter(tc.pv)}
+		site.batteryMeters = []api.Meter{meter(tc.battery)}
+		site.loadpoints = []*LoadPoint{{chargePower: 1000}}
+
+		if tc.gridMeter {
+			site.gridMeters = []api.Meter{meter(tc.grid)}
+		}
+
+		for _, power := range tc.consumers {
+			site.consumerMeters = append(site.consumerMeters, meter(power))
+			site.Meters.ConsumerMeterRefs = append(site.Meters.ConsumerMeterRefs, "heatpump")
+		}
+		site.consumerPowers = make([]float64, len(site.consumerMeters))
+
+		if err := site.updateMeters(); err != nil {
+			t.Error(err)
+		}
+
+		if site.gridPower != tc.expectedGrid {
+			t.Errorf("grid power: expected %.0f, got %.0f", tc.expectedGrid, site.gridPower)
+		}
+
+		if site.homePower != tc.expectedHome {
+			t.Errorf("home power: expected %.0f, got %.0f", tc.expectedHome, site.homePower)
+		}
+	}
+}
+
+type testConfigProvider map[string]api.Meter
+
+func (cp testConfigProvider) Meter(name string) api.Meter     { return cp[name] }
+func (cp testConfigProvider) Charger(name string) api.Charger { return nil }
+func (cp testConfigProvider) Vehicle(name string) api.Vehicle { return nil }
+
+type testMeter struct {
+	power, energy float64
+}
+
+func (m *testMeter) CurrentPower() (float64, error) { return m.power, nil }
+func (m *testMeter) TotalEnergy() (float64, error)  { return m.energy, nil }
+
+type testBattery struct {
+	testMeter
+	soc, capacity float64
+}
+
+func (m *testBattery) SoC() (float64, error) { return m.soc, nil }
+func (m *testBattery) Capacity() float64     { return m.capacity }
+
+func TestSiteMetersConfig(t *testing.T) {
+	cp := testConfigProvider{
+		"grid":    &testMeter{},
+		"pv":      &testMeter{},
+		"roof":    &testMeter{},
+		"carport": &testMeter{},
+	}
+
+	tc := []struct {
+		meters    map[string]interface{}
+		pv        int
+		expectErr bool
+	}{
+		{map[string]interface{}{"grid": "grid", "pv": "pv"}, 1, false},
+		{map[string]interface{}{"grid": "grid", "pv": []string{"roof", "carport"}}, 2, false},
+		{map[string]interface{}{"grid": "grid", "pv": []string{"pv", "carport"}}, 0, true}, // name collides with total
+		{map[string]interface{}{"pv": []string{"roof"}}, 1, false},
+		{map[string]interface{}{}, 0, true},
+	}
+
+	for _, tc := range tc {
+		site, err := NewSiteFromConfig(util.NewLogger("foo"), cp, map[string]interface{}{"meters": tc.meters}, nil)
+
+		if (err != nil) != tc.expectErr {
+			t.Errorf("%v: unexpected error %v", tc.meters, err)
+		}
+
+		if err == nil && len(site.pvMeters) != tc.pv {
+			t.Errorf("%v: expected %d pv meters, got %d", tc.meters, tc.pv, len(site.pvMeters))
+		}
+	}
+}
+
+func TestSiteMultipleMeters(t *testing.T) {
+	site := NewSite()
+	site.Meters.PVMeterRefs = []string{"roof", "carport"}
+	site.pvMeters = []api.Meter{&testMeter{power: 1000, energy: 10}, &testMeter{power: 2000, energy: 20}}
+	site.Meters.GridMeterRefs = []string{"grid"}
+	site.gridMeters = []api.Meter{&testMeter{power: -1000, energy: 5}}
+
+	if err := site.updateMeters(); err != nil {
+		t.Fatal(err)
+	}
+
+	if site.pvPower != 3000 || site.meterPowers["roof"] != 1000 || site.meterPowers["carport"] != 2000 {
+		t.Errorf("unexpected pv power: %.0f %v", site.pvPower, site.meterPowers)
+	}
+
+	if site.meterEnergy["pv"] != 30 || site.meterEnergy["grid"] != 5 {
+		t.Errorf("unexpected energy: %v", site.meterEnergy)
+	}
+
+	if site.homePower != 2000 {
+		t.Errorf("unexpected home power: %.0f", site.homePower)
+	}
+}
+
+type testPhaseMeter struct {
+	testMeter
+	currents []float64
+	err      error
+}
+
+func (m *testPhaseMeter) Currents() (float64, float64, float64, error) {
+	if m.err != nil {
+		return 0, 0, 0, m.err
+	}
+	return m.currents[0], m.currents[1], m.currents[2], nil
+}
+
+func TestSiteGridCurrents(t *testing.T) {
+	uiChan := make(chan util.Param, 1)
+
+	site := NewSite()
+	site.uiChan = uiChan
+	site.Meters.GridMeterRefs = []string{"main", "garage", "shed"}
+	site.gridMeters = []api.Meter{
+		&testPhaseMeter{currents: []float64{1, 2, 3}},
+		&testPhaseMeter{err: errors.New("timeout")},
+		&testPhaseMeter{currents: []float64{1, 1, 1}},
+	}
+
+	// failing meter is skipped
+	site.updateGridCurrents()
+
+	p := <-uiChan
+	if p.Key != "gridCurrents" || !reflect.DeepEqual(p.Val, []float64{2, 3, 4}) {
+		t.Errorf("unexpected currents: %+v", p)
+	}
+}
+
+type testOutdatedMeter struct {
+	calls int
+}
+
+func (m *testOutdatedMeter) CurrentPower() (float64, error) {
+	m.calls++
+	return 0, fmt.Errorf("%w: 1m0s", api.ErrOutdated)
+}
+
+type testUpdater struct {
+	updated bool
+}
+
+func (lp *testUpdater) Update(float64) { lp.updated = true }
+
+func TestSiteOutdatedMeter(t *testing.T) {
+	meter := new(testOutdatedMeter)
+
+	site := NewSite()
+	site.gridMeters = []api.Meter{meter}
+
+	site.Health.Update()
+
+	lp := new(testUpdater)
+	site.update(lp)
+
+	if lp.updated {
+		t.Error("loadpoint updated with outdated meter")
+	}
+
+	if site.Healthy() {
+		t.Error("expected unhealthy site")
+	}
+
+	if meter.calls != 1 {
+		t.Errorf("outdated meter retried %d times", meter.calls)
+	}
+}
+
+func TestSiteBatterySoC(t *testing.T) {
+	tc := []struct {
+		batteries []api.Meter
+		soc       float64
+	}{
+		{[]api.Meter{&testBattery{soc: 50, capacity: 10}}, 50},
+		{[]api.Meter{&testBattery{soc: 50, capacity: 10}, &testBattery{soc: 80, capacity: 5}}, 60},
+		{[]api.Meter{&testBattery{soc: 50, capacity: 10}, &testBattery{soc: 80}}, 65}, // unknown capacity
+		{[]api.Meter{&testBattery{soc: 50, capacity: 10}, &testMeter{}}, 50},          // meter without soc
+	}
+
+	for _, tc := range tc {
+		site := NewSite()
+		site.batteryMeters = tc.batteries
+
+		soc, err := site.batterySoC()
+		if err != nil {
+			t.Error(err)
+		}
+
+		if soc != tc.soc {
+			t.Errorf("expected soc %.0f, got %.0f", tc.soc, soc)
+		}
+	}
+}
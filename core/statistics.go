@@ -0,0 +1,329 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/andig/evcc/util"
+	"github.com/benbjohnson/clock"
+)
+
+const (
+	statsDayFormat   = "2006-01-02"
+	statsMonthFormat = "2006-01"
+	statsRetention   = 400              // days
+	statsMaxGap      = 5 * time.Minute  // power is not integrated across longer gaps
+	statsPersist     = 15 * time.Minute // persistence interval
+)
+
+// EnergyTotals are the energy flows of a period in kWh
+type EnergyTotals struct {
+	PV               float64   `json:"pv"`
+	GridImport       float64   `json:"gridImport"`
+	GridExport       float64   `json:"gridExport"`
+	BatteryCharge    float64   `json:"batteryCharge"`
+	BatteryDischarge float64   `json:"batteryDischarge"`
+	Home             float64   `json:"home"`            // household consumption excluding loadpoints
+	LoadPoints       []float64 `json:"loadpoints"`      // charged energy per loadpoint
+	SelfConsumption  float64   `json:"selfConsumption"` // share of pv energy consumed on site in %
+	Autarky          float64   `json:"autarky"`         // share of consumption not imported from grid in %
+}
+
+// add adds the delta energies and updates the ratios
+func (t *EnergyTotals) add(d EnergyTotals) {
+	t.PV += d.PV
+	t.GridImport += d.GridImport
+	t.GridExport += d.GridExport
+	t.BatteryCharge += d.BatteryCharge
+	t.BatteryDischarge += d.BatteryDischarge
+	t.Home += d.Home
+
+	for len(t.LoadPoints) < len(d.LoadPoints) {
+		t.LoadPoints = append(t.LoadPoints, 0)
+	}
+	for i, e := range d.LoadPoints {
+		t.LoadPoints[i] += e
+	}
+
+	t.SelfConsumption = 0
+	if t.PV > 0 {
+		t.SelfConsumption = ratio(t.PV-t.GridExport, t.PV)
+	}
+
+	t.Autarky = 0
+	if consumption := t.PV - t.GridExport + t.GridImport + t.BatteryDischarge - t.BatteryCharge; consumption > 0 {
+		t.Autarky = ratio(consumption-t.GridImport, consumption)
+	}
+}
+
+// ratio returns the share of a in b in percent limited to 0..100
+func ratio(a, b float64) float64 {
+	return math.Min(100, math.Max(0, 100*a/b))
+}
+
+// energyReading is a meter's current power and optional total energy in kWh
+type energyReading struct {
+	power     float64
+	energy    float64
+	hasEnergy bool
+}
+
+// energyCounter converts meter readings into energy deltas
+type energyCounter struct {
+	updated time.Time
+	power   float64
+	energy  float64
+	valid   bool // energy is valid
+}
+
+// integrate returns the positive and negative energy in kWh of the previous
+// power since the last update
+func (c *energyCounter) integrate(now time.Time, power float64) (float64, float64) {
+	var pos, neg float64
+
+	if elapsed := now.Sub(c.updated); !c.updated.IsZero() && elapsed > 0 && elapsed <= statsMaxGap {
+		e := c.power * elapsed.Hours() / 1e3
+		if e > 0 {
+			pos = e
+		} else {
+			neg = -e
+		}
+	}
+
+	c.updated = now
+	c.power = power
+
+	return pos, neg
+}
+
+// delta returns the difference of the total energy since the last update.
+// Counter resets are ignored.
+func (c *energyCounter) delta(energy float64) float64 {
+	var res float64
+	if c.valid && energy >= c.energy {
+		res = energy - c.energy
+	}
+
+	c.energy = energy
+	c.valid = true
+
+	return res
+}
+
+// statisticsJSON is the persisted and published statistics
+type statisticsJSON struct {
+	Days   map[string]*EnergyTotals `json:"days"`
+	Months map[string]*EnergyTotals `json:"months"`
+}
+
+// Statistics aggregates daily and monthly energy flows
+type Statistics struct {
+	mu        sync.Mutex
+	clock     clock.Clock
+	file      string
+	persisted time.Time
+
+	days   map[string]*EnergyTotals
+	months map[string]*EnergyTotals
+
+	pv, grid, battery energyCounter
+	loadpoints        []energyCounter
+}
+
+// NewStatistics creates energy statistics. If file is given, statistics are
+// loaded from and periodically stored to file.
+func NewStatistics(file string) (*Statistics, error) {
+	s := &Statistics{
+		clock:  clock.New(),
+		file:   file,
+		days:   make(map[string]*EnergyTotals),
+		months: make(map[string]*EnergyTotals),
+	}
+
+	if file != "" {
+		if err := s.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return s, err
+		}
+	}
+
+	return s, nil
+}
+
+// update converts the readings into energy and adds it to the current day and month.
+// Energy counters are preferred over integrating power except for bidirectional
+// battery flows and grid export.
+func (s *Statistics) update(pv, grid, battery *energyReading, loadpoints []energyReading) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+
+	var d EnergyTotals
+
+	if pv != nil {
+		pos, neg := s.pv.integrate(now, pv.power)
+		d.PV = pos + neg // pv meters may report negative power
+
+		if pv.hasEnergy {
+			d.PV = s.pv.delta(pv.energy)
+		}
+	}
+
+	if grid != nil {
+		d.GridImport, d.GridExport = s.grid.integrate(now, grid.power)
+
+		if grid.hasEnergy {
+			d.GridImport = s.grid.delta(grid.energy)
+		}
+	}
+
+	if battery != nil {
+		d.BatteryDischarge, d.BatteryCharge = s.battery.integrate(now, battery.power)
+	}
+
+	for len(s.loadpoints) < len(loadpoints) {
+		s.loadpoints = append(s.loadpoints, energyCounter{})
+	}
+
+	d.LoadPoints = make([]float64, len(loadpoints))
+
+	var charged float64
+	for i, lp := range loadpoints {
+		d.LoadPoints[i], _ = s.loadpoints[i].integrate(now, lp.power)
+
+		if lp.hasEnergy {
+			d.LoadPoints[i] = s.loadpoints[i].delta(lp.energy)
+		}
+
+		charged += d.LoadPoints[i]
+	}
+
+	// household consumption is the remainder of all flows
+	d.Home = math.Max(0, d.PV+d.GridImport-d.GridExport+d.BatteryDischarge-d.BatteryCharge-charged)
+
+	s.total(s.days, now.Format(statsDayFormat)).add(d)
+	s.total(s.months, now.Format(statsMonthFormat)).add(d)
+}
+
+// total returns the totals of the period, creating them if required
+func (s *Statistics) total(totals map[string]*EnergyTotals, period string) *EnergyTotals {
+	t, ok := totals[period]
+	if !ok {
+		t = new(EnergyTotals)
+		totals[period] = t
+	}
+	return t
+}
+
+// Today returns today's totals
+func (s *Statistics) Today() EnergyTotals {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.days[s.clock.Now().Format(statsDayFormat)]; ok {
+		res := *t
+		res.LoadPoints = append([]float64{}, t.LoadPoints...)
+		return res
+	}
+
+	return EnergyTotals{}
+}
+
+// Days returns the daily totals keyed by date
+func (s *Statistics) Days() map[string]EnergyTotals {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyTotals(s.days)
+}
+
+// Months returns the monthly totals keyed by month
+func (s *Statistics) Months() map[string]EnergyTotals {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyTotals(s.months)
+}
+
+func copyTotals(totals map[string]*EnergyTotals) map[string]EnergyTotals {
+	res := make(map[string]EnergyTotals, len(totals))
+	for k, t := range totals {
+		c := *t
+		c.LoadPoints = append([]float64{}, t.LoadPoints...)
+		res[k] = c
+	}
+	return res
+}
+
+// prune removes daily totals older than the retention period
+func (s *Statistics) prune() {
+	var days []string
+	for day := range s.days {
+		days = append(days, day)
+	}
+
+	if len(days) <= statsRetention {
+		return
+	}
+
+	sort.Strings(days)
+	for _, day := range days[:len(days)-statsRetention] {
+		delete(s.days, day)
+	}
+}
+
+// persistDue returns true if the persistence interval has elapsed
+func (s *Statistics) persistDue() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file != "" && s.clock.Since(s.persisted) >= statsPersist
+}
+
+// Persist stores the statistics to file
+func (s *Statistics) Persist() error {
+	if s.file == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.prune()
+	s.persisted = s.clock.Now()
+	b, err := json.Marshal(statisticsJSON{Days: s.days, Months: s.months})
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFileAtomic(s.file, b, 0600)
+}
+
+// load restores the statistics from file
+func (s *Statistics) load() error {
+	b, err := os.ReadFile(s.file)
+	if err != nil {
+		return err
+	}
+
+	var data statisticsJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, t := range data.Days {
+		s.days[k] = t
+	}
+	for k, t := range data.Months {
+		s.months[k] = t
+	}
+
+	s.persisted = s.clock.Now()
+
+	return nil
+}
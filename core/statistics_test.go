@@ -0,0 +1,160 @@
+package core
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+func newTestStatistics(t *testing.T, file string) (*Statistics, *clock.Mock) {
+	s, err := NewStatistics(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clck := clock.NewMock()
+	clck.Set(time.Date(2021, 4, 30, 12, 0, 0, 0, time.Local))
+	s.clock = clck
+
+	return s, clck
+}
+
+func equal(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestStatisticsIntegratePower(t *testing.T) {
+	s, clck := newTestStatistics(t, "")
+
+	// 1h of 3kW pv with 1kW export, 1kW battery charging and 0.5kW charging
+	for i := 0; i <= 60; i++ {
+		s.update(
+			&energyReading{power: 3000},
+			&energyReading{power: -1000},
+			&energyReading{power: -1000},
+			[]energyReading{{power: 500}},
+		)
+		clck.Add(time.Minute)
+	}
+
+	res := s.Days()["2021-04-30"]
+
+	tc := []struct {
+		name       string
+		got, wants float64
+	}{
+		{"pv", res.PV, 3},
+		{"gridImport", res.GridImport, 0},
+		{"gridExport", res.GridExport, 1},
+		{"batteryCharge", res.BatteryCharge, 1},
+		{"batteryDischarge", res.BatteryDischarge, 0},
+		{"loadpoint", res.LoadPoints[0], 0.5},
+		{"home", res.Home, 0.5},
+		{"selfConsumption", res.SelfConsumption, 100 * 2 / 3.0},
+		{"autarky", res.Autarky, 100},
+	}
+
+	for _, tc := range tc {
+		if !equal(tc.got, tc.wants) {
+			t.Errorf("%s: expected %.3f, got %.3f", tc.name, tc.wants, tc.got)
+		}
+	}
+
+	if m := s.Months()["2021-04"]; !equal(m.PV, res.PV) {
+		t.Errorf("month: expected %.3f, got %.3f", res.PV, m.PV)
+	}
+}
+
+func TestStatisticsEnergyCounter(t *testing.T) {
+	s, clck := newTestStatistics(t, "")
+
+	tc := []struct {
+		pv, grid float64
+	}{
+		{100, 1000},
+		{101, 1000.5},
+		{100, 1001}, // pv counter reset
+		{102, 1002},
+	}
+
+	for _, tc := range tc {
+		s.update(
+			&energyReading{power: 1000, energy: tc.pv, hasEnergy: true},
+			&energyReading{power: 1000, energy: tc.grid, hasEnergy: true},
+			nil, nil,
+		)
+		clck.Add(time.Second)
+	}
+
+	res := s.Today()
+	if !equal(res.PV, 3) {
+		t.Errorf("pv: expected 3, got %.3f", res.PV)
+	}
+	if !equal(res.GridImport, 2) {
+		t.Errorf("grid: expected 2, got %.3f", res.GridImport)
+	}
+	if !equal(res.Autarky, 60) {
+		t.Errorf("autarky: expected 60, got %.3f", res.Autarky)
+	}
+}
+
+func TestStatisticsPeriods(t *testing.T) {
+	s, clck := newTestStatistics(t, "")
+	clck.Set(time.Date(2021, 4, 30, 22, 59, 0, 0, time.Local))
+
+	// 2h across midnight and end of month
+	for i := 0; i <= 120; i++ {
+		s.update(nil, &energyReading{power: 600}, nil, nil)
+		clck.Add(time.Minute)
+	}
+
+	// long gaps are not integrated
+	clck.Add(time.Hour)
+	s.update(nil, &energyReading{power: 600}, nil, nil)
+
+	if res := s.Days()["2021-04-30"].GridImport; !equal(res, 0.6) {
+		t.Errorf("day 1: expected 0.6, got %.3f", res)
+	}
+	if res := s.Days()["2021-05-01"].GridImport; !equal(res, 0.6) {
+		t.Errorf("day 2: expected 0.6, got %.3f", res)
+	}
+	if res := s.Months()["2021-05"].GridImport; !equal(res, 0.6) {
+		t.Errorf("month: expected 0.6, got %.3f", res)
+	}
+	if res := s.Today().GridImport; !equal(res, 0.6) {
+		t.Errorf("today: expected 0.6, got %.3f", res)
+	}
+}
+
+func TestStatisticsPersist(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "evcc", "statistics.json") // data directory is created on save
+
+	s, clck := newTestStatistics(t, file)
+	for i := 0; i <= 60; i++ {
+		s.update(&energyReading{power: 1000}, nil, nil, nil)
+		clck.Add(time.Minute)
+	}
+
+	if !s.persistDue() {
+		t.Error("expected persistence due")
+	}
+
+	if err := s.Persist(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.persistDue() {
+		t.Error("unexpected persistence due")
+	}
+
+	restored, _ := newTestStatistics(t, file)
+	if res := restored.Days()["2021-04-30"].PV; !equal(res, 1) {
+		t.Errorf("expected 1, got %.3f", res)
+	}
+	if res := restored.Months()["2021-04"].PV; !equal(res, 1) {
+		t.Errorf("expected 1, got %.3f", res)
+	}
+}
@@ -6,6 +6,9 @@ require (
 	github.com/PuerkitoBio/goquery v1.7.1
 	github.com/andig/evcc-config v0.0.0-20210516083211-8b5c1c7bd5b0
 	github.com/andig/gosunspec v0.0.0-20210511114617-aa30cf9b7a3f // indirect
+	github.com/antchfx/htmlquery v1.2.3
+	github.com/antchfx/xmlquery v1.3.6
+	github.com/antchfx/xpath v1.2.0
 	github.com/asaskevich/EventBus v0.0.0-20200907212545-49d423059eef
 	github.com/avast/retry-go v3.0.0+incompatible
 	github.com/benbjohnson/clock v1.1.0
@@ -19,6 +22,7 @@ require (
 	github.com/fatih/color v1.12.0 // indirect
 	github.com/fatih/structs v1.1.0
 	github.com/felixge/httpsnoop v1.0.2 // indirect
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/go-ping/ping v0.0.0-20210506233800-ff8be3320020
 	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible
 	github.com/godbus/dbus/v5 v5.0.4
@@ -32,6 +36,7 @@ require (
 	github.com/gorilla/websocket v1.4.2
 	github.com/gregdel/pushover v1.1.0
 	github.com/grid-x/modbus v0.0.0-20210224155242-c4a3d042e99b
+	github.com/grid-x/serial v0.0.0-20191104121038-e24bc9bf6f08
 	github.com/hashicorp/go-version v1.3.0
 	github.com/imdario/mergo v0.3.12
 	github.com/influxdata/influxdb-client-go/v2 v2.4.0
@@ -68,7 +73,7 @@ require (
 	github.com/tv42/httpunix v0.0.0-20191220191345-2ba4b9c3382c
 	github.com/volkszaehler/mbmd v0.0.0-20210526131012-e1fec7232ed7
 	gitlab.com/bboehmke/sunny v0.14.2
-	golang.org/x/crypto v0.0.0-20210513164829-c07d793c2f9a // indirect
+	golang.org/x/crypto v0.0.0-20210513164829-c07d793c2f9a
 	golang.org/x/net v0.0.0-20210614182718-04defd469f4e
 	golang.org/x/oauth2 v0.0.0-20210615190721-d04028783cf1
 	golang.org/x/sys v0.0.0-20210616045830-e2b7044e8c71 // indirect
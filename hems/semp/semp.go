@@ -91,7 +91,7 @@ func New(conf map[string]interface{}, site core.SiteAPI, cache *util.Cache, http
 
 	s.hostURI = s.callbackURI()
 
-	s.handlers(httpd.Router())
+	s.handlers(httpd.Router(), httpd.Authorize(server.RoleControl))
 
 	return s, err
 }
@@ -176,7 +176,7 @@ func (s *SEMP) callbackURI() string {
 	return uri
 }
 
-func (s *SEMP) handlers(router *mux.Router) {
+func (s *SEMP) handlers(router *mux.Router, control mux.MiddlewareFunc) {
 	sempRouter := router.PathPrefix(basePath).Subrouter()
 	getRouter := sempRouter.Methods(http.MethodGet).Subrouter()
 
@@ -189,6 +189,7 @@ func (s *SEMP) handlers(router *mux.Router) {
 
 	// post control messages
 	postRouter := sempRouter.Methods(http.MethodPost).Subrouter()
+	postRouter.Use(control)
 	postRouter.HandleFunc("/", s.deviceControlHandler)
 }
 
@@ -14,7 +14,7 @@ func init() {
 	registry.Add(api.Custom, NewConfigurableFromConfig)
 }
 
-//go:generate go run ../cmd/tools/decorate.go -f decorateMeter -b api.Meter -t "api.MeterEnergy,TotalEnergy,func() (float64, error)" -t "api.MeterCurrent,Currents,func() (float64, float64, float64, error)" -t "api.Battery,SoC,func() (float64, error)"
+//go:generate go run ../cmd/tools/decorate.go -f decorateMeter -b api.Meter -t "api.MeterEnergy,TotalEnergy,func() (float64, error)" -t "api.MeterCurrent,Currents,func() (float64, float64, float64, error)" -t "api.Battery,SoC,func() (float64, error)" -t "api.BatteryCapacity,Capacity,func() float64"
 
 // NewConfigurableFromConfig creates api.Meter from config
 func NewConfigurableFromConfig(other map[string]interface{}) (api.Meter, error) {
@@ -22,6 +22,7 @@ func NewConfigurableFromConfig(other map[string]interface{}) (api.Meter, error)
 		Power    provider.Config
 		Energy   *provider.Config  // optional
 		SoC      *provider.Config  // optional
+		Capacity float64           // optional battery capacity in kWh
 		Currents []provider.Config // optional
 	}{}
 
@@ -74,6 +75,15 @@ func NewConfigurableFromConfig(other map[string]interface{}) (api.Meter, error)
 		}
 	}
 
+	// decorate battery with BatteryCapacity
+	if cc.Capacity > 0 {
+		if cc.SoC == nil {
+			return nil, errors.New("capacity requires battery soc")
+		}
+
+		m.capacity = cc.Capacity
+	}
+
 	res := m.Decorate(m.totalEnergyG, m.currentsG, m.batterySoCG)
 
 	return res, nil
@@ -93,6 +103,7 @@ type Meter struct {
 	totalEnergyG  func() (float64, error)
 	currentsG     []func() (float64, error)
 	batterySoCG   func() (float64, error)
+	capacity      float64
 }
 
 // Decorate attaches additional capabilities to the base meter
@@ -119,7 +130,12 @@ func (m *Meter) Decorate(
 		batterySoC = m.batterySoC
 	}
 
-	return decorateMeter(m, totalEnergy, currents, batterySoC)
+	var capacity func() float64
+	if m.capacity > 0 {
+		capacity = m.batteryCapacity
+	}
+
+	return decorateMeter(m, totalEnergy, currents, batterySoC, capacity)
 }
 
 // CurrentPower implements the api.Meter interface
@@ -151,3 +167,8 @@ func (m *Meter) currents() (float64, float64, float64, error) {
 func (m *Meter) batterySoC() (float64, error) {
 	return m.batterySoCG()
 }
+
+// batteryCapacity implements the api.BatteryCapacity interface
+func (m *Meter) batteryCapacity() float64 {
+	return m.capacity
+}
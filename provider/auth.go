@@ -0,0 +1,156 @@
+package provider
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/andig/evcc/util"
+	"github.com/andig/evcc/util/oauth"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// Auth is the authorization config
+type Auth struct {
+	Type, User, Password string
+	Token                string   // bearer
+	TokenURL             string   // oauth2
+	ClientID             string   // oauth2
+	ClientSecret         string   // oauth2
+	Scopes               []string // oauth2
+	RefreshToken         string   // oauth2
+}
+
+// AuthHeaders creates authorization headers from config
+func AuthHeaders(log *util.Logger, auth Auth, headers map[string]string) error {
+	switch strings.ToLower(auth.Type) {
+	case "basic":
+		basicAuth := auth.User + ":" + auth.Password
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(basicAuth))
+
+	case "bearer":
+		if auth.Token == "" {
+			return errors.New("missing token")
+		}
+		headers["Authorization"] = "Bearer " + auth.Token
+
+	default:
+		return fmt.Errorf("unsupported auth type: %s", auth.Type)
+	}
+
+	return nil
+}
+
+// oauthRefresher obtains tokens using the refresh token or client credentials grant
+type oauthRefresher struct {
+	log    *util.Logger
+	ctx    context.Context
+	config oauth2.Config
+	creds  *clientcredentials.Config
+}
+
+// RefreshToken implements oauth.TokenRefresher
+func (r *oauthRefresher) RefreshToken(token *oauth2.Token) (*oauth2.Token, error) {
+	if token.RefreshToken != "" {
+		res, err := r.config.TokenSource(r.ctx, &oauth2.Token{RefreshToken: token.RefreshToken}).Token()
+		if err == nil || r.creds == nil {
+			return res, err
+		}
+
+		r.log.DEBUG.Printf("token refresh failed, using client credentials: %v", err)
+	}
+
+	if r.creds == nil {
+		return nil, errors.New("missing refresh token")
+	}
+
+	return r.creds.Token(r.ctx)
+}
+
+var (
+	oauthSourcesMu sync.Mutex
+	oauthSources   = make(map[string]oauth2.TokenSource)
+)
+
+// oauthTransport creates a transport authorizing requests with oauth2 tokens.
+// Tokens are requested using the given client and persisted across restarts.
+// Providers using the same token share a single token source to not invalidate
+// each other's rotating refresh tokens.
+func oauthTransport(log *util.Logger, auth Auth, client *http.Client) (http.RoundTripper, error) {
+	if auth.TokenURL == "" {
+		return nil, errors.New("missing token url")
+	}
+
+	keys := []string{auth.TokenURL, auth.ClientID, auth.ClientSecret, auth.RefreshToken, strings.Join(auth.Scopes, " ")}
+
+	// token source is keyed by token file or its identifying keys if not persisted
+	file := oauth.TokenFile(keys...)
+	key := file
+	if key == "" {
+		key = strings.Join(keys, "\n")
+	}
+
+	oauthSourcesMu.Lock()
+	defer oauthSourcesMu.Unlock()
+
+	ts, ok := oauthSources[key]
+	if !ok {
+		var err error
+		if ts, err = oauthTokenSource(log, auth, client, file); err != nil {
+			return nil, err
+		}
+
+		oauthSources[key] = ts
+	}
+
+	return &oauth2.Transport{
+		Source: ts,
+		Base:   client.Transport,
+	}, nil
+}
+
+// oauthTokenSource creates a token source refreshing and persisting the token
+func oauthTokenSource(log *util.Logger, auth Auth, client *http.Client, file string) (oauth2.TokenSource, error) {
+	r := &oauthRefresher{
+		log: log,
+		ctx: context.WithValue(context.Background(), oauth2.HTTPClient, client),
+		config: oauth2.Config{
+			ClientID:     auth.ClientID,
+			ClientSecret: auth.ClientSecret,
+			Endpoint:     oauth2.Endpoint{TokenURL: auth.TokenURL},
+			Scopes:       auth.Scopes,
+		},
+	}
+
+	if auth.ClientSecret != "" {
+		r.creds = &clientcredentials.Config{
+			ClientID:     auth.ClientID,
+			ClientSecret: auth.ClientSecret,
+			TokenURL:     auth.TokenURL,
+			Scopes:       auth.Scopes,
+		}
+	}
+
+	if r.creds == nil && auth.RefreshToken == "" {
+		return nil, errors.New("missing client secret or refresh token")
+	}
+
+	// configured refresh token is replaced by persisted token
+	token := &oauth2.Token{RefreshToken: auth.RefreshToken}
+
+	if file != "" {
+		if t, err := oauth.LoadToken(file); err == nil {
+			token = t
+		} else if !os.IsNotExist(err) {
+			log.WARN.Printf("cannot load token: %v", err)
+		}
+	}
+
+	return oauth.PersistentTokenSource(log, file, oauth.RefreshTokenSource(token, r)), nil
+}
@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andig/evcc/util/oauth"
+	"golang.org/x/oauth2"
+)
+
+// authServer serves a token endpoint issuing counting access and rotating refresh tokens
+// and an api endpoint returning 1 for authorized requests
+func authServer(t *testing.T) (*httptest.Server, *int) {
+	var issued int
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+
+		switch r.Form.Get("grant_type") {
+		case "client_credentials":
+			if user, pass, _ := r.BasicAuth(); user != "client" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+		case "refresh_token":
+			if r.Form.Get("refresh_token") != fmt.Sprintf("refresh%d", issued) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		issued++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"access%d","refresh_token":"refresh%d","token_type":"bearer","expires_in":3600}`, issued, issued)
+	})
+
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != fmt.Sprintf("Bearer access%d", issued) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte("1"))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv, &issued
+}
+
+func resetOAuthSources() {
+	oauthSourcesMu.Lock()
+	oauthSources = make(map[string]oauth2.TokenSource)
+	oauthSourcesMu.Unlock()
+}
+
+func TestHTTPAuth(t *testing.T) {
+	srv, _ := authServer(t)
+
+	tc := []struct {
+		auth map[string]interface{}
+		ok   bool
+	}{
+		{map[string]interface{}{"type": "oauth2", "tokenurl": srv.URL + "/token", "clientid": "client", "clientsecret": "secret"}, true},
+		{map[string]interface{}{"type": "oauth2", "tokenurl": srv.URL + "/token", "clientid": "client", "clientsecret": "wrong"}, false},
+		{map[string]interface{}{"type": "bearer", "token": "invalid"}, false},
+	}
+
+	for _, tc := range tc {
+		p, err := NewHTTPProviderFromConfig(map[string]interface{}{
+			"uri":  srv.URL + "/api",
+			"auth": tc.auth,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := p.(IntProvider).IntGetter()()
+		if ok := err == nil && res == 1; ok != tc.ok {
+			t.Errorf("%v: expected %v, got %d (%v)", tc.auth, tc.ok, res, err)
+		}
+	}
+}
+
+func TestHTTPAuthPersistedToken(t *testing.T) {
+	srv, issued := authServer(t)
+
+	*issued = 1
+	oauth.TokenDir = t.TempDir()
+	defer func() { oauth.TokenDir = "" }()
+
+	config := map[string]interface{}{
+		"uri": srv.URL + "/api",
+		"auth": map[string]interface{}{
+			"type":         "oauth2",
+			"tokenurl":     srv.URL + "/token",
+			"clientid":     "client",
+			"refreshtoken": "refresh1",
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		// restart
+		resetOAuthSources()
+
+		p, err := NewHTTPProviderFromConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the configured refresh token is rotated on first use, the restarted
+		// provider must continue with the persisted token
+		if res, err := p.(IntProvider).IntGetter()(); err != nil || res != 1 {
+			t.Errorf("%d: expected 1, got %d (%v)", i, res, err)
+		}
+	}
+
+	if *issued != 2 {
+		t.Errorf("expected persisted token to be re-used, got %d tokens", *issued)
+	}
+}
+
+func TestHTTPAuthSharedToken(t *testing.T) {
+	srv, issued := authServer(t)
+
+	*issued = 1
+	resetOAuthSources()
+
+	config := map[string]interface{}{
+		"uri": srv.URL + "/api",
+		"auth": map[string]interface{}{
+			"type":         "oauth2",
+			"tokenurl":     srv.URL + "/token",
+			"clientid":     "client",
+			"refreshtoken": "refresh1",
+		},
+	}
+
+	var getters []func() (int64, error)
+	for i := 0; i < 2; i++ {
+		p, err := NewHTTPProviderFromConfig(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		getters = append(getters, p.(IntProvider).IntGetter())
+	}
+
+	// the second provider must not refresh with the rotated refresh token
+	for i, g := range getters {
+		if res, err := g(); err != nil || res != 1 {
+			t.Errorf("%d: expected 1, got %d (%v)", i, res, err)
+		}
+	}
+
+	if *issued != 2 {
+		t.Errorf("expected shared token, got %d tokens", *issued)
+	}
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -24,13 +25,15 @@ func ResetCached() {
 
 // Cached wraps a getter with a cache
 type Cached struct {
-	mux     sync.Mutex
-	clock   clock.Clock
-	updated time.Time
-	cache   time.Duration
-	getter  interface{}
-	val     interface{}
-	err     error
+	mux       sync.Mutex
+	clock     clock.Clock
+	updated   time.Time
+	succeeded time.Time
+	cache     time.Duration
+	timeout   time.Duration
+	getter    interface{}
+	val       interface{}
+	err       error
 }
 
 // NewCached wraps a getter with a cache
@@ -46,6 +49,14 @@ func NewCached(getter interface{}, cache time.Duration) *Cached {
 	return c
 }
 
+// WithTimeout keeps returning the last value if updating fails until timeout has
+// elapsed since the last successful update. Afterwards, api.ErrOutdated is returned.
+// Failed updates are retried after the cache duration.
+func (c *Cached) WithTimeout(timeout time.Duration) *Cached {
+	c.timeout = timeout
+	return c
+}
+
 func (c *Cached) reset() {
 	c.mux.Lock()
 	c.updated = time.Time{}
@@ -56,6 +67,34 @@ func (c *Cached) mustUpdate() bool {
 	return c.clock.Since(c.updated) > c.cache || errors.Is(c.err, api.ErrMustRetry)
 }
 
+// update updates the cached value if required
+func (c *Cached) update(g func() (interface{}, error)) {
+	if !c.mustUpdate() {
+		return
+	}
+
+	val, err := g()
+	now := c.clock.Now()
+
+	if err != nil && c.timeout > 0 && !c.succeeded.IsZero() {
+		// keep last value and retry after cache duration
+		if elapsed := now.Sub(c.succeeded); elapsed <= c.timeout {
+			log.DEBUG.Printf("using last value: %v", err)
+			c.updated = now
+			return
+		}
+
+		err = fmt.Errorf("%w: %v", api.ErrOutdated, err)
+	}
+
+	c.val, c.err = val, err
+	c.updated = now
+
+	if err == nil {
+		c.succeeded = now
+	}
+}
+
 // FloatGetter gets float value
 func (c *Cached) FloatGetter() func() (float64, error) {
 	g, ok := c.getter.(func() (float64, error))
@@ -67,10 +106,7 @@ func (c *Cached) FloatGetter() func() (float64, error) {
 		c.mux.Lock()
 		defer c.mux.Unlock()
 
-		if c.mustUpdate() {
-			c.val, c.err = g()
-			c.updated = c.clock.Now()
-		}
+		c.update(func() (interface{}, error) { return g() })
 
 		return c.val.(float64), c.err
 	}
@@ -87,10 +123,7 @@ func (c *Cached) IntGetter() func() (int64, error) {
 		c.mux.Lock()
 		defer c.mux.Unlock()
 
-		if c.mustUpdate() {
-			c.val, c.err = g()
-			c.updated = c.clock.Now()
-		}
+		c.update(func() (interface{}, error) { return g() })
 
 		return c.val.(int64), c.err
 	}
@@ -107,10 +140,7 @@ func (c *Cached) StringGetter() func() (string, error) {
 		c.mux.Lock()
 		defer c.mux.Unlock()
 
-		if c.mustUpdate() {
-			c.val, c.err = g()
-			c.updated = c.clock.Now()
-		}
+		c.update(func() (interface{}, error) { return g() })
 
 		return c.val.(string), c.err
 	}
@@ -127,10 +157,7 @@ func (c *Cached) BoolGetter() func() (bool, error) {
 		c.mux.Lock()
 		defer c.mux.Unlock()
 
-		if c.mustUpdate() {
-			c.val, c.err = g()
-			c.updated = c.clock.Now()
-		}
+		c.update(func() (interface{}, error) { return g() })
 
 		return c.val.(bool), c.err
 	}
@@ -147,10 +174,7 @@ func (c *Cached) DurationGetter() func() (time.Duration, error) {
 		c.mux.Lock()
 		defer c.mux.Unlock()
 
-		if c.mustUpdate() {
-			c.val, c.err = g()
-			c.updated = c.clock.Now()
-		}
+		c.update(func() (interface{}, error) { return g() })
 
 		return c.val.(time.Duration), c.err
 	}
@@ -167,10 +191,7 @@ func (c *Cached) TimeGetter() func() (time.Time, error) {
 		c.mux.Lock()
 		defer c.mux.Unlock()
 
-		if c.mustUpdate() {
-			c.val, c.err = g()
-			c.updated = c.clock.Now()
-		}
+		c.update(func() (interface{}, error) { return g() })
 
 		return c.val.(time.Time), c.err
 	}
@@ -187,10 +208,7 @@ func (c *Cached) InterfaceGetter() func() (interface{}, error) {
 		c.mux.Lock()
 		defer c.mux.Unlock()
 
-		if c.mustUpdate() {
-			c.val, c.err = g()
-			c.updated = c.clock.Now()
-		}
+		c.update(func() (interface{}, error) { return g() })
 
 		return c.val, c.err
 	}
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/andig/evcc/api"
 	"github.com/benbjohnson/clock"
 )
 
@@ -51,3 +52,48 @@ func TestCachedGetter(t *testing.T) {
 	clck.Add(2 * duration)
 	expect(cases[2])
 }
+
+func TestCachedTimeout(t *testing.T) {
+	var err error
+	var calls int
+	g := func() (float64, error) {
+		calls++
+		if err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	c := NewCached(g, time.Second).WithTimeout(time.Minute)
+	clck := clock.NewMock()
+	c.clock = clck
+	getter := c.FloatGetter()
+
+	if f, err := getter(); f != 1 || err != nil {
+		t.Errorf("unexpected value: %f, %v", f, err)
+	}
+
+	// last value returned until timeout
+	err = errors.New("failed")
+	clck.Add(30 * time.Second)
+	if f, err := getter(); f != 1 || err != nil {
+		t.Errorf("unexpected value: %f, %v", f, err)
+	}
+
+	// failing source is not called again within cache duration
+	if f, err := getter(); f != 1 || err != nil || calls != 2 {
+		t.Errorf("unexpected value: %f, %v (%d calls)", f, err, calls)
+	}
+
+	clck.Add(time.Minute)
+	if _, err := getter(); !errors.Is(err, api.ErrOutdated) {
+		t.Errorf("expected outdated, got %v", err)
+	}
+
+	// recovered
+	err = nil
+	clck.Add(2 * time.Second)
+	if f, err := getter(); f != 1 || err != nil {
+		t.Errorf("unexpected value: %f, %v", f, err)
+	}
+}
@@ -1,40 +1,303 @@
 package provider
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/andig/evcc/util"
 )
 
 type calcProvider struct {
-	add []func() (float64, error)
+	value func() (float64, error)
+	scale float64
 }
 
 func init() {
 	registry.Add("calc", NewCalcFromConfig)
 }
 
-// NewCalcFromConfig creates calc provider
+// calcOperandConfig is a plugin configuration used as operand
+type calcOperandConfig struct {
+	Config `mapstructure:",squash"`
+	Bool   bool // evaluate boolean value of the plugin, converted to 0/1
+}
+
+// calcClamp limits the value to min and max
+type calcClamp struct {
+	Value    calcOperandConfig
+	Min, Max *float64
+}
+
+// calcIf selects one of two values depending on the condition
+type calcIf struct {
+	Condition  Config
+	Then, Else calcOperandConfig
+}
+
+// calcListOps are the operations on lists of operands
+var calcListOps = map[string]func(vals []float64) (float64, error){
+	"add": func(vals []float64) (float64, error) {
+		var res float64
+		for _, v := range vals {
+			res += v
+		}
+		return res, nil
+	},
+	"sub": func(vals []float64) (float64, error) {
+		res := vals[0]
+		for _, v := range vals[1:] {
+			res -= v
+		}
+		return res, nil
+	},
+	"mul": func(vals []float64) (float64, error) {
+		res := vals[0]
+		for _, v := range vals[1:] {
+			res *= v
+		}
+		return res, nil
+	},
+	"div": func(vals []float64) (float64, error) {
+		res := vals[0]
+		for _, v := range vals[1:] {
+			if v == 0 {
+				return 0, errors.New("division by zero")
+			}
+			res /= v
+		}
+		return res, nil
+	},
+	"min": func(vals []float64) (float64, error) {
+		res := vals[0]
+		for _, v := range vals[1:] {
+			res = math.Min(res, v)
+		}
+		return res, nil
+	},
+	"max": func(vals []float64) (float64, error) {
+		res := vals[0]
+		for _, v := range vals[1:] {
+			res = math.Max(res, v)
+		}
+		return res, nil
+	},
+}
+
+// calcUnaryOps are the operations on single operands
+var calcUnaryOps = map[string]func(v float64) float64{
+	"abs": math.Abs,
+	"sign": func(v float64) float64 {
+		switch {
+		case v > 0:
+			return 1
+		case v < 0:
+			return -1
+		default:
+			return 0
+		}
+	},
+}
+
+// NewCalcFromConfig creates calc provider. At most one operation is evaluated,
+// operands are nested plugin configurations. Without operation the value is 0.
 func NewCalcFromConfig(other map[string]interface{}) (IntProvider, error) {
 	cc := struct {
-		Add []Config
+		Add, Sub, Mul, Div, Min, Max []calcOperandConfig
+		Abs, Sign                    *calcOperandConfig
+		Clamp                        *calcClamp
+		If                           *calcIf
+		Scale                        float64
 	}{}
 
 	if err := util.DecodeOther(other, &cc); err != nil {
 		return nil, err
 	}
 
-	o := &calcProvider{}
+	lists := map[string][]calcOperandConfig{
+		"add": cc.Add, "sub": cc.Sub, "mul": cc.Mul, "div": cc.Div, "min": cc.Min, "max": cc.Max,
+	}
+	unary := map[string]*calcOperandConfig{
+		"abs": cc.Abs, "sign": cc.Sign,
+	}
+
+	o := &calcProvider{scale: cc.Scale}
+
+	var ops []string
+	var err error
+
+	for op, operands := range lists {
+		if len(operands) > 0 {
+			ops = append(ops, op)
+			o.value, err = calcList(op, operands)
+		}
+	}
+
+	for op, operand := range unary {
+		if operand != nil {
+			ops = append(ops, op)
+			o.value, err = calcUnary(op, *operand)
+		}
+	}
+
+	if cc.Clamp != nil {
+		ops = append(ops, "clamp")
+		o.value, err = calcClampValue(*cc.Clamp)
+	}
+
+	if cc.If != nil {
+		ops = append(ops, "if")
+		o.value, err = calcCondition(*cc.If)
+	}
+
+	switch len(ops) {
+	case 0:
+		// empty sum for compatibility with add-only configurations
+		o.value = func() (float64, error) { return 0, nil }
+		return o, nil
+	case 1:
+		return o, err
+	default:
+		return nil, fmt.Errorf("multiple calc operations: %s", strings.Join(ops, ", "))
+	}
+}
+
+// calcOperand creates a float getter from config. Boolean operands are converted to 0/1.
+// Operands are evaluated as boolean if configured or if the plugin does not provide floats.
+func calcOperand(cc calcOperandConfig) (func() (float64, error), error) {
+	factory, err := registry.Get(cc.PluginType())
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := factory(cc.Other)
+	if err != nil {
+		return nil, err
+	}
 
-	for idx, cc := range cc.Add {
-		f, err := NewFloatGetterFromConfig(cc)
+	if prov, ok := provider.(FloatProvider); ok && !cc.Bool {
+		return prov.FloatGetter(), nil
+	}
+
+	prov, ok := provider.(BoolProvider)
+	if !ok {
+		return nil, fmt.Errorf("invalid plugin type: %s", cc.PluginType())
+	}
+
+	b := prov.BoolGetter()
+
+	return func() (float64, error) {
+		v, err := b()
+		if v {
+			return 1, err
+		}
+		return 0, err
+	}, nil
+}
+
+func calcList(op string, operands []calcOperandConfig) (func() (float64, error), error) {
+	var getters []func() (float64, error)
+	for idx, cc := range operands {
+		f, err := calcOperand(cc)
 		if err != nil {
-			return nil, fmt.Errorf("add[%d]: %w", idx, err)
+			return nil, fmt.Errorf("%s[%d]: %w", op, idx, err)
 		}
-		o.add = append(o.add, f)
+		getters = append(getters, f)
 	}
 
-	return o, nil
+	apply := calcListOps[op]
+
+	return func() (float64, error) {
+		vals := make([]float64, 0, len(getters))
+		for idx, f := range getters {
+			v, err := f()
+			if err != nil {
+				return 0, fmt.Errorf("%s[%d]: %w", op, idx, err)
+			}
+			vals = append(vals, v)
+		}
+
+		res, err := apply(vals)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", op, err)
+		}
+
+		return res, err
+	}, nil
+}
+
+func calcUnary(op string, operand calcOperandConfig) (func() (float64, error), error) {
+	f, err := calcOperand(operand)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	apply := calcUnaryOps[op]
+
+	return func() (float64, error) {
+		v, err := f()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		return apply(v), nil
+	}, nil
+}
+
+func calcClampValue(cc calcClamp) (func() (float64, error), error) {
+	f, err := calcOperand(cc.Value)
+	if err != nil {
+		return nil, fmt.Errorf("clamp: %w", err)
+	}
+
+	if cc.Min != nil && cc.Max != nil && *cc.Min > *cc.Max {
+		return nil, fmt.Errorf("clamp: min %v exceeds max %v", *cc.Min, *cc.Max)
+	}
+
+	return func() (float64, error) {
+		v, err := f()
+		if err != nil {
+			return 0, fmt.Errorf("clamp: %w", err)
+		}
+
+		if cc.Min != nil {
+			v = math.Max(v, *cc.Min)
+		}
+		if cc.Max != nil {
+			v = math.Min(v, *cc.Max)
+		}
+
+		return v, nil
+	}, nil
+}
+
+func calcCondition(cc calcIf) (func() (float64, error), error) {
+	cond, err := NewBoolGetterFromConfig(cc.Condition)
+	if err != nil {
+		return nil, fmt.Errorf("if: condition: %w", err)
+	}
+
+	then, err := calcOperand(cc.Then)
+	if err != nil {
+		return nil, fmt.Errorf("if: then: %w", err)
+	}
+
+	els, err := calcOperand(cc.Else)
+	if err != nil {
+		return nil, fmt.Errorf("if: else: %w", err)
+	}
+
+	return func() (float64, error) {
+		b, err := cond()
+		if err != nil {
+			return 0, fmt.Errorf("if: condition: %w", err)
+		}
+
+		if b {
+			return then()
+		}
+		return els()
+	}, nil
 }
 
 func (o *calcProvider) IntGetter() func() (int64, error) {
@@ -55,15 +318,18 @@ func (o *calcProvider) FloatGetter() func() (float64, error) {
 	return o.floatGetter
 }
 
+func (o *calcProvider) BoolGetter() func() (bool, error) {
+	return func() (bool, error) {
+		f, err := o.floatGetter()
+		return f != 0, err
+	}
+}
+
 func (o *calcProvider) floatGetter() (float64, error) {
-	var sum float64
-	for idx, p := range o.add {
-		v, err := p()
-		if err != nil {
-			return 0, fmt.Errorf("add[%d]: %w", idx, err)
-		}
-		sum += v
+	f, err := o.value()
+	if err == nil && o.scale != 0 {
+		f *= o.scale
 	}
 
-	return sum, nil
+	return f, err
 }
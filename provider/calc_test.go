@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"testing"
+)
+
+func constConfig(value interface{}) map[string]interface{} {
+	return map[string]interface{}{"source": "const", "value": value}
+}
+
+func boolConfig(value interface{}) map[string]interface{} {
+	return map[string]interface{}{"source": "const", "value": value, "bool": true}
+}
+
+func TestCalcProvider(t *testing.T) {
+	tc := []struct {
+		config   map[string]interface{}
+		expected float64
+		err      bool
+	}{
+		{map[string]interface{}{"add": []interface{}{constConfig(1), constConfig(2)}}, 3, false},
+		{map[string]interface{}{"sub": []interface{}{constConfig(5), constConfig(2), constConfig(1)}}, 2, false},
+		{map[string]interface{}{"mul": []interface{}{constConfig(2), constConfig(-3)}}, -6, false},
+		{map[string]interface{}{"div": []interface{}{constConfig(4600), constConfig(230)}}, 20, false},
+		{map[string]interface{}{"div": []interface{}{constConfig(1), constConfig(0)}}, 0, true},
+		{map[string]interface{}{"min": []interface{}{constConfig(2), constConfig(-1), constConfig(3)}}, -1, false},
+		{map[string]interface{}{"max": []interface{}{constConfig(2), constConfig(-1), constConfig(3)}}, 3, false},
+		{map[string]interface{}{"abs": constConfig(-2.5)}, 2.5, false},
+		{map[string]interface{}{"sign": constConfig(-2.5)}, -1, false},
+		{map[string]interface{}{"sign": constConfig(0)}, 0, false},
+		{map[string]interface{}{"add": []interface{}{constConfig(1), boolConfig("true")}}, 2, false}, // bool operand
+		{map[string]interface{}{"add": []interface{}{constConfig(1), boolConfig("false")}}, 1, false},
+		{map[string]interface{}{"add": []interface{}{constConfig(1), constConfig("true")}}, 0, true}, // bool operand without type
+		{map[string]interface{}{"sign": boolConfig(true)}, 1, false},
+		{map[string]interface{}{"if": map[string]interface{}{"condition": constConfig("true"), "then": boolConfig("true"), "else": constConfig(2)}}, 1, false},
+		{map[string]interface{}{}, 0, false}, // empty calc
+		{map[string]interface{}{"add": []interface{}{}}, 0, false},
+		{map[string]interface{}{"abs": constConfig(-2), "scale": 1000}, 2000, false},
+		{map[string]interface{}{"clamp": map[string]interface{}{"value": constConfig(120), "min": 0, "max": 100}}, 100, false},
+		{map[string]interface{}{"clamp": map[string]interface{}{"value": constConfig(-5), "min": 0}}, 0, false},
+		{map[string]interface{}{"if": map[string]interface{}{"condition": constConfig(true), "then": constConfig(1), "else": constConfig(2)}}, 1, false},
+		{map[string]interface{}{"if": map[string]interface{}{"condition": constConfig(false), "then": constConfig(1), "else": constConfig(2)}}, 2, false},
+		{
+			// battery power = -sign(status) * abs(power)
+			map[string]interface{}{"mul": []interface{}{
+				map[string]interface{}{"source": "calc", "sign": constConfig(1), "scale": -1},
+				map[string]interface{}{"source": "calc", "abs": constConfig(-1500)},
+			}},
+			-1500, false,
+		},
+	}
+
+	for _, tc := range tc {
+		p, err := NewCalcFromConfig(tc.config)
+		if err != nil {
+			t.Errorf("%v: %v", tc.config, err)
+			continue
+		}
+
+		res, err := p.(FloatProvider).FloatGetter()()
+		if (err != nil) != tc.err {
+			t.Errorf("%v: unexpected error %v", tc.config, err)
+		}
+
+		if res != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.config, tc.expected, res)
+		}
+	}
+}
+
+func TestCalcProviderConfig(t *testing.T) {
+	tc := []map[string]interface{}{
+		{"add": []interface{}{constConfig(1)}, "abs": constConfig(1)},
+		{"abs": map[string]interface{}{"source": "foo"}},
+		{"clamp": map[string]interface{}{"value": constConfig(1), "min": 2, "max": 1}},
+	}
+
+	for _, tc := range tc {
+		if _, err := NewCalcFromConfig(tc); err == nil {
+			t.Errorf("%v: expected error", tc)
+		}
+	}
+}
@@ -22,6 +22,12 @@ type (
 	SetIntProvider interface {
 		IntSetter(param string) func(int64) error
 	}
+	SetFloatProvider interface {
+		FloatSetter(param string) func(float64) error
+	}
+	SetStringProvider interface {
+		StringSetter(param string) func(string) error
+	}
 	SetBoolProvider interface {
 		BoolSetter(param string) func(bool) error
 	}
@@ -164,6 +170,44 @@ func NewIntSetterFromConfig(param string, config Config) (res func(int64) error,
 	return
 }
 
+// NewFloatSetterFromConfig creates a FloatSetter from config
+func NewFloatSetterFromConfig(param string, config Config) (res func(float64) error, err error) {
+	factory, err := registry.Get(config.PluginType())
+	if err == nil {
+		var provider IntProvider
+		provider, err = factory(config.Other)
+
+		if prov, ok := provider.(SetFloatProvider); ok {
+			res = prov.FloatSetter(param)
+		}
+	}
+
+	if err == nil && res == nil {
+		err = fmt.Errorf("invalid plugin type: %s", config.PluginType())
+	}
+
+	return
+}
+
+// NewStringSetterFromConfig creates a StringSetter from config
+func NewStringSetterFromConfig(param string, config Config) (res func(string) error, err error) {
+	factory, err := registry.Get(config.PluginType())
+	if err == nil {
+		var provider IntProvider
+		provider, err = factory(config.Other)
+
+		if prov, ok := provider.(SetStringProvider); ok {
+			res = prov.StringSetter(param)
+		}
+	}
+
+	if err == nil && res == nil {
+		err = fmt.Errorf("invalid plugin type: %s", config.PluginType())
+	}
+
+	return
+}
+
 // NewBoolSetterFromConfig creates a BoolSetter from config
 func NewBoolSetterFromConfig(param string, config Config) (res func(bool) error, err error) {
 	factory, err := registry.Get(config.PluginType())
@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/andig/evcc/util"
+)
+
+type constProvider struct {
+	value string
+}
+
+func init() {
+	registry.Add("const", NewConstFromConfig)
+}
+
+// NewConstFromConfig creates const provider returning a fixed value, e.g. as calc operand
+func NewConstFromConfig(other map[string]interface{}) (IntProvider, error) {
+	cc := struct {
+		Value string
+	}{}
+
+	if err := util.DecodeOther(other, &cc); err != nil {
+		return nil, err
+	}
+
+	if cc.Value == "" {
+		return nil, fmt.Errorf("missing value")
+	}
+
+	return &constProvider{value: cc.Value}, nil
+}
+
+func (o *constProvider) IntGetter() func() (int64, error) {
+	return func() (int64, error) {
+		return strconv.ParseInt(o.value, 10, 64)
+	}
+}
+
+func (o *constProvider) FloatGetter() func() (float64, error) {
+	return func() (float64, error) {
+		return strconv.ParseFloat(o.value, 64)
+	}
+}
+
+func (o *constProvider) StringGetter() func() (string, error) {
+	return func() (string, error) {
+		return o.value, nil
+	}
+}
+
+func (o *constProvider) BoolGetter() func() (bool, error) {
+	return func() (bool, error) {
+		return strconv.ParseBool(o.value)
+	}
+}
@@ -0,0 +1,299 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/andig/evcc/util"
+)
+
+const (
+	fallbackFirst    = "first"
+	fallbackMajority = "majority"
+	fallbackMedian   = "median"
+)
+
+type fallbackProvider struct {
+	mu        sync.Mutex
+	log       *util.Logger
+	mode      string
+	tolerance float64
+	names     []string
+	providers []IntProvider
+	failed    []bool
+	active    int
+}
+
+func init() {
+	registry.Add("fallback", NewFallbackFromConfig)
+}
+
+// NewFallbackFromConfig creates fallback provider. Depending on mode, the first successful
+// source is used or the values of all successful sources are compared.
+func NewFallbackFromConfig(other map[string]interface{}) (IntProvider, error) {
+	cc := struct {
+		Sources   []Config
+		Mode      string
+		Tolerance float64 // maximum difference of float values considered equal in majority mode
+	}{}
+
+	if err := util.DecodeOther(other, &cc); err != nil {
+		return nil, err
+	}
+
+	if len(cc.Sources) == 0 {
+		return nil, errors.New("missing sources")
+	}
+
+	mode := strings.ToLower(cc.Mode)
+	if mode == "" {
+		mode = fallbackFirst
+	}
+
+	switch mode {
+	case fallbackFirst, fallbackMajority, fallbackMedian:
+	default:
+		return nil, fmt.Errorf("invalid mode: %s", cc.Mode)
+	}
+
+	o := &fallbackProvider{
+		log:       util.NewLogger("fallback"),
+		mode:      mode,
+		tolerance: cc.Tolerance,
+		failed:    make([]bool, len(cc.Sources)),
+		active:    -1,
+	}
+
+	for idx, sc := range cc.Sources {
+		factory, err := registry.Get(sc.PluginType())
+		if err != nil {
+			return nil, fmt.Errorf("sources[%d]: %w", idx, err)
+		}
+
+		p, err := factory(sc.Other)
+		if err != nil {
+			return nil, fmt.Errorf("sources[%d]: %w", idx, err)
+		}
+
+		o.names = append(o.names, fmt.Sprintf("%d (%s)", idx, sc.PluginType()))
+		o.providers = append(o.providers, p)
+	}
+
+	return o, nil
+}
+
+// status logs failing and recovering sources
+func (o *fallbackProvider) status(idx int, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if failed := err != nil; failed != o.failed[idx] {
+		if failed {
+			o.log.WARN.Printf("source %s failed: %v", o.names[idx], err)
+		} else {
+			o.log.INFO.Printf("source %s recovered", o.names[idx])
+		}
+		o.failed[idx] = failed
+	}
+}
+
+// use logs the source in use
+func (o *fallbackProvider) use(idx int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if idx != o.active {
+		o.log.INFO.Printf("using source %s", o.names[idx])
+		o.active = idx
+	}
+}
+
+// collect returns the values of the successful sources. In first mode,
+// evaluation stops at the first successful source.
+func (o *fallbackProvider) collect(get func(idx int) (interface{}, error)) ([]interface{}, error) {
+	var vals []interface{}
+	var err error
+
+	for idx := range o.providers {
+		v, verr := get(idx)
+		o.status(idx, verr)
+
+		if verr != nil {
+			err = verr
+			continue
+		}
+
+		vals = append(vals, v)
+
+		if o.mode == fallbackFirst {
+			o.use(idx)
+			break
+		}
+	}
+
+	if len(vals) == 0 {
+		return nil, fmt.Errorf("all sources failed: %w", err)
+	}
+
+	return vals, nil
+}
+
+// majority returns the value shared by more than half of the values
+func majority(vals []interface{}, equal func(a, b interface{}) bool) (interface{}, error) {
+	var res interface{}
+	var count int
+
+	for _, a := range vals {
+		var n int
+		for _, b := range vals {
+			if equal(a, b) {
+				n++
+			}
+		}
+
+		if n > count {
+			res, count = a, n
+		}
+	}
+
+	if 2*count <= len(vals) {
+		return nil, fmt.Errorf("no majority: %v", vals)
+	}
+
+	return res, nil
+}
+
+// median returns the median of the values
+func median(vals []interface{}) float64 {
+	f := make([]float64, 0, len(vals))
+	for _, v := range vals {
+		f = append(f, v.(float64))
+	}
+
+	sort.Float64s(f)
+
+	if n := len(f); n%2 == 0 {
+		return (f[n/2-1] + f[n/2]) / 2
+	}
+
+	return f[len(f)/2]
+}
+
+func (o *fallbackProvider) FloatGetter() func() (float64, error) {
+	getters := make([]func() (float64, error), 0, len(o.providers))
+	for _, p := range o.providers {
+		prov, ok := p.(FloatProvider)
+		if !ok {
+			return nil
+		}
+		getters = append(getters, prov.FloatGetter())
+	}
+
+	return func() (float64, error) {
+		vals, err := o.collect(func(idx int) (interface{}, error) {
+			return getters[idx]()
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		switch o.mode {
+		case fallbackMedian:
+			return median(vals), nil
+
+		case fallbackMajority:
+			res, err := majority(vals, func(a, b interface{}) bool {
+				return math.Abs(a.(float64)-b.(float64)) <= o.tolerance
+			})
+			if err != nil {
+				return 0, err
+			}
+			return res.(float64), nil
+
+		default:
+			return vals[0].(float64), nil
+		}
+	}
+}
+
+func (o *fallbackProvider) IntGetter() func() (int64, error) {
+	g := o.FloatGetter()
+	if g == nil {
+		return nil
+	}
+
+	return func() (int64, error) {
+		f, err := g()
+		return int64(math.Round(f)), err
+	}
+}
+
+func (o *fallbackProvider) StringGetter() func() (string, error) {
+	if o.mode == fallbackMedian {
+		return nil
+	}
+
+	getters := make([]func() (string, error), 0, len(o.providers))
+	for _, p := range o.providers {
+		prov, ok := p.(StringProvider)
+		if !ok {
+			return nil
+		}
+		getters = append(getters, prov.StringGetter())
+	}
+
+	return func() (string, error) {
+		vals, err := o.collect(func(idx int) (interface{}, error) {
+			return getters[idx]()
+		})
+		if err != nil {
+			return "", err
+		}
+
+		res := vals[0]
+		if o.mode == fallbackMajority {
+			if res, err = majority(vals, func(a, b interface{}) bool { return a == b }); err != nil {
+				return "", err
+			}
+		}
+
+		return res.(string), nil
+	}
+}
+
+func (o *fallbackProvider) BoolGetter() func() (bool, error) {
+	if o.mode == fallbackMedian {
+		return nil
+	}
+
+	getters := make([]func() (bool, error), 0, len(o.providers))
+	for _, p := range o.providers {
+		prov, ok := p.(BoolProvider)
+		if !ok {
+			return nil
+		}
+		getters = append(getters, prov.BoolGetter())
+	}
+
+	return func() (bool, error) {
+		vals, err := o.collect(func(idx int) (interface{}, error) {
+			return getters[idx]()
+		})
+		if err != nil {
+			return false, err
+		}
+
+		res := vals[0]
+		if o.mode == fallbackMajority {
+			if res, err = majority(vals, func(a, b interface{}) bool { return a == b }); err != nil {
+				return false, err
+			}
+		}
+
+		return res.(bool), nil
+	}
+}
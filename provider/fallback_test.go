@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"testing"
+)
+
+// failingConfig is a source failing on every read
+var failingConfig = map[string]interface{}{
+	"source": "calc",
+	"div":    []interface{}{constConfig(1), constConfig(0)},
+}
+
+func TestFallbackProvider(t *testing.T) {
+	tc := []struct {
+		mode     string
+		sources  []interface{}
+		expected float64
+		err      bool
+	}{
+		{"", []interface{}{constConfig(1), constConfig(2)}, 1, false},
+		{"first", []interface{}{failingConfig, constConfig(2)}, 2, false},
+		{"first", []interface{}{failingConfig, failingConfig}, 0, true},
+		{"median", []interface{}{constConfig(3), constConfig(1000), constConfig(1)}, 3, false},
+		{"median", []interface{}{constConfig(3), failingConfig, constConfig(1)}, 2, false},
+		{"majority", []interface{}{constConfig(3), constConfig(1000), constConfig(3)}, 3, false},
+		{"majority", []interface{}{constConfig(3), constConfig(1000)}, 0, true},
+		{"majority", []interface{}{constConfig(3), failingConfig}, 3, false},
+	}
+
+	for _, tc := range tc {
+		p, err := NewFallbackFromConfig(map[string]interface{}{
+			"mode":    tc.mode,
+			"sources": tc.sources,
+		})
+		if err != nil {
+			t.Errorf("%s %v: %v", tc.mode, tc.sources, err)
+			continue
+		}
+
+		res, err := p.(FloatProvider).FloatGetter()()
+		if (err != nil) != tc.err {
+			t.Errorf("%s %v: unexpected error %v", tc.mode, tc.sources, err)
+		}
+
+		if res != tc.expected {
+			t.Errorf("%s %v: expected %v, got %v", tc.mode, tc.sources, tc.expected, res)
+		}
+	}
+}
+
+func TestFallbackProviderTolerance(t *testing.T) {
+	p, err := NewFallbackFromConfig(map[string]interface{}{
+		"mode":      "majority",
+		"tolerance": 10,
+		"sources":   []interface{}{constConfig(1000), constConfig(1005), constConfig(2000)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res, err := p.(FloatProvider).FloatGetter()(); err != nil || res != 1000 {
+		t.Errorf("expected 1000, got %v (%v)", res, err)
+	}
+}
+
+func TestFallbackProviderTypes(t *testing.T) {
+	p, err := NewFallbackFromConfig(map[string]interface{}{
+		"mode":    "majority",
+		"sources": []interface{}{constConfig("C"), constConfig("B"), constConfig("C")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res, err := p.(StringProvider).StringGetter()(); err != nil || res != "C" {
+		t.Errorf("expected C, got %v (%v)", res, err)
+	}
+
+	p, err = NewFallbackFromConfig(map[string]interface{}{
+		"mode":    "median",
+		"sources": []interface{}{constConfig("C")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g := p.(StringProvider).StringGetter(); g != nil {
+		t.Error("unexpected string getter in median mode")
+	}
+}
+
+func TestFallbackProviderConfig(t *testing.T) {
+	tc := []map[string]interface{}{
+		{},
+		{"mode": "foo", "sources": []interface{}{constConfig(1)}},
+		{"sources": []interface{}{map[string]interface{}{"source": "foo"}}},
+	}
+
+	for _, tc := range tc {
+		if _, err := NewFallbackFromConfig(tc); err == nil {
+			t.Errorf("%v: expected error", tc)
+		}
+	}
+}
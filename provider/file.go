@@ -0,0 +1,406 @@
+package provider
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/util"
+	"github.com/andig/evcc/util/extract"
+	"github.com/fsnotify/fsnotify"
+)
+
+// File implements file and named pipe provider
+type File struct {
+	log     *util.Logger
+	path    string
+	payload string
+	scale   float64
+	extract *extract.Extractor
+
+	// watched files and pipes
+	started  sync.Once
+	mux      *util.Waiter
+	watched  bool
+	received bool
+	val      string
+	err      error
+}
+
+func init() {
+	registry.Add("file", NewFileProviderFromConfig)
+}
+
+// NewFileProviderFromConfig creates a file provider. Once read, regular files are
+// watched for changes and named pipes are read continuously.
+func NewFileProviderFromConfig(other map[string]interface{}) (IntProvider, error) {
+	cc := struct {
+		Path    string
+		Payload string         // Payload only applies to setters
+		Extract extract.Config `mapstructure:",squash"`
+		Scale   float64
+		Timeout time.Duration
+	}{
+		Scale: 1,
+	}
+
+	if err := util.DecodeOther(other, &cc); err != nil {
+		return nil, err
+	}
+
+	if cc.Path == "" {
+		return nil, fmt.Errorf("missing path")
+	}
+
+	log := util.NewLogger("file")
+
+	p := &File{
+		log:     log,
+		path:    filepath.Clean(cc.Path),
+		payload: cc.Payload,
+		scale:   cc.Scale,
+		mux:     util.NewWaiter(cc.Timeout, func() { log.TRACE.Printf("%s wait for initial value", cc.Path) }),
+	}
+
+	e, err := extract.New(cc.Extract)
+	if err != nil {
+		return nil, err
+	}
+
+	p.extract = e
+
+	return p, nil
+}
+
+// start subscribes to the shared watcher of the path. Watching is started by the getters
+// only to not consume lines written to named pipes by the setters.
+func (p *File) start() {
+	p.started.Do(func() {
+		if err := subscribeFileWatcher(p); err != nil {
+			p.log.WARN.Printf("%s: cannot watch for changes, reading on each update: %v", p.path, err)
+			return
+		}
+
+		p.watched = true
+	})
+}
+
+// Close releases the shared watcher of the path. The provider must not be used afterwards.
+func (p *File) Close() error {
+	return unsubscribeFileWatcher(p)
+}
+
+// update stores the current file content
+func (p *File) update(val string, err error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.received = true
+	p.val, p.err = val, err
+	p.mux.Update()
+}
+
+// fileWatcher watches a file or reads a named pipe and distributes the content to all providers of the path
+type fileWatcher struct {
+	mu          sync.Mutex
+	log         *util.Logger
+	path        string
+	closer      io.Closer // fsnotify watcher or named pipe
+	subscribers map[*File]struct{}
+	received    bool
+	val         string
+	err         error
+}
+
+var (
+	fileWatchersMu sync.Mutex
+	fileWatchers   = make(map[string]*fileWatcher)
+)
+
+// subscribeFileWatcher subscribes the provider to the shared watcher of its path
+func subscribeFileWatcher(p *File) error {
+	fileWatchersMu.Lock()
+	defer fileWatchersMu.Unlock()
+
+	w, ok := fileWatchers[p.path]
+	if !ok {
+		w = &fileWatcher{
+			log:         util.NewLogger("file"),
+			path:        p.path,
+			subscribers: make(map[*File]struct{}),
+		}
+
+		var err error
+		if fi, serr := os.Stat(p.path); serr == nil && fi.Mode()&os.ModeNamedPipe != 0 {
+			err = w.readPipe()
+		} else {
+			err = w.watch()
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fileWatchers[p.path] = w
+	}
+
+	w.subscribe(p)
+
+	return nil
+}
+
+// unsubscribeFileWatcher removes the provider from the shared watcher of its path.
+// The watcher is closed when its last subscriber is removed.
+func unsubscribeFileWatcher(p *File) error {
+	fileWatchersMu.Lock()
+	defer fileWatchersMu.Unlock()
+
+	w, ok := fileWatchers[p.path]
+	if !ok {
+		return nil
+	}
+
+	w.mu.Lock()
+	delete(w.subscribers, p)
+	last := len(w.subscribers) == 0
+	w.mu.Unlock()
+
+	if !last {
+		return nil
+	}
+
+	delete(fileWatchers, p.path)
+
+	return w.closer.Close()
+}
+
+// subscribe adds the subscriber and sends the current content if available
+func (w *fileWatcher) subscribe(p *File) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.subscribers[p] = struct{}{}
+	if w.received {
+		p.update(w.val, w.err)
+	}
+}
+
+// publish sends the content to all subscribers
+func (w *fileWatcher) publish(val string, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.received = true
+	w.val, w.err = val, err
+
+	for p := range w.subscribers {
+		p.update(val, err)
+	}
+}
+
+// watch watches the file's directory for changes to keep atomic replacements visible
+func (w *fileWatcher) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err := watcher.Add(filepath.Dir(w.path)); err != nil {
+		_ = watcher.Close()
+		return err
+	}
+
+	w.closer = watcher
+	w.publish(readFile(w.path))
+
+	go func() {
+		for {
+			select {
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if filepath.Clean(ev.Name) == w.path {
+					w.publish(readFile(w.path))
+				}
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				w.log.ERROR.Printf("%s: %v", w.path, err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// readPipe continuously reads lines from the named pipe. The pipe is opened for writing, too,
+// to not block until a writer connects and to keep it open when writers disconnect.
+func (w *fileWatcher) readPipe() error {
+	f, err := os.OpenFile(w.path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	w.closer = f
+
+	go func() {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			w.publish(scanner.Text(), nil)
+		}
+
+		if err := scanner.Err(); err != nil && !errors.Is(err, os.ErrClosed) {
+			w.log.ERROR.Printf("%s: %v", w.path, err)
+			w.publish("", err)
+		}
+	}()
+
+	return nil
+}
+
+// readFile reads the file content
+func readFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	return string(b), err
+}
+
+// content returns the current file content. Named pipes are not available until the
+// first line has been received to not block the caller on silent writers.
+func (p *File) content() (string, error) {
+	p.start()
+
+	if !p.watched {
+		return readFile(p.path)
+	}
+
+	p.mux.Lock()
+	received := p.received
+	p.mux.Unlock()
+
+	if !received {
+		return "", fmt.Errorf("%s: %w", p.path, api.ErrNotAvailable)
+	}
+
+	elapsed := p.mux.LockWithTimeout()
+	defer p.mux.Unlock()
+
+	if elapsed > 0 {
+		return "", fmt.Errorf("%s %w: %v", p.path, api.ErrOutdated, elapsed.Truncate(time.Second))
+	}
+
+	return p.val, p.err
+}
+
+// StringGetter returns string extracted from file content
+func (p *File) StringGetter() func() (string, error) {
+	return func() (string, error) {
+		s, err := p.content()
+		if err != nil {
+			return "", err
+		}
+
+		return p.extract.Extract([]byte(s))
+	}
+}
+
+// FloatGetter parses float from string getter
+func (p *File) FloatGetter() func() (float64, error) {
+	g := p.StringGetter()
+
+	return func() (float64, error) {
+		s, err := g()
+		if err != nil {
+			return 0, err
+		}
+
+		f, err := strconv.ParseFloat(s, 64)
+		return f * p.scale, err
+	}
+}
+
+// IntGetter parses int64 from float getter
+func (p *File) IntGetter() func() (int64, error) {
+	g := p.FloatGetter()
+
+	return func() (int64, error) {
+		f, err := g()
+		return int64(math.Round(f)), err
+	}
+}
+
+// BoolGetter parses bool from string getter
+func (p *File) BoolGetter() func() (bool, error) {
+	g := p.StringGetter()
+
+	return func() (bool, error) {
+		s, err := g()
+		return util.Truish(s), err
+	}
+}
+
+// write writes the payload with parameter replaced by value. Regular files are
+// replaced atomically, named pipes require a reader.
+func (p *File) write(param string, val interface{}) error {
+	payload, err := setFormattedValue(p.payload, param, val)
+	if err != nil {
+		return err
+	}
+
+	if fi, err := os.Stat(p.path); err == nil && fi.Mode()&os.ModeNamedPipe != 0 {
+		f, err := os.OpenFile(p.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			return err
+		}
+
+		_, err = f.WriteString(payload + "\n")
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+
+		return err
+	}
+
+	return util.WriteFileAtomic(p.path, []byte(payload), 0644)
+}
+
+// IntSetter writes int value
+func (p *File) IntSetter(param string) func(int64) error {
+	return func(val int64) error {
+		return p.write(param, val)
+	}
+}
+
+// FloatSetter writes float value
+func (p *File) FloatSetter(param string) func(float64) error {
+	return func(val float64) error {
+		return p.write(param, val)
+	}
+}
+
+// StringSetter writes string value
+func (p *File) StringSetter(param string) func(string) error {
+	return func(val string) error {
+		return p.write(param, val)
+	}
+}
+
+// BoolSetter writes bool value
+func (p *File) BoolSetter(param string) func(bool) error {
+	return func(val bool) error {
+		return p.write(param, val)
+	}
+}
@@ -0,0 +1,174 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// eventually retries the getter until the expected value is returned
+func eventually(t *testing.T, g func() (float64, error), expected float64) {
+	t.Helper()
+
+	var res float64
+	var err error
+
+	for start := time.Now(); time.Since(start) < 2*time.Second; time.Sleep(10 * time.Millisecond) {
+		if res, err = g(); err == nil && res == expected {
+			return
+		}
+	}
+
+	t.Errorf("expected %v, got %v (%v)", expected, res, err)
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "power")
+	if err := os.WriteFile(path, []byte("1000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewFileProviderFromConfig(map[string]interface{}{"path": path, "scale": 0.001})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := p.(FloatProvider).FloatGetter()
+	eventually(t, g, 1)
+
+	// changes are watched
+	if err := os.WriteFile(path, []byte("2000"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, g, 2)
+
+	// atomic write
+	if err := p.(SetFloatProvider).FloatSetter("power")(3000); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, g, 3)
+}
+
+func TestFileProviderExtract(t *testing.T) {
+	dir := t.TempDir()
+
+	tc := []struct {
+		content  string
+		config   map[string]interface{}
+		expected string
+	}{
+		{`{"power": 1500}`, map[string]interface{}{"jq": ".power"}, "1500"},
+		{"power=1500W\nenergy=12kWh", map[string]interface{}{"regex": `energy=(\d+)`}, "12"},
+		{"status: C", map[string]interface{}{"regex": `[A-F]$`}, "C"},
+	}
+
+	for i, tc := range tc {
+		path := filepath.Join(dir, string(rune('a'+i)))
+		if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		tc.config["path"] = path
+
+		p, err := NewFileProviderFromConfig(tc.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res, err := p.(StringProvider).StringGetter()(); err != nil || res != tc.expected {
+			t.Errorf("%v: expected %s, got %s (%v)", tc.config, tc.expected, res, err)
+		}
+	}
+}
+
+func TestFileProviderSetter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "command")
+
+	p, err := NewFileProviderFromConfig(map[string]interface{}{"path": path, "payload": "enable=${enable:%d}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.(SetBoolProvider).BoolSetter("enable")(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := os.ReadFile(path); err != nil || string(b) != "enable=1" {
+		t.Errorf("unexpected content: %s (%v)", b, err)
+	}
+
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("unexpected temp file: %v", err)
+	}
+}
+
+func TestFileProviderSharedWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "power")
+	if err := os.WriteFile(path, []byte("1000"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var providers []*File
+	var getters []func() (float64, error)
+	for i := 0; i < 2; i++ {
+		p, err := NewFileProviderFromConfig(map[string]interface{}{"path": path})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		providers = append(providers, p.(*File))
+		getters = append(getters, p.(FloatProvider).FloatGetter())
+	}
+
+	// watcher is created by the getter
+	fileWatchersMu.Lock()
+	_, ok := fileWatchers[path]
+	fileWatchersMu.Unlock()
+	if ok {
+		t.Error("unexpected watcher before reading")
+	}
+
+	for _, g := range getters {
+		eventually(t, g, 1000)
+	}
+
+	fileWatchersMu.Lock()
+	w := fileWatchers[path]
+	fileWatchersMu.Unlock()
+
+	if w == nil {
+		t.Fatal("missing watcher")
+	}
+
+	w.mu.Lock()
+	subscribers := len(w.subscribers)
+	w.mu.Unlock()
+
+	if subscribers != 2 {
+		t.Errorf("expected shared watcher, got %d subscribers", subscribers)
+	}
+
+	if err := os.WriteFile(path, []byte("2000"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, g := range getters {
+		eventually(t, g, 2000)
+	}
+
+	// watcher is closed with its last subscriber
+	for i, p := range providers {
+		if err := p.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		fileWatchersMu.Lock()
+		_, ok := fileWatchers[path]
+		fileWatchersMu.Unlock()
+
+		if last := i == len(providers)-1; ok == last {
+			t.Errorf("expected watcher closed %v, got %v", last, !ok)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// +build !windows
+
+package provider
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/andig/evcc/api"
+)
+
+func TestFileProviderSilentPipe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "power")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewFileProviderFromConfig(map[string]interface{}{"path": path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := p.(FloatProvider).FloatGetter()
+
+	// getter does not block without writer
+	done := make(chan error, 1)
+	go func() {
+		_, err := g()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, api.ErrNotAvailable) {
+			t.Errorf("expected not available error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("getter blocked on silent pipe")
+	}
+
+	// value is available once written
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString("1000\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, g, 1000)
+
+	// pipe is closed with the last subscriber
+	if err := p.(*File).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fileWatchersMu.Lock()
+	_, ok := fileWatchers[path]
+	fileWatchersMu.Unlock()
+
+	if ok {
+		t.Error("unexpected pipe reader after close")
+	}
+}
+
+func TestFileProviderPipeSetter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "command")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewFileProviderFromConfig(map[string]interface{}{"path": path, "payload": "${current}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// external reader
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// setter must not start reading the pipe
+	if err := p.(SetIntProvider).IntSetter("current")(16); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil || line != "16\n" {
+		t.Errorf("unexpected line: %q (%v)", line, err)
+	}
+
+	fileWatchersMu.Lock()
+	defer fileWatchersMu.Unlock()
+	if _, ok := fileWatchers[path]; ok {
+		t.Error("unexpected pipe reader")
+	}
+}
@@ -0,0 +1,215 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andig/evcc/util"
+	"github.com/benbjohnson/clock"
+)
+
+const (
+	filterNone    = "none"
+	filterAverage = "average"
+	filterMedian  = "median"
+	filterSmooth  = "smooth"
+)
+
+type filterSample struct {
+	ts  time.Time
+	val float64
+}
+
+type filterProvider struct {
+	mu       sync.Mutex
+	log      *util.Logger
+	clock    clock.Clock
+	get      func() (float64, error)
+	filter   string
+	window   time.Duration
+	maxDelta float64
+	interval time.Duration
+
+	samples  []filterSample
+	last     filterSample  // last accepted sample
+	rejected *filterSample // last sample if rejected as spike
+	res      float64
+}
+
+func init() {
+	registry.Add("filter", NewFilterFromConfig)
+}
+
+// NewFilterFromConfig creates filter provider smoothing the values of a float getter
+func NewFilterFromConfig(other map[string]interface{}) (IntProvider, error) {
+	cc := struct {
+		Get      Config
+		Filter   string
+		Window   time.Duration // time window of average and median or time constant of smooth
+		MaxDelta float64       // maximum change per interval, larger changes are rejected as spike
+		Interval time.Duration // interval of max delta, default per sample
+	}{}
+
+	if err := util.DecodeOther(other, &cc); err != nil {
+		return nil, err
+	}
+
+	filter := strings.ToLower(cc.Filter)
+	if filter == "" {
+		filter = filterNone
+	}
+
+	switch filter {
+	case filterNone:
+	case filterAverage, filterMedian, filterSmooth:
+		if cc.Window <= 0 {
+			return nil, fmt.Errorf("missing window for filter: %s", filter)
+		}
+	default:
+		return nil, fmt.Errorf("invalid filter: %s", cc.Filter)
+	}
+
+	if filter == filterNone && cc.MaxDelta <= 0 {
+		return nil, errors.New("missing filter or max delta")
+	}
+
+	get, err := NewFloatGetterFromConfig(cc.Get)
+	if err != nil {
+		return nil, fmt.Errorf("get: %w", err)
+	}
+
+	o := &filterProvider{
+		log:      util.NewLogger("filter"),
+		clock:    clock.New(),
+		get:      get,
+		filter:   filter,
+		window:   cc.Window,
+		maxDelta: cc.MaxDelta,
+		interval: cc.Interval,
+	}
+
+	return o, nil
+}
+
+// allowed returns the maximum change since the reference sample
+func (o *filterProvider) allowed(now time.Time, ref filterSample) float64 {
+	if o.interval > 0 {
+		return o.maxDelta * float64(now.Sub(ref.ts)) / float64(o.interval)
+	}
+	return o.maxDelta
+}
+
+// spike checks if the value exceeds the maximum change since the last accepted sample.
+// Persistent changes are accepted if the value is within the maximum change of the
+// previously rejected sample.
+func (o *filterProvider) spike(now time.Time, val float64) bool {
+	if o.maxDelta <= 0 || o.last.ts.IsZero() {
+		return false
+	}
+
+	rejected := o.rejected
+	o.rejected = nil
+
+	if math.Abs(val-o.last.val) <= o.allowed(now, o.last) ||
+		rejected != nil && math.Abs(val-rejected.val) <= o.allowed(now, *rejected) {
+		return false
+	}
+
+	o.rejected = &filterSample{ts: now, val: val}
+	return true
+}
+
+// apply adds the sample and returns the filtered value
+func (o *filterProvider) apply(now time.Time, val float64) float64 {
+	if o.filter == filterNone {
+		return val
+	}
+
+	if o.filter == filterSmooth {
+		if o.last.ts.IsZero() {
+			return val
+		}
+
+		alpha := 1 - math.Exp(-float64(now.Sub(o.last.ts))/float64(o.window))
+		return o.res + alpha*(val-o.res)
+	}
+
+	o.samples = append(o.samples, filterSample{ts: now, val: val})
+
+	// remove samples outside the window
+	for len(o.samples) > 1 && now.Sub(o.samples[0].ts) >= o.window {
+		o.samples = o.samples[1:]
+	}
+
+	vals := make([]float64, 0, len(o.samples))
+	for _, s := range o.samples {
+		vals = append(vals, s.val)
+	}
+
+	if o.filter == filterMedian {
+		return o.median(vals)
+	}
+
+	var sum float64
+	for _, v := range vals {
+		sum += v
+	}
+
+	return sum / float64(len(vals))
+}
+
+// median returns the median of the values. For even number of values the middle value
+// closest to the previous result is used instead of the mean to not leak spikes.
+func (o *filterProvider) median(vals []float64) float64 {
+	sort.Float64s(vals)
+
+	n := len(vals)
+	if n%2 == 1 {
+		return vals[n/2]
+	}
+
+	lo, hi := vals[n/2-1], vals[n/2]
+	if math.Abs(hi-o.res) < math.Abs(lo-o.res) {
+		return hi
+	}
+
+	return lo
+}
+
+func (o *filterProvider) floatGetter() (float64, error) {
+	val, err := o.get()
+	if err != nil {
+		return 0, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := o.clock.Now()
+
+	if o.spike(now, val) {
+		o.log.DEBUG.Printf("rejected spike: %.3f", val)
+		return o.res, nil
+	}
+
+	o.res = o.apply(now, val)
+	o.last = filterSample{ts: now, val: val}
+
+	return o.res, nil
+}
+
+func (o *filterProvider) FloatGetter() func() (float64, error) {
+	return o.floatGetter
+}
+
+func (o *filterProvider) IntGetter() func() (int64, error) {
+	return func() (int64, error) {
+		f, err := o.floatGetter()
+		return int64(math.Round(f)), err
+	}
+}
@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+func newTestFilter(t *testing.T, other map[string]interface{}, vals []float64) (*filterProvider, *clock.Mock) {
+	other["get"] = constConfig(0)
+
+	p, err := NewFilterFromConfig(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := p.(*filterProvider)
+
+	clck := clock.NewMock()
+	o.clock = clck
+
+	var idx int
+	o.get = func() (float64, error) {
+		v := vals[idx]
+		idx++
+		return v, nil
+	}
+
+	return o, clck
+}
+
+func TestFilterProvider(t *testing.T) {
+	tc := []struct {
+		config   map[string]interface{}
+		vals     []float64
+		expected []float64
+	}{
+		{
+			map[string]interface{}{"filter": "average", "window": "30s"},
+			[]float64{100, 200, 300, 400, 500},
+			[]float64{100, 150, 200, 300, 400},
+		},
+		{
+			map[string]interface{}{"filter": "median", "window": "30s"},
+			[]float64{100, 5000, 300, 200, 200},
+			[]float64{100, 100, 300, 300, 200},
+		},
+		{
+			// even number of samples uses middle value closest to previous result
+			map[string]interface{}{"filter": "median", "window": "40s"},
+			[]float64{100, 5000, 300, 200, 200},
+			[]float64{100, 100, 300, 300, 300},
+		},
+		{
+			// alpha = 1 - e^-1
+			map[string]interface{}{"filter": "smooth", "window": "10s"},
+			[]float64{100, 200, 200},
+			[]float64{100, 100 + 100*(1-math.Exp(-1)), 200 - 100*math.Exp(-2)},
+		},
+		{
+			// single spike rejected, persistent change accepted
+			map[string]interface{}{"maxdelta": 1000},
+			[]float64{100, 5000, 200, 3000, 3100},
+			[]float64{100, 100, 200, 200, 3100},
+		},
+		{
+			// consecutive spikes rejected
+			map[string]interface{}{"maxdelta": 1000},
+			[]float64{100, 5000, 9000, 9500, 200},
+			[]float64{100, 100, 100, 9500, 9500},
+		},
+		{
+			// 10s sample interval allows 1000 per sample
+			map[string]interface{}{"maxdelta": 500, "interval": "5s"},
+			[]float64{100, 1000, 2100},
+			[]float64{100, 1000, 1000},
+		},
+	}
+
+	for _, tc := range tc {
+		o, clck := newTestFilter(t, tc.config, tc.vals)
+
+		for i, expected := range tc.expected {
+			res, err := o.FloatGetter()()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if math.Abs(res-expected) > 1e-9 {
+				t.Errorf("%v sample %d: expected %v, got %v", tc.config, i, expected, res)
+			}
+
+			clck.Add(10 * time.Second)
+		}
+	}
+}
+
+func TestFilterProviderConfig(t *testing.T) {
+	tc := []map[string]interface{}{
+		{"get": constConfig(1)},
+		{"get": constConfig(1), "filter": "foo", "window": "1m"},
+		{"get": constConfig(1), "filter": "average"},
+		{"get": map[string]interface{}{"source": "foo"}, "filter": "average", "window": "1m"},
+	}
+
+	for _, tc := range tc {
+		if _, err := NewFilterFromConfig(tc); err == nil {
+			t.Errorf("%v: expected error", tc)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package provider
 
 import (
-	"encoding/base64"
 	"fmt"
 	"io"
 	"math"
@@ -10,48 +9,32 @@ import (
 	"time"
 
 	"github.com/andig/evcc/util"
-	"github.com/andig/evcc/util/jq"
+	"github.com/andig/evcc/util/extract"
 	"github.com/andig/evcc/util/request"
-	"github.com/itchyny/gojq"
 )
 
 // HTTP implements HTTP request provider
 type HTTP struct {
 	*request.Helper
+	log         *util.Logger
 	url, method string
 	headers     map[string]string
 	body        string
 	scale       float64
-	jq          *gojq.Query
+	extract     *extract.Extractor
 }
 
 func init() {
 	registry.Add("http", NewHTTPProviderFromConfig)
 }
 
-// Auth is the authorization config
-type Auth struct {
-	Type, User, Password string
-}
-
-// AuthHeaders creates authorization headers from config
-func AuthHeaders(log *util.Logger, auth Auth, headers map[string]string) error {
-	if strings.ToLower(auth.Type) != "basic" {
-		return fmt.Errorf("unsupported auth type: %s", auth.Type)
-	}
-
-	basicAuth := auth.User + ":" + auth.Password
-	headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(basicAuth))
-	return nil
-}
-
 // NewHTTPProviderFromConfig creates a HTTP provider
 func NewHTTPProviderFromConfig(other map[string]interface{}) (IntProvider, error) {
 	cc := struct {
 		URI, Method string
 		Headers     map[string]string
 		Body        string
-		Jq          string
+		Extract     extract.Config `mapstructure:",squash"`
 		Scale       float64
 		Insecure    bool
 		Auth        Auth
@@ -67,31 +50,32 @@ func NewHTTPProviderFromConfig(other map[string]interface{}) (IntProvider, error
 
 	log := util.NewLogger("http")
 
-	// handle basic auth
-	if cc.Auth.Type != "" {
-		if err := AuthHeaders(log, cc.Auth, cc.Headers); err != nil {
-			return nil, fmt.Errorf("http auth: %w", err)
-		}
-	}
-
 	http, err := NewHTTP(log,
 		cc.Method,
 		cc.URI,
 		cc.Headers,
 		cc.Body,
 		cc.Insecure,
-		cc.Jq,
+		"",
 		cc.Scale,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	if err == nil {
-		http.Client.Timeout = cc.Timeout
+	if http, err = http.WithExtract(cc.Extract); err != nil {
+		return nil, err
+	}
+
+	http.Client.Timeout = cc.Timeout
+
+	if cc.Auth.Type != "" {
+		if http, err = http.WithAuth(cc.Auth); err != nil {
+			return nil, fmt.Errorf("http auth: %w", err)
+		}
 	}
 
-	return http, err
+	return http, nil
 }
 
 // NewHTTP create HTTP provider
@@ -103,6 +87,7 @@ func NewHTTP(log *util.Logger, method, uri string, headers map[string]string, bo
 
 	p := &HTTP{
 		Helper:  request.NewHelper(log),
+		log:     log,
 		url:     url,
 		method:  method,
 		headers: headers,
@@ -115,13 +100,50 @@ func NewHTTP(log *util.Logger, method, uri string, headers map[string]string, bo
 		p.Client.Transport = request.NewTripper(log, request.InsecureTransport())
 	}
 
-	if jq != "" {
-		op, err := gojq.Parse(jq)
+	return p.WithExtract(extract.Config{Jq: jq})
+}
+
+// WithExtract sets the extraction applied to the response
+func (p *HTTP) WithExtract(cc extract.Config) (*HTTP, error) {
+	e, err := extract.New(cc)
+	if err != nil {
+		return nil, err
+	}
+
+	p.extract = e
+
+	return p, nil
+}
+
+// WithAuth adds authorization to the provider's requests
+func (p *HTTP) WithAuth(auth Auth) (*HTTP, error) {
+	switch strings.ToLower(auth.Type) {
+	case "digest":
+		p.Client.Transport = request.NewDigestTransport(p.Client.Transport, auth.User, auth.Password)
+
+	case "oauth2":
+		// token requests use a copy of the client without authorization
+		client := *p.Client
+
+		transport, err := oauthTransport(p.log, auth, &client)
 		if err != nil {
-			return nil, fmt.Errorf("invalid jq query '%s': %w", jq, err)
+			return nil, err
 		}
 
-		p.jq = op
+		p.Client.Transport = transport
+
+	default:
+		// copy headers to not modify shared config
+		headers := make(map[string]string, len(p.headers))
+		for k, v := range p.headers {
+			headers[k] = v
+		}
+
+		if err := AuthHeaders(p.log, auth, headers); err != nil {
+			return nil, err
+		}
+
+		p.headers = headers
 	}
 
 	return p, nil
@@ -180,12 +202,7 @@ func (p *HTTP) StringGetter() func() (string, error) {
 			return string(b), err
 		}
 
-		if p.jq != nil {
-			v, err := jq.Query(p.jq, b)
-			return fmt.Sprintf("%v", v), err
-		}
-
-		return string(b), err
+		return p.extract.Extract(b)
 	}
 }
 
@@ -216,6 +233,13 @@ func (p *HTTP) IntSetter(param string) func(int64) error {
 	}
 }
 
+// FloatSetter sends float request
+func (p *HTTP) FloatSetter(param string) func(float64) error {
+	return func(val float64) error {
+		return p.set(param, val)
+	}
+}
+
 // StringSetter sends string request
 func (p *HTTP) StringSetter(param string) func(string) error {
 	return func(val string) error {
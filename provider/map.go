@@ -0,0 +1,207 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andig/evcc/util"
+)
+
+// mapValue maps an input value or range of input values to the result
+type mapValue struct {
+	Value    *string  // exact match of the input value
+	Min, Max *float64 // inclusive range of numeric input values
+	Result   string
+}
+
+// numeric checks if the value or range only matches numeric input values
+func (m mapValue) numeric() bool {
+	if m.Value != nil {
+		_, err := strconv.ParseFloat(*m.Value, 64)
+		return err == nil
+	}
+
+	return true
+}
+
+// match checks if the input value is mapped
+func (m mapValue) match(s string) bool {
+	if m.Value != nil {
+		if s == *m.Value {
+			return true
+		}
+
+		// numeric comparison, e.g. 1 and 1.0
+		f, err := strconv.ParseFloat(s, 64)
+		v, verr := strconv.ParseFloat(*m.Value, 64)
+		return err == nil && verr == nil && f == v
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false
+	}
+
+	return (m.Min == nil || f >= *m.Min) && (m.Max == nil || f <= *m.Max)
+}
+
+type mapProvider struct {
+	get    func() (string, error)
+	values []mapValue
+	def    *string
+	strict bool
+}
+
+func init() {
+	registry.Add("map", NewMapFromConfig)
+}
+
+// NewMapFromConfig creates map provider. Values are evaluated in order, the first
+// matching value or range determines the result.
+func NewMapFromConfig(other map[string]interface{}) (IntProvider, error) {
+	cc := struct {
+		Get     Config
+		Input   string // number or string, number if all values are numeric
+		Values  []mapValue
+		Default *string
+		Error   bool // error on unmapped values
+	}{}
+
+	if err := util.DecodeOther(other, &cc); err != nil {
+		return nil, err
+	}
+
+	if len(cc.Values) == 0 {
+		return nil, errors.New("missing values")
+	}
+
+	for idx, v := range cc.Values {
+		if v.Value != nil && (v.Min != nil || v.Max != nil) {
+			return nil, fmt.Errorf("values[%d]: cannot have value and range both", idx)
+		}
+
+		if v.Value == nil && v.Min == nil && v.Max == nil {
+			return nil, fmt.Errorf("values[%d]: missing value or range", idx)
+		}
+
+		if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+			return nil, fmt.Errorf("values[%d]: min %v exceeds max %v", idx, *v.Min, *v.Max)
+		}
+	}
+
+	if cc.Default != nil && cc.Error {
+		return nil, errors.New("cannot have default and error both")
+	}
+
+	if cc.Input == "" {
+		cc.Input = mapInputNumber
+		for _, v := range cc.Values {
+			if !v.numeric() {
+				cc.Input = mapInputString
+			}
+		}
+	}
+
+	get, err := mapInput(cc.Get, cc.Input)
+	if err != nil {
+		return nil, fmt.Errorf("get: %w", err)
+	}
+
+	o := &mapProvider{
+		get:    get,
+		values: cc.Values,
+		def:    cc.Default,
+		strict: cc.Error,
+	}
+
+	return o, nil
+}
+
+const (
+	mapInputNumber = "number"
+	mapInputString = "string"
+)
+
+// mapInput creates a string getter from config. Numbers are read using the float getter
+// as string getters of e.g. modbus return raw register content. Numbers are formatted
+// without trailing zeros.
+func mapInput(cc Config, input string) (func() (string, error), error) {
+	switch strings.ToLower(input) {
+	case mapInputString:
+		return NewStringGetterFromConfig(cc)
+
+	case mapInputNumber:
+		f, err := NewFloatGetterFromConfig(cc)
+		if err != nil {
+			return nil, err
+		}
+
+		return func() (string, error) {
+			v, err := f()
+			return strconv.FormatFloat(v, 'f', -1, 64), err
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("invalid input: %s", input)
+	}
+}
+
+func (o *mapProvider) stringGetter() (string, error) {
+	s, err := o.get()
+	if err != nil {
+		return "", err
+	}
+
+	s = strings.TrimSpace(s)
+
+	for _, v := range o.values {
+		if v.match(s) {
+			return v.Result, nil
+		}
+	}
+
+	switch {
+	case o.def != nil:
+		return *o.def, nil
+	case o.strict:
+		return "", fmt.Errorf("unknown value: %s", s)
+	default:
+		return s, nil
+	}
+}
+
+func (o *mapProvider) StringGetter() func() (string, error) {
+	return o.stringGetter
+}
+
+func (o *mapProvider) IntGetter() func() (int64, error) {
+	return func() (int64, error) {
+		s, err := o.stringGetter()
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseInt(s, 10, 64)
+	}
+}
+
+func (o *mapProvider) FloatGetter() func() (float64, error) {
+	return func() (float64, error) {
+		s, err := o.stringGetter()
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseFloat(s, 64)
+	}
+}
+
+func (o *mapProvider) BoolGetter() func() (bool, error) {
+	return func() (bool, error) {
+		s, err := o.stringGetter()
+		if err != nil {
+			return false, err
+		}
+		return strconv.ParseBool(s)
+	}
+}
@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"testing"
+)
+
+// registerProvider mimics modbus returning raw register content as string
+type registerProvider struct {
+	val float64
+}
+
+func (p *registerProvider) IntGetter() func() (int64, error) {
+	return func() (int64, error) { return int64(p.val), nil }
+}
+
+func (p *registerProvider) FloatGetter() func() (float64, error) {
+	return func() (float64, error) { return p.val, nil }
+}
+
+func (p *registerProvider) StringGetter() func() (string, error) {
+	return func() (string, error) { return string([]byte{0, byte(p.val)}), nil }
+}
+
+func init() {
+	registry.Add("register", func(other map[string]interface{}) (IntProvider, error) {
+		return &registerProvider{val: other["value"].(float64)}, nil
+	})
+}
+
+func mapConfig(input interface{}, other map[string]interface{}) map[string]interface{} {
+	other["get"] = constConfig(input)
+	other["values"] = []interface{}{
+		map[string]interface{}{"value": 1, "result": "A"},
+		map[string]interface{}{"value": "connected", "result": "B"},
+		map[string]interface{}{"min": 3, "max": 5, "result": "C"},
+		map[string]interface{}{"min": 10, "result": "F"},
+	}
+	return other
+}
+
+func TestMapProvider(t *testing.T) {
+	tc := []struct {
+		config   map[string]interface{}
+		expected string
+		err      bool
+	}{
+		{mapConfig(1, map[string]interface{}{}), "A", false},
+		{mapConfig("1.0", map[string]interface{}{}), "A", false},
+		{mapConfig("connected", map[string]interface{}{}), "B", false},
+		{mapConfig(3, map[string]interface{}{}), "C", false},
+		{mapConfig(4.5, map[string]interface{}{}), "C", false},
+		{mapConfig(5, map[string]interface{}{}), "C", false},
+		{mapConfig(12, map[string]interface{}{}), "F", false},
+		{mapConfig(2, map[string]interface{}{}), "2", false},
+		{mapConfig(2, map[string]interface{}{"default": "E"}), "E", false},
+		{mapConfig("idle", map[string]interface{}{"error": true}), "", true},
+	}
+
+	for _, tc := range tc {
+		p, err := NewMapFromConfig(tc.config)
+		if err != nil {
+			t.Errorf("%v: %v", tc.config, err)
+			continue
+		}
+
+		res, err := p.(StringProvider).StringGetter()()
+		if (err != nil) != tc.err {
+			t.Errorf("%v: unexpected error %v", tc.config, err)
+		}
+
+		if res != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.config, tc.expected, res)
+		}
+	}
+}
+
+func TestMapProviderNumericSources(t *testing.T) {
+	values := []interface{}{
+		map[string]interface{}{"value": 1, "result": "A"},
+		map[string]interface{}{"value": "3", "result": "C"},
+	}
+
+	tc := []struct {
+		config   map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"get": map[string]interface{}{"source": "register", "value": 3.0}, "values": values}, "C"},
+		{map[string]interface{}{"get": map[string]interface{}{"source": "calc", "add": []interface{}{constConfig(1), constConfig(2)}}, "values": values}, "C"},
+		{map[string]interface{}{"get": constConfig("1"), "values": values, "input": "string"}, "A"},
+		{map[string]interface{}{"get": constConfig("connected"), "values": values, "input": "string"}, "connected"},
+	}
+
+	for _, tc := range tc {
+		p, err := NewMapFromConfig(tc.config)
+		if err != nil {
+			t.Errorf("%v: %v", tc.config, err)
+			continue
+		}
+
+		if res, err := p.(StringProvider).StringGetter()(); err != nil || res != tc.expected {
+			t.Errorf("%v: expected %v, got %q (%v)", tc.config, tc.expected, res, err)
+		}
+	}
+}
+
+func TestMapProviderTypes(t *testing.T) {
+	p, err := NewMapFromConfig(map[string]interface{}{
+		"get": constConfig(3),
+		"values": []interface{}{
+			map[string]interface{}{"value": 3, "result": true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := p.(BoolProvider).BoolGetter()(); err != nil || !b {
+		t.Errorf("expected true, got %v (%v)", b, err)
+	}
+
+	p, err = NewMapFromConfig(map[string]interface{}{
+		"get": constConfig("charging"),
+		"values": []interface{}{
+			map[string]interface{}{"value": "charging", "result": 16},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if i, err := p.(IntProvider).IntGetter()(); err != nil || i != 16 {
+		t.Errorf("expected 16, got %v (%v)", i, err)
+	}
+}
+
+func TestMapProviderConfig(t *testing.T) {
+	tc := []map[string]interface{}{
+		{"get": constConfig(1)},
+		{"get": constConfig(1), "values": []interface{}{map[string]interface{}{"result": "A"}}},
+		{"get": constConfig(1), "values": []interface{}{map[string]interface{}{"value": 1, "min": 1, "result": "A"}}},
+		{"get": constConfig(1), "values": []interface{}{map[string]interface{}{"min": 2, "max": 1, "result": "A"}}},
+		{"get": map[string]interface{}{"source": "foo"}, "values": []interface{}{map[string]interface{}{"value": 1, "result": "A"}}},
+		mapConfig(1, map[string]interface{}{"default": "E", "error": true}),
+		mapConfig(1, map[string]interface{}{"input": "bytes"}),
+	}
+
+	for _, tc := range tc {
+		if _, err := NewMapFromConfig(tc); err == nil {
+			t.Errorf("%v: expected error", tc)
+		}
+	}
+}
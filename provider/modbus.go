@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 
 	"github.com/andig/evcc/util"
@@ -234,26 +235,50 @@ func (m *Modbus) BoolGetter() func() (bool, error) {
 	}
 }
 
+// writeRegister executes configured modbus write operation
+func (m *Modbus) writeRegister(uval uint16) error {
+	// if funccode is configured, execute the write directly
+	op := m.op.MBMD
+	if op.FuncCode == 0 {
+		return errors.New("modbus plugin does not support writing to sunspec")
+	}
+
+	switch op.FuncCode {
+	case modbus.WriteSingleRegister:
+		_, err := m.conn.WriteSingleRegister(op.OpCode, uval)
+		return err
+	default:
+		return fmt.Errorf("unknown function code %d", op.FuncCode)
+	}
+}
+
 // IntSetter executes configured modbus write operation and implements SetIntProvider
 func (m *Modbus) IntSetter(param string) func(int64) error {
 	return func(val int64) error {
-		var err error
+		return m.writeRegister(uint16(int64(m.scale) * val))
+	}
+}
 
-		// if funccode is configured, execute the read directly
-		if op := m.op.MBMD; op.FuncCode != 0 {
-			uval := uint16(int64(m.scale) * val)
+// FloatSetter executes configured modbus write operation and implements SetFloatProvider.
+// The scaled value is rounded to the register's integer value.
+func (m *Modbus) FloatSetter(param string) func(float64) error {
+	return func(val float64) error {
+		return m.writeRegister(uint16(int64(math.Round(m.scale * val))))
+	}
+}
 
-			switch op.FuncCode {
-			case modbus.WriteSingleRegister:
-				_, err = m.conn.WriteSingleRegister(op.OpCode, uval)
-			default:
-				err = fmt.Errorf("unknown function code %d", op.FuncCode)
-			}
-		} else {
-			err = errors.New("modbus plugin does not support writing to sunspec")
+// StringSetter executes configured modbus write operation and implements SetStringProvider.
+// The string is parsed as number as registers cannot hold text.
+func (m *Modbus) StringSetter(param string) func(string) error {
+	set := m.FloatSetter(param)
+
+	return func(val string) error {
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number: %s", val)
 		}
 
-		return err
+		return set(f)
 	}
 }
 
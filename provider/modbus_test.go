@@ -0,0 +1,19 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestModbusStringSetter(t *testing.T) {
+	set := (&Modbus{scale: 1}).StringSetter("mode")
+
+	if err := set("fast"); err == nil || !strings.Contains(err.Error(), "invalid number") {
+		t.Errorf("expected invalid number error, got %v", err)
+	}
+
+	// numeric strings are written to the register
+	if err := set(" 3 "); err == nil || strings.Contains(err.Error(), "invalid number") {
+		t.Errorf("expected write error, got %v", err)
+	}
+}
@@ -6,10 +6,10 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/andig/evcc/api"
 	"github.com/andig/evcc/provider/mqtt"
 	"github.com/andig/evcc/util"
-	"github.com/andig/evcc/util/jq"
-	"github.com/itchyny/gojq"
+	"github.com/andig/evcc/util/extract"
 )
 
 // Mqtt provider
@@ -20,7 +20,7 @@ type Mqtt struct {
 	payload string
 	scale   float64
 	timeout time.Duration
-	jq      *gojq.Query
+	extract *extract.Extractor
 }
 
 func init() {
@@ -34,7 +34,7 @@ func NewMqttFromConfig(other map[string]interface{}) (IntProvider, error) {
 		Topic, Payload string // Payload only applies to setters
 		Scale          float64
 		Timeout        time.Duration
-		Jq             string
+		Extract        extract.Config `mapstructure:",squash"`
 	}{
 		Scale: 1,
 	}
@@ -55,11 +55,8 @@ func NewMqttFromConfig(other map[string]interface{}) (IntProvider, error) {
 	if cc.Payload != "" {
 		m = m.WithPayload(cc.Payload)
 	}
-	if cc.Jq != "" {
-		m, err = m.WithJq(cc.Jq)
-	}
 
-	return m, err
+	return m.WithExtract(cc.Extract)
 }
 
 // NewMqtt creates mqtt provider for given topic
@@ -81,14 +78,14 @@ func (m *Mqtt) WithPayload(payload string) *Mqtt {
 	return m
 }
 
-// WithJq adds a jq query applied to the mqtt listener payload
-func (m *Mqtt) WithJq(jq string) (*Mqtt, error) {
-	op, err := gojq.Parse(jq)
+// WithExtract adds the extraction applied to the mqtt listener payload
+func (m *Mqtt) WithExtract(cc extract.Config) (*Mqtt, error) {
+	e, err := extract.New(cc)
 	if err != nil {
-		return m, fmt.Errorf("invalid jq query '%s': %w", jq, err)
+		return m, err
 	}
 
-	m.jq = op
+	m.extract = e
 
 	return m, nil
 }
@@ -98,10 +95,10 @@ var _ FloatProvider = (*Mqtt)(nil)
 // FloatGetter creates handler for float64 from MQTT topic that returns cached value
 func (m *Mqtt) FloatGetter() func() (float64, error) {
 	h := &msgHandler{
-		topic: m.topic,
-		scale: m.scale,
-		mux:   util.NewWaiter(m.timeout, func() { m.log.TRACE.Printf("%s wait for initial value", m.topic) }),
-		jq:    m.jq,
+		topic:   m.topic,
+		scale:   m.scale,
+		mux:     util.NewWaiter(m.timeout, func() { m.log.TRACE.Printf("%s wait for initial value", m.topic) }),
+		extract: m.extract,
 	}
 
 	m.client.Listen(m.topic, h.receive)
@@ -113,10 +110,10 @@ var _ IntProvider = (*Mqtt)(nil)
 // IntGetter creates handler for int64 from MQTT topic that returns cached value
 func (m *Mqtt) IntGetter() func() (int64, error) {
 	h := &msgHandler{
-		topic: m.topic,
-		scale: float64(m.scale),
-		mux:   util.NewWaiter(m.timeout, func() { m.log.TRACE.Printf("%s wait for initial value", m.topic) }),
-		jq:    m.jq,
+		topic:   m.topic,
+		scale:   float64(m.scale),
+		mux:     util.NewWaiter(m.timeout, func() { m.log.TRACE.Printf("%s wait for initial value", m.topic) }),
+		extract: m.extract,
 	}
 
 	m.client.Listen(m.topic, h.receive)
@@ -128,9 +125,9 @@ var _ StringProvider = (*Mqtt)(nil)
 // StringGetter creates handler for string from MQTT topic that returns cached value
 func (m *Mqtt) StringGetter() func() (string, error) {
 	h := &msgHandler{
-		topic: m.topic,
-		mux:   util.NewWaiter(m.timeout, func() { m.log.TRACE.Printf("%s wait for initial value", m.topic) }),
-		jq:    m.jq,
+		topic:   m.topic,
+		mux:     util.NewWaiter(m.timeout, func() { m.log.TRACE.Printf("%s wait for initial value", m.topic) }),
+		extract: m.extract,
 	}
 
 	m.client.Listen(m.topic, h.receive)
@@ -142,9 +139,9 @@ var _ BoolProvider = (*Mqtt)(nil)
 // BoolGetter creates handler for string from MQTT topic that returns cached value
 func (m *Mqtt) BoolGetter() func() (bool, error) {
 	h := &msgHandler{
-		topic: m.topic,
-		mux:   util.NewWaiter(m.timeout, func() { m.log.TRACE.Printf("%s wait for initial value", m.topic) }),
-		jq:    m.jq,
+		topic:   m.topic,
+		mux:     util.NewWaiter(m.timeout, func() { m.log.TRACE.Printf("%s wait for initial value", m.topic) }),
+		extract: m.extract,
 	}
 
 	m.client.Listen(m.topic, h.receive)
@@ -165,6 +162,34 @@ func (m *Mqtt) IntSetter(param string) func(int64) error {
 	}
 }
 
+var _ SetFloatProvider = (*Mqtt)(nil)
+
+// FloatSetter publishes topic with parameter replaced by float value
+func (m *Mqtt) FloatSetter(param string) func(float64) error {
+	return func(v float64) error {
+		payload, err := setFormattedValue(m.payload, param, v)
+		if err != nil {
+			return err
+		}
+
+		return m.client.Publish(m.topic, false, payload)
+	}
+}
+
+var _ SetStringProvider = (*Mqtt)(nil)
+
+// StringSetter publishes topic with parameter replaced by string value
+func (m *Mqtt) StringSetter(param string) func(string) error {
+	return func(v string) error {
+		payload, err := setFormattedValue(m.payload, param, v)
+		if err != nil {
+			return err
+		}
+
+		return m.client.Publish(m.topic, false, payload)
+	}
+}
+
 var _ SetBoolProvider = (*Mqtt)(nil)
 
 // BoolSetter invokes script with parameter replaced by bool value
@@ -179,26 +204,12 @@ func (m *Mqtt) BoolSetter(param string) func(bool) error {
 	}
 }
 
-// var _ SetStringProvider = (*Mqtt)(nil)
-
-// // StringSetter invokes script with parameter replaced by string value
-// func (m *Mqtt) StringSetter(param string) func(string) error {
-// 	return func(v string) error {
-// 		payload, err := setFormattedValue(m.payload, param, v)
-// 		if err != nil {
-// 			return err
-// 		}
-
-// 		return m.client.Publish(m.topic, false, payload)
-// 	}
-// }
-
 type msgHandler struct {
 	mux     *util.Waiter
 	scale   float64
 	topic   string
 	payload string
-	jq      *gojq.Query
+	extract *extract.Extractor
 }
 
 func (h *msgHandler) receive(payload string) {
@@ -214,20 +225,14 @@ func (h *msgHandler) hasValue() (string, error) {
 	defer h.mux.Unlock()
 
 	if elapsed > 0 {
-		return "", fmt.Errorf("%s outdated: %v", h.topic, elapsed.Truncate(time.Second))
+		return "", fmt.Errorf("%s %w: %v", h.topic, api.ErrOutdated, elapsed.Truncate(time.Second))
 	}
 
-	var err error
-	payload := h.payload
-
-	if h.jq != nil {
-		var val interface{}
-		if val, err = jq.Query(h.jq, []byte(payload)); err == nil {
-			payload = fmt.Sprintf("%v", val)
-		}
+	if h.extract != nil {
+		return h.extract.Extract([]byte(h.payload))
 	}
 
-	return payload, err
+	return h.payload, nil
 }
 
 func (h *msgHandler) floatGetter() (float64, error) {
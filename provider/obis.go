@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"math"
+	"time"
+
+	"github.com/andig/evcc/provider/obis"
+	"github.com/andig/evcc/util"
+)
+
+// OBIS provider reads values of smart meters with SML or D0 interface
+type OBIS struct {
+	reader *obis.Reader
+	code   string
+	scale  float64
+}
+
+func init() {
+	registry.Add("sml", NewSMLFromConfig)
+	registry.Add("d0", NewD0FromConfig)
+}
+
+// NewSMLFromConfig creates SML provider
+func NewSMLFromConfig(other map[string]interface{}) (IntProvider, error) {
+	return newOBISFromConfig(obis.SML, "8N1", other)
+}
+
+// NewD0FromConfig creates D0 provider
+func NewD0FromConfig(other map[string]interface{}) (IntProvider, error) {
+	return newOBISFromConfig(obis.D0, "7E1", other)
+}
+
+func newOBISFromConfig(protocol obis.Protocol, comset string, other map[string]interface{}) (IntProvider, error) {
+	cc := struct {
+		URI, Device string
+		Baudrate    int
+		Comset      string
+		Obis        string
+		Scale       float64
+		Timeout     time.Duration
+		Interval    time.Duration
+	}{
+		Baudrate: 9600,
+		Comset:   comset,
+		Scale:    1,
+		Timeout:  30 * time.Second,
+	}
+
+	if err := util.DecodeOther(other, &cc); err != nil {
+		return nil, err
+	}
+
+	code, err := obis.Normalize(cc.Obis)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := obis.NewReader(protocol, cc.URI, cc.Device, cc.Baudrate, cc.Comset, cc.Timeout, cc.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &OBIS{
+		reader: reader,
+		code:   code,
+		scale:  cc.Scale,
+	}
+
+	return o, nil
+}
+
+// FloatGetter creates handler for float64
+func (o *OBIS) FloatGetter() func() (float64, error) {
+	return func() (float64, error) {
+		f, err := o.reader.Value(o.code)
+		return f * o.scale, err
+	}
+}
+
+// IntGetter creates handler for int64
+func (o *OBIS) IntGetter() func() (int64, error) {
+	return func() (int64, error) {
+		f, err := o.FloatGetter()()
+		return int64(math.Round(f)), err
+	}
+}
@@ -0,0 +1,120 @@
+package obis
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// d0Regex matches data lines like 1-0:1.8.0*255(001234.5678*kWh)
+var d0Regex = regexp.MustCompile(`^(?:\d+-\d+:)?(\d+\.\d+\.\d+)(?:\*\d+)?\(([^)*]*)(?:\*([^)]*))?\)`)
+
+const (
+	d0SignOn = "/?!\r\n"     // request message
+	d0Ack    = "\x06000\r\n" // data readout without baudrate change
+)
+
+// d0Request requests telegrams from IEC 62056-21 mode A and C meters. The sign-on
+// is answered by the meter's identification line which is acknowledged to start the
+// data readout in mode C. Mode A meters send the data without acknowledgement.
+type d0Request struct {
+	io.Reader
+	w       io.Writer
+	pending bool   // identification line not yet acknowledged
+	line    []byte // current line
+}
+
+// signOn sends the request message
+func (d *d0Request) signOn() error {
+	d.pending = true
+	d.line = d.line[:0]
+	_, err := io.WriteString(d.w, d0SignOn)
+	return err
+}
+
+// Read reads from the connection and acknowledges the identification line
+func (d *d0Request) Read(b []byte) (int, error) {
+	n, err := d.Reader.Read(b)
+
+	for _, c := range b[:n] {
+		if !d.pending {
+			break
+		}
+
+		if c == '/' {
+			d.line = d.line[:0]
+		}
+		d.line = append(d.line, c)
+
+		if c != '\n' {
+			continue
+		}
+
+		// identification line received, ignoring echo of the request
+		if d.line[0] == '/' && string(d.line) != d0SignOn {
+			d.pending = false
+			if _, werr := io.WriteString(d.w, d0Ack); werr != nil && err == nil {
+				err = werr
+			}
+		}
+
+		d.line = d.line[:0]
+	}
+
+	return n, err
+}
+
+// ScanD0 is a bufio.SplitFunc returning complete IEC 62056-21 telegrams from
+// the identification line starting with / to the end line starting with !
+func ScanD0(data []byte, atEOF bool) (int, []byte, error) {
+	start := bytes.IndexByte(data, '/')
+	if start < 0 {
+		return len(data), nil, nil
+	}
+
+	if end := bytes.Index(data[start:], []byte("\n!")); end >= 0 {
+		end += start + 1
+
+		if eol := bytes.IndexByte(data[end:], '\n'); eol >= 0 {
+			end += eol + 1
+			return end, data[start:end], nil
+		}
+	}
+
+	if atEOF {
+		return len(data), nil, nil
+	}
+
+	// request more data
+	return start, nil, nil
+}
+
+// ParseD0 parses the data lines of a complete telegram
+func ParseD0(telegram []byte) (Values, error) {
+	res := make(Values)
+
+	scanner := bufio.NewScanner(bytes.NewReader(telegram))
+	for scanner.Scan() {
+		match := d0Regex.FindStringSubmatch(string(bytes.TrimSpace(scanner.Bytes())))
+		if match == nil {
+			continue
+		}
+
+		// ignore non-numeric values like meter ids
+		val, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			continue
+		}
+
+		res[match[1]] = scale(val, match[3])
+	}
+
+	if len(res) == 0 {
+		return nil, errors.New("no values")
+	}
+
+	return res, nil
+}
@@ -0,0 +1,129 @@
+package obis
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andig/evcc/util"
+)
+
+// d0Fixture is a telegram of an EasyMeter Q3D meter
+const d0Fixture = "/ESY5Q3DA1004 V3.04\r\n\r\n" +
+	"1-0:0.0.0*255(1ESY1160000000)\r\n" +
+	"1-0:1.8.0*255(00012345.6789*kWh)\r\n" +
+	"1-0:2.8.0*255(00000123.4500*kWh)\r\n" +
+	"1-0:16.7.0*255(000456.78*W)\r\n" +
+	"1-0:32.7.0*255(230.1*V)\r\n" +
+	"1-0:36.7.0*255(0.12*kW)\r\n" +
+	"0-0:96.1.255*255(1ESY1160000000)\r\n" +
+	"!\r\n"
+
+func TestParseD0(t *testing.T) {
+	values, err := ParseD0([]byte(d0Fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Values{
+		EnergyImport: 12345.6789,
+		EnergyExport: 123.45,
+		Power:        456.78,
+		VoltageL1:    230.1,
+		PowerL1:      120,
+	}
+
+	if len(values) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+
+	for code, val := range expected {
+		if res, ok := values[code]; !ok || res != val {
+			t.Errorf("%s: expected %v, got %v", code, val, res)
+		}
+	}
+}
+
+func TestScanD0(t *testing.T) {
+	stream := "1-0:1.8.0*255(00012345.6789*kWh)\r\n!\r\n" + d0Fixture + d0Fixture + "/ESY5Q3DA1004"
+
+	scanner := bufio.NewScanner(strings.NewReader(stream))
+	scanner.Split(ScanD0)
+
+	var telegrams int
+	for scanner.Scan() {
+		if res := scanner.Text(); res != d0Fixture {
+			t.Errorf("unexpected telegram: %q", res)
+		}
+		telegrams++
+	}
+
+	if telegrams != 2 {
+		t.Errorf("expected 2 telegrams, got %d", telegrams)
+	}
+}
+
+// d0Meter simulates an IEC 62056-21 mode C meter echoing all requests
+type d0Meter struct {
+	out      bytes.Buffer
+	requests int
+	acks     int
+}
+
+func (m *d0Meter) Write(b []byte) (int, error) {
+	m.out.Write(b) // echo
+
+	switch string(b) {
+	case d0SignOn:
+		m.requests++
+		m.out.WriteString(strings.SplitAfterN(d0Fixture, "\n", 2)[0])
+	case d0Ack:
+		m.acks++
+		m.out.WriteString(strings.SplitAfterN(d0Fixture, "\n", 2)[1])
+	}
+
+	return len(b), nil
+}
+
+func (m *d0Meter) Read(b []byte) (int, error) {
+	if m.requests > 2 {
+		return 0, io.EOF
+	}
+
+	// send in small chunks
+	if len(b) > 8 {
+		b = b[:8]
+	}
+
+	return m.out.Read(b)
+}
+
+func TestReaderD0Request(t *testing.T) {
+	r := &Reader{
+		log:      util.NewLogger("foo"),
+		mux:      util.NewWaiter(0, func() {}),
+		split:    ScanD0,
+		parse:    ParseD0,
+		interval: time.Millisecond,
+		values:   make(Values),
+	}
+
+	// don't start connection
+	r.started.Do(func() {})
+
+	meter := new(d0Meter)
+	if err := r.read(meter); err == nil {
+		t.Error("expected EOF")
+	}
+
+	if meter.requests != 3 || meter.acks != 2 {
+		t.Errorf("expected 3 requests and 2 acks, got %d and %d", meter.requests, meter.acks)
+	}
+
+	if res, err := r.Value(Power); err != nil || res != 456.78 {
+		t.Errorf("expected 456.78, got %v (%v)", res, err)
+	}
+}
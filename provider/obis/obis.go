@@ -0,0 +1,51 @@
+package obis
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Common OBIS codes of electricity meters
+const (
+	EnergyImport = "1.8.0"  // total imported energy in kWh
+	EnergyExport = "2.8.0"  // total exported energy in kWh
+	Power        = "16.7.0" // sum of active power in W
+	PowerL1      = "36.7.0" // active power L1 in W
+	PowerL2      = "56.7.0" // active power L2 in W
+	PowerL3      = "76.7.0" // active power L3 in W
+	CurrentL1    = "31.7.0" // current L1 in A
+	CurrentL2    = "51.7.0" // current L2 in A
+	CurrentL3    = "71.7.0" // current L3 in A
+	VoltageL1    = "32.7.0" // voltage L1 in V
+	VoltageL2    = "52.7.0" // voltage L2 in V
+	VoltageL3    = "72.7.0" // voltage L3 in V
+)
+
+// codeRegex matches the value group C.D.E of full A-B:C.D.E*F or short OBIS codes
+var codeRegex = regexp.MustCompile(`^(?:\d+-\d+:)?(\d+\.\d+\.\d+)(?:\*\d+)?$`)
+
+// Normalize converts OBIS codes to their short C.D.E form
+func Normalize(code string) (string, error) {
+	match := codeRegex.FindStringSubmatch(code)
+	if match == nil {
+		return "", fmt.Errorf("invalid obis code: %s", code)
+	}
+
+	return match[1], nil
+}
+
+// Values are the meter readings by short OBIS code. Energy is
+// converted to kWh and power to W.
+type Values map[string]float64
+
+// scale returns the value converted to kWh or W according to its unit
+func scale(val float64, unit string) float64 {
+	switch unit {
+	case "Wh":
+		return val / 1e3
+	case "kW", "MWh":
+		return val * 1e3
+	default:
+		return val
+	}
+}
@@ -0,0 +1,76 @@
+package obis
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/util"
+)
+
+func TestNormalize(t *testing.T) {
+	tc := []struct {
+		code, expected string
+	}{
+		{"1.8.0", "1.8.0"},
+		{"1-0:1.8.0", "1.8.0"},
+		{"1-0:16.7.0*255", "16.7.0"},
+		{"1.8", ""},
+		{"foo", ""},
+	}
+
+	for _, tc := range tc {
+		res, err := Normalize(tc.code)
+		if res != tc.expected || (err != nil) != (tc.expected == "") {
+			t.Errorf("%s: expected %s, got %s (%v)", tc.code, tc.expected, res, err)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	r := &Reader{
+		log:    util.NewLogger("foo"),
+		mux:    util.NewWaiter(0, func() {}),
+		split:  ScanSML,
+		parse:  ParseSML,
+		values: make(Values),
+	}
+
+	// don't start connection
+	r.started.Do(func() {})
+
+	if err := r.read(bytes.NewReader(fixture(t, smlFixture))); err == nil {
+		t.Error("expected EOF")
+	}
+
+	if res, err := r.Value(Power); err != nil || res != -338 {
+		t.Errorf("expected -338, got %v (%v)", res, err)
+	}
+
+	if _, err := r.Value(CurrentL1); !errors.Is(err, api.ErrNotAvailable) {
+		t.Errorf("expected not available, got %v", err)
+	}
+}
+
+func TestNewReaderShared(t *testing.T) {
+	r1, err := NewReader(D0, "", "/dev/ttyTest", 300, "7E1", time.Minute, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// conflicting settings are ignored
+	r2, err := NewReader(D0, "", "/dev/ttyTest", 9600, "7E1", time.Minute, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r1 != r2 || r2.interval != 10*time.Second || r2.settings != "baudrate 300, comset 7E1, interval 10s" {
+		t.Errorf("expected shared reader, got %+v", r2)
+	}
+
+	if _, err := NewReader(SML, "", "/dev/ttyTest", 9600, "8N1", time.Minute, time.Second); err == nil {
+		t.Error("expected error for sml request interval")
+	}
+}
@@ -0,0 +1,257 @@
+package obis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/util"
+	"github.com/grid-x/serial"
+)
+
+const (
+	readTimeout = time.Minute     // reconnect if no data received
+	retryDelay  = 5 * time.Second // delay before reconnecting
+)
+
+// Protocol is the meter's data protocol
+type Protocol string
+
+// Supported protocols
+const (
+	SML Protocol = "sml"
+	D0  Protocol = "d0"
+)
+
+// Reader continuously reads meter values from a serial device or TCP serial bridge
+type Reader struct {
+	log      *util.Logger
+	mux      *util.Waiter
+	open     func() (io.ReadWriteCloser, error)
+	split    bufio.SplitFunc
+	parse    func([]byte) (Values, error)
+	settings string        // connection settings for detecting conflicting configurations
+	interval time.Duration // request interval of D0 meters not sending without request
+	values   Values
+	started  sync.Once
+}
+
+var (
+	mu      sync.Mutex
+	readers = make(map[string]*Reader)
+)
+
+// NewReader returns the shared reader of the given uri or device. Timeout is the maximum
+// age of values, the first reader's settings apply to all values of the connection.
+// If interval is given, D0 telegrams are requested in interval (IEC 62056-21 mode A and C).
+func NewReader(protocol Protocol, uri, device string, baudrate int, comset string, timeout, interval time.Duration) (*Reader, error) {
+	var split bufio.SplitFunc
+	var parse func([]byte) (Values, error)
+
+	switch protocol {
+	case SML:
+		split, parse = ScanSML, ParseSML
+	case D0:
+		split, parse = ScanD0, ParseD0
+	default:
+		return nil, fmt.Errorf("invalid protocol: %s", protocol)
+	}
+
+	if interval > 0 && protocol != D0 {
+		return nil, fmt.Errorf("request interval not supported by %s", protocol)
+	}
+
+	var key, settings string
+	var open func() (io.ReadWriteCloser, error)
+
+	switch {
+	case uri != "" && device != "":
+		return nil, fmt.Errorf("cannot have uri and device both")
+
+	case uri != "":
+		key = uri
+		open = func() (io.ReadWriteCloser, error) {
+			conn, err := net.DialTimeout("tcp", uri, 10*time.Second)
+			if err != nil {
+				return nil, err
+			}
+			return &deadlineConn{conn}, nil
+		}
+
+	case device != "":
+		config, err := serialConfig(device, baudrate, comset)
+		if err != nil {
+			return nil, err
+		}
+
+		key = device
+		settings = fmt.Sprintf("baudrate %d, comset %s", baudrate, strings.ToUpper(comset))
+		open = func() (io.ReadWriteCloser, error) {
+			return serial.Open(config)
+		}
+
+	default:
+		return nil, fmt.Errorf("missing uri or device")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	key = string(protocol) + ":" + key
+
+	if interval > 0 {
+		settings = strings.TrimPrefix(fmt.Sprintf("%s, interval %v", settings, interval), ", ")
+	}
+
+	if r, ok := readers[key]; ok {
+		if r.settings != settings {
+			r.log.WARN.Printf("%s: ignoring %s, already using %s", key, orDefault(settings), orDefault(r.settings))
+		}
+
+		return r, nil
+	}
+
+	log := util.NewLogger(string(protocol))
+
+	r := &Reader{
+		log:      log,
+		mux:      util.NewWaiter(timeout, func() { log.TRACE.Println("wait for initial value") }),
+		open:     open,
+		split:    split,
+		parse:    parse,
+		settings: settings,
+		interval: interval,
+		values:   make(Values),
+	}
+
+	readers[key] = r
+
+	return r, nil
+}
+
+// orDefault returns the settings or a placeholder for default settings
+func orDefault(settings string) string {
+	if settings == "" {
+		return "default settings"
+	}
+	return settings
+}
+
+// serialConfig creates the serial port configuration from communication set like 8N1 or 7E1
+func serialConfig(device string, baudrate int, comset string) (*serial.Config, error) {
+	comset = strings.ToUpper(comset)
+	if len(comset) != 3 || !strings.Contains("5678", comset[:1]) || !strings.Contains("NEO", comset[1:2]) || !strings.Contains("12", comset[2:]) {
+		return nil, fmt.Errorf("invalid comset: %s", comset)
+	}
+
+	return &serial.Config{
+		Address:  device,
+		BaudRate: baudrate,
+		DataBits: int(comset[0] - '0'),
+		Parity:   comset[1:2],
+		StopBits: int(comset[2] - '0'),
+		Timeout:  readTimeout,
+	}, nil
+}
+
+// deadlineConn applies the read timeout to each read
+type deadlineConn struct {
+	net.Conn
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+// run reads from the connection and reconnects on errors
+func (r *Reader) run() {
+	for {
+		conn, err := r.open()
+		if err != nil {
+			r.log.ERROR.Println(err)
+			time.Sleep(retryDelay)
+			continue
+		}
+
+		if err := r.read(conn); err != nil {
+			r.log.ERROR.Println(err)
+		}
+
+		_ = conn.Close()
+		time.Sleep(retryDelay)
+	}
+}
+
+// read parses the values of all frames until the connection fails
+func (r *Reader) read(conn io.Reader) error {
+	var req *d0Request
+	if w, ok := conn.(io.Writer); ok && r.interval > 0 {
+		req = &d0Request{Reader: conn, w: w}
+		if err := req.signOn(); err != nil {
+			return err
+		}
+
+		conn = req
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Split(r.split)
+
+	for scanner.Scan() {
+		values, err := r.parse(scanner.Bytes())
+		if err != nil {
+			r.log.DEBUG.Printf("invalid frame: %v", err)
+			continue
+		}
+
+		r.log.TRACE.Printf("recv: %v", values)
+
+		r.mux.Lock()
+		for code, val := range values {
+			r.values[code] = val
+		}
+		r.mux.Update()
+		r.mux.Unlock()
+
+		// request next telegram
+		if req != nil {
+			time.Sleep(r.interval)
+			if err := req.signOn(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return io.EOF
+}
+
+// Value returns the value of the OBIS code
+func (r *Reader) Value(code string) (float64, error) {
+	r.started.Do(func() { go r.run() })
+
+	elapsed := r.mux.LockWithTimeout()
+	defer r.mux.Unlock()
+
+	if elapsed > 0 {
+		return 0, fmt.Errorf("%w: %v", api.ErrOutdated, elapsed.Truncate(time.Second))
+	}
+
+	val, ok := r.values[code]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", code, api.ErrNotAvailable)
+	}
+
+	return val, nil
+}
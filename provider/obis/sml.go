@@ -0,0 +1,316 @@
+package obis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// SML transport escape sequences
+var (
+	smlEscape = []byte{0x1b, 0x1b, 0x1b, 0x1b}
+	smlStart  = []byte{0x1b, 0x1b, 0x1b, 0x1b, 0x01, 0x01, 0x01, 0x01}
+)
+
+// SML units as of DLMS
+const (
+	smlUnitWatt     = 27
+	smlUnitWattHour = 30
+	smlUnitVolt     = 35
+	smlUnitAmpere   = 33
+)
+
+// SML element types
+const (
+	smlOctetString = 0
+	smlBoolean     = 4
+	smlInteger     = 5
+	smlUnsigned    = 6
+	smlList        = 7
+)
+
+// smlFrameEnd returns the index of the end escape sequence of the frame starting at start.
+// Escape sequences are aligned to 4 bytes, escaped escape sequences are skipped.
+func smlFrameEnd(data []byte, start int) int {
+	for i := start + len(smlStart); i+8 <= len(data); i += 4 {
+		if !bytes.Equal(data[i:i+4], smlEscape) {
+			continue
+		}
+
+		switch {
+		case bytes.Equal(data[i+4:i+8], smlEscape):
+			i += 4 // escaped escape sequence
+		case data[i+4] == 0x1a:
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ScanSML is a bufio.SplitFunc returning complete SML transport frames
+func ScanSML(data []byte, atEOF bool) (int, []byte, error) {
+	start := bytes.Index(data, smlStart)
+	if start < 0 {
+		if atEOF {
+			return len(data), nil, nil
+		}
+
+		// keep possible beginning of start sequence
+		skip := len(data) - len(smlStart) + 1
+		if skip < 0 {
+			skip = 0
+		}
+
+		return skip, nil, nil
+	}
+
+	end := smlFrameEnd(data, start)
+
+	// start sequences cannot be part of a frame, discard incomplete frame
+	if next := bytes.Index(data[start+len(smlStart):], smlStart); next >= 0 {
+		if next += start + len(smlStart); end < 0 || next < end {
+			return next, nil, nil
+		}
+	}
+
+	if end >= 0 {
+		return end + 8, data[start : end+8], nil
+	}
+
+	if atEOF {
+		return len(data), nil, nil
+	}
+
+	// request more data
+	return start, nil, nil
+}
+
+// crc16 calculates the CRC-16/X-25 checksum
+func crc16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0x8408
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+
+	return crc ^ 0xffff
+}
+
+// smlElement is a decoded SML element
+type smlElement struct {
+	typ  byte
+	data []byte       // octet string
+	num  int64        // integer, unsigned and boolean
+	list []smlElement // list
+}
+
+// smlDecoder decodes SML elements
+type smlDecoder struct {
+	buf []byte
+	pos int
+}
+
+// typeLength reads the type-length field. The returned length excludes the type-length bytes for non-list types.
+func (d *smlDecoder) typeLength() (byte, int, error) {
+	start := d.pos
+
+	if d.pos >= len(d.buf) {
+		return 0, 0, errors.New("unexpected end of data")
+	}
+
+	b := d.buf[d.pos]
+	d.pos++
+
+	typ := (b >> 4) & 0x07
+	length := int(b & 0x0f)
+
+	for b&0x80 != 0 {
+		if d.pos >= len(d.buf) {
+			return 0, 0, errors.New("unexpected end of data")
+		}
+
+		b = d.buf[d.pos]
+		d.pos++
+		length = length<<4 | int(b&0x0f)
+	}
+
+	if typ != smlList {
+		length -= d.pos - start
+		if length < 0 {
+			return 0, 0, fmt.Errorf("invalid length at %d", start)
+		}
+	}
+
+	return typ, length, nil
+}
+
+// element decodes the next element
+func (d *smlDecoder) element() (smlElement, error) {
+	typ, length, err := d.typeLength()
+	if err != nil {
+		return smlElement{}, err
+	}
+
+	el := smlElement{typ: typ}
+
+	if typ == smlList {
+		for i := 0; i < length; i++ {
+			child, err := d.element()
+			if err != nil {
+				return el, err
+			}
+			el.list = append(el.list, child)
+		}
+
+		return el, nil
+	}
+
+	if d.pos+length > len(d.buf) {
+		return el, errors.New("unexpected end of data")
+	}
+
+	data := d.buf[d.pos : d.pos+length]
+	d.pos += length
+
+	switch typ {
+	case smlOctetString:
+		el.data = data
+
+	case smlBoolean, smlUnsigned, smlInteger:
+		if length > 8 {
+			return el, fmt.Errorf("invalid number length: %d", length)
+		}
+
+		var u uint64
+		for _, b := range data {
+			u = u<<8 | uint64(b)
+		}
+
+		el.num = int64(u)
+
+		// sign extension
+		if typ == smlInteger && length > 0 && length < 8 && data[0]&0x80 != 0 {
+			el.num -= 1 << (8 * uint(length))
+		}
+
+	default:
+		return el, fmt.Errorf("invalid type: %d", typ)
+	}
+
+	return el, nil
+}
+
+// unescape removes escaped escape sequences from the frame payload
+func unescape(data []byte) []byte {
+	res := make([]byte, 0, len(data))
+
+	for i := 0; i < len(data); i += 4 {
+		end := i + 4
+		if end > len(data) {
+			end = len(data)
+		}
+
+		res = append(res, data[i:end]...)
+
+		if end+4 <= len(data) && bytes.Equal(data[i:end], smlEscape) && bytes.Equal(data[end:end+4], smlEscape) {
+			i += 4
+		}
+	}
+
+	return res
+}
+
+// ParseSML parses the list entries of a complete SML transport frame
+func ParseSML(frame []byte) (Values, error) {
+	if len(frame) < 16 || !bytes.HasPrefix(frame, smlStart) {
+		return nil, errors.New("invalid frame")
+	}
+
+	// checksum covers the frame including end sequence and padding count
+	n := len(frame)
+	if crc := crc16(frame[:n-2]); crc != uint16(frame[n-1])<<8|uint16(frame[n-2]) {
+		return nil, errors.New("invalid checksum")
+	}
+
+	padding := int(frame[n-3])
+	payload := unescape(frame[len(smlStart) : n-8])
+	if padding > len(payload) {
+		return nil, errors.New("invalid padding")
+	}
+	payload = payload[:len(payload)-padding]
+
+	res := make(Values)
+
+	// list entries are lists of 7 elements starting with a 6-byte object name
+	for i := 0; i+2 < len(payload); i++ {
+		if payload[i] != 0x77 || payload[i+1] != 0x07 {
+			continue
+		}
+
+		d := &smlDecoder{buf: payload, pos: i}
+
+		entry, err := d.element()
+		if err != nil || len(entry.list) != 7 {
+			continue
+		}
+
+		if code, val, ok := smlListEntry(entry.list); ok {
+			res[code] = val
+			i = d.pos - 1
+		}
+	}
+
+	if len(res) == 0 {
+		return nil, errors.New("no values")
+	}
+
+	return res, nil
+}
+
+// smlListEntry decodes objName, status, valTime, unit, scaler, value and signature of a list entry
+func smlListEntry(entry []smlElement) (string, float64, bool) {
+	name, unit, scaler, value := entry[0], entry[3], entry[4], entry[5]
+
+	if len(name.data) != 6 {
+		return "", 0, false
+	}
+
+	if value.typ != smlInteger && value.typ != smlUnsigned {
+		return "", 0, false
+	}
+
+	val := float64(value.num)
+	if value.typ == smlUnsigned {
+		val = float64(uint64(value.num))
+	}
+
+	if scaler.typ == smlInteger {
+		val *= math.Pow10(int(scaler.num))
+	}
+
+	var u string
+	if unit.typ == smlUnsigned {
+		switch unit.num {
+		case smlUnitWattHour:
+			u = "Wh"
+		case smlUnitWatt:
+			u = "W"
+		case smlUnitVolt:
+			u = "V"
+		case smlUnitAmpere:
+			u = "A"
+		}
+	}
+
+	code := fmt.Sprintf("%d.%d.%d", name.data[2], name.data[3], name.data[4])
+
+	return code, scale(val, u), true
+}
@@ -0,0 +1,107 @@
+package obis
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+// smlFixture is a frame of an EMH eHZ meter
+const smlFixture = "1b1b1b1b010101017605004b2f63620062007263070177010b0a01454d480000b8ef1a070100620affff72620165001c5f3c7677078181c78203ff0101010104454d480177070100000009ff010101010b0a01454d480000b8ef1a0177070100010800ff650000018201621e52ff590000000000b3ba6c0177070100020800ff650000018201621e52ff59000000000007fcc00177070100100700ff0101621b520055fffffeae0177070100240700ff0101621b52fe633039010101630000001b1b1b1b1a007d69"
+
+func fixture(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCRC16(t *testing.T) {
+	if crc := crc16([]byte("123456789")); crc != 0x906e {
+		t.Errorf("expected 0x906e, got %#x", crc)
+	}
+}
+
+func TestParseSML(t *testing.T) {
+	values, err := ParseSML(fixture(t, smlFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Values{
+		EnergyImport: 1177.8668,
+		EnergyExport: 52.3456,
+		Power:        -338,
+		PowerL1:      123.45,
+	}
+
+	if len(values) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+
+	for code, val := range expected {
+		if res, ok := values[code]; !ok || math.Abs(res-val) > 1e-9 {
+			t.Errorf("%s: expected %v, got %v", code, val, res)
+		}
+	}
+}
+
+func TestParseSMLInvalid(t *testing.T) {
+	frame := fixture(t, smlFixture)
+	frame[100] ^= 0xff
+
+	if _, err := ParseSML(frame); err == nil {
+		t.Error("expected checksum error")
+	}
+
+	if _, err := ParseSML(frame[:20]); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestScanSML(t *testing.T) {
+	frame := fixture(t, smlFixture)
+
+	// garbage, frame, incomplete frame start, frame
+	var stream []byte
+	stream = append(stream, 0x1b, 0x1b, 0x00, 0x42)
+	stream = append(stream, frame...)
+	stream = append(stream, frame[:10]...)
+	stream = append(stream, frame...)
+
+	scanner := bufio.NewScanner(bytes.NewReader(stream))
+	scanner.Buffer(make([]byte, 16), 1024) // force partial reads
+	scanner.Split(ScanSML)
+
+	var frames int
+	for scanner.Scan() {
+		if !bytes.Equal(scanner.Bytes(), frame) {
+			t.Errorf("unexpected frame: %x", scanner.Bytes())
+		}
+		frames++
+	}
+
+	if err := scanner.Err(); err != nil {
+		t.Error(err)
+	}
+
+	if frames != 2 {
+		t.Errorf("expected 2 frames, got %d", frames)
+	}
+}
+
+func TestSMLUnescape(t *testing.T) {
+	data := fixture(t, "010203041b1b1b1b1b1b1b1b05060708")
+	if res := unescape(data); !bytes.Equal(res, fixture(t, "010203041b1b1b1b05060708")) {
+		t.Errorf("unexpected result: %x", res)
+	}
+
+	// escaped escape sequence does not end the frame
+	frame := fixture(t, "1b1b1b1b010101011b1b1b1b1b1b1b1b1b1b1b1b1a00ffff")
+	if end := smlFrameEnd(frame, 0); end != 16 {
+		t.Errorf("expected end at 16, got %d", end)
+	}
+}
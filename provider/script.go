@@ -3,15 +3,13 @@ package provider
 import (
 	"context"
 	"errors"
-	"fmt"
 	"os/exec"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/andig/evcc/util"
-	"github.com/andig/evcc/util/jq"
-	"github.com/itchyny/gojq"
+	"github.com/andig/evcc/util/extract"
 	"github.com/kballard/go-shellquote"
 )
 
@@ -24,7 +22,7 @@ type Script struct {
 	updated time.Time
 	val     string
 	err     error
-	jq      *gojq.Query
+	extract *extract.Extractor
 }
 
 func init() {
@@ -37,7 +35,7 @@ func NewScriptProviderFromConfig(other map[string]interface{}) (IntProvider, err
 		Cmd     string
 		Timeout time.Duration
 		Cache   time.Duration
-		Jq      string
+		Extract extract.Config `mapstructure:",squash"`
 	}{
 		Timeout: 5 * time.Second,
 	}
@@ -46,7 +44,12 @@ func NewScriptProviderFromConfig(other map[string]interface{}) (IntProvider, err
 		return nil, err
 	}
 
-	return NewScriptProvider(cc.Cmd, cc.Timeout, cc.Jq, cc.Cache)
+	s, err := NewScriptProvider(cc.Cmd, cc.Timeout, "", cc.Cache)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.WithExtract(cc.Extract)
 }
 
 // NewScriptProvider creates a script provider.
@@ -58,17 +61,20 @@ func NewScriptProvider(script string, timeout time.Duration, jq string, cache ti
 		timeout: timeout,
 		cache:   cache,
 	}
-	
-	if jq != "" {
-		op, err := gojq.Parse(jq)
-		if err != nil {
-			return nil, fmt.Errorf("invalid jq query '%s': %w", jq, err)
-		}
 
-		s.jq = op
+	return s.WithExtract(extract.Config{Jq: jq})
+}
+
+// WithExtract sets the extraction applied to the script output
+func (e *Script) WithExtract(cc extract.Config) (*Script, error) {
+	ex, err := extract.New(cc)
+	if err != nil {
+		return nil, err
 	}
 
-	return s, nil
+	e.extract = ex
+
+	return e, nil
 }
 
 func (e *Script) exec(script string) (string, error) {
@@ -108,14 +114,11 @@ func (e *Script) StringGetter() func() (string, error) {
 			e.val, e.err = e.exec(e.script)
 			e.updated = time.Now()
 
-			if e.err == nil && e.jq != nil {
-				var v interface{}
-				if v, e.err = jq.Query(e.jq, []byte(e.val)); e.err == nil {
-					e.val = fmt.Sprintf("%v", v)
-				}
+			if e.err == nil {
+				e.val, e.err = e.extract.Extract([]byte(e.val))
 			}
 		}
-		
+
 		return e.val, e.err
 	}
 }
@@ -178,6 +181,36 @@ func (e *Script) IntSetter(param string) func(int64) error {
 	}
 }
 
+// FloatSetter invokes script with parameter replaced by float value
+func (e *Script) FloatSetter(param string) func(float64) error {
+	return func(f float64) error {
+		cmd, err := util.ReplaceFormatted(e.script, map[string]interface{}{
+			param: f,
+		})
+
+		if err == nil {
+			_, err = e.exec(cmd)
+		}
+
+		return err
+	}
+}
+
+// StringSetter invokes script with parameter replaced by string value
+func (e *Script) StringSetter(param string) func(string) error {
+	return func(s string) error {
+		cmd, err := util.ReplaceFormatted(e.script, map[string]interface{}{
+			param: s,
+		})
+
+		if err == nil {
+			_, err = e.exec(cmd)
+		}
+
+		return err
+	}
+}
+
 // BoolSetter invokes script with parameter replaced by bool value
 func (e *Script) BoolSetter(param string) func(bool) error {
 	// return func to access cached value
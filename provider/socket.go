@@ -1,17 +1,19 @@
 package provider
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/andig/evcc/api"
 	"github.com/andig/evcc/util"
-	"github.com/andig/evcc/util/jq"
+	"github.com/andig/evcc/util/extract"
 	"github.com/andig/evcc/util/request"
 	"github.com/gorilla/websocket"
-	"github.com/itchyny/gojq"
 )
 
 const retryDelay = 5 * time.Second
@@ -24,8 +26,12 @@ type Socket struct {
 	url     string
 	headers map[string]string
 	scale   float64
-	jq      *gojq.Query
-	val     interface{}
+	extract *extract.Extractor
+	val     string
+	payload string
+
+	connMu sync.Mutex
+	conn   *websocket.Conn
 }
 
 func init() {
@@ -38,7 +44,8 @@ func NewSocketProviderFromConfig(other map[string]interface{}) (IntProvider, err
 	cc := struct {
 		URI      string
 		Headers  map[string]string
-		Jq       string
+		Extract  extract.Config `mapstructure:",squash"`
+		Payload  string
 		Scale    float64
 		Insecure bool
 		Auth     Auth
@@ -65,6 +72,7 @@ func NewSocketProviderFromConfig(other map[string]interface{}) (IntProvider, err
 		url:     url,
 		headers: cc.Headers,
 		scale:   cc.Scale,
+		payload: cc.Payload,
 	}
 
 	// handle basic auth
@@ -79,15 +87,13 @@ func NewSocketProviderFromConfig(other map[string]interface{}) (IntProvider, err
 		p.Client.Transport = request.NewTripper(log, request.InsecureTransport())
 	}
 
-	if cc.Jq != "" {
-		op, err := gojq.Parse(cc.Jq)
-		if err != nil {
-			return nil, fmt.Errorf("invalid jq query: %s", p.jq)
-		}
-
-		p.jq = op
+	e, err := extract.New(cc.Extract)
+	if err != nil {
+		return nil, err
 	}
 
+	p.extract = e
+
 	go p.listen()
 
 	return p, nil
@@ -107,53 +113,53 @@ func (p *Socket) listen() {
 			continue
 		}
 
+		p.connMu.Lock()
+		p.conn = client
+		p.connMu.Unlock()
+
 		for {
 			_, b, err := client.ReadMessage()
 			if err != nil {
 				p.log.TRACE.Println("read:", err)
+				p.connMu.Lock()
+				p.conn = nil
+				p.connMu.Unlock()
+
 				_ = client.Close()
 				break
 			}
 
 			p.log.TRACE.Printf("recv: %s", b)
 
-			p.mux.Lock()
-			if p.jq != nil {
-				v, err := jq.Query(p.jq, b)
-				if err == nil {
-					p.val = v
-					p.mux.Update()
-				}
-			} else {
-				p.val = string(b)
-				p.mux.Update()
+			// ignore messages not matching the extraction
+			v, err := p.extract.Extract(b)
+			if err != nil {
+				p.log.TRACE.Println("extract:", err)
+				continue
 			}
+
+			p.mux.Lock()
+			p.val = v
+			p.mux.Update()
 			p.mux.Unlock()
 		}
 	}
 }
 
-func (p *Socket) hasValue() (interface{}, error) {
+func (p *Socket) hasValue() (string, error) {
 	elapsed := p.mux.LockWithTimeout()
 	defer p.mux.Unlock()
 
 	if elapsed > 0 {
-		return nil, fmt.Errorf("outdated: %v", elapsed.Truncate(time.Second))
+		return "", fmt.Errorf("%w: %v", api.ErrOutdated, elapsed.Truncate(time.Second))
 	}
 
 	return p.val, nil
 }
 
-// StringGetter sends string request
+// StringGetter returns the last received value
 func (p *Socket) StringGetter() func() (string, error) {
-	return func() (string, error) {
-		v, err := p.hasValue()
-		if err != nil {
-			return "", err
-		}
-
-		return jq.String(v)
-	}
+	return p.hasValue
 }
 
 // FloatGetter parses float from string getter
@@ -164,38 +170,17 @@ func (p *Socket) FloatGetter() func() (float64, error) {
 			return 0, err
 		}
 
-		// v is always string when jq not used
-		if p.jq == nil {
-			v, err = strconv.ParseFloat(v.(string), 64)
-			if err != nil {
-				return 0, err
-			}
-		}
-
-		f, err := jq.Float64(v)
+		f, err := strconv.ParseFloat(v, 64)
 		return f * p.scale, err
 	}
 }
 
 // IntGetter parses int64 from float getter
 func (p *Socket) IntGetter() func() (int64, error) {
-	return func() (int64, error) {
-		v, err := p.hasValue()
-		if err != nil {
-			return 0, err
-		}
-
-		// v is always string when jq not used
-		if p.jq == nil {
-			v, err = strconv.ParseInt(v.(string), 10, 64)
-			if err != nil {
-				return 0, err
-			}
-		}
-
-		i, err := jq.Int64(v)
-		f := float64(i) * p.scale
+	g := p.FloatGetter()
 
+	return func() (int64, error) {
+		f, err := g()
 		return int64(math.Round(f)), err
 	}
 }
@@ -204,15 +189,53 @@ func (p *Socket) IntGetter() func() (int64, error) {
 func (p *Socket) BoolGetter() func() (bool, error) {
 	return func() (bool, error) {
 		v, err := p.hasValue()
-		if err != nil {
-			return false, err
-		}
+		return util.Truish(v), err
+	}
+}
 
-		// v is always string when jq not used
-		if p.jq == nil {
-			v = util.Truish(v.(string))
-		}
+// set sends the payload with parameter replaced by value
+func (p *Socket) set(param string, val interface{}) error {
+	payload, err := setFormattedValue(p.payload, param, val)
+	if err != nil {
+		return err
+	}
+
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	if p.conn == nil {
+		return errors.New("not connected")
+	}
+
+	p.log.TRACE.Printf("send: %s", payload)
+
+	return p.conn.WriteMessage(websocket.TextMessage, []byte(payload))
+}
+
+// IntSetter sends int request
+func (p *Socket) IntSetter(param string) func(int64) error {
+	return func(val int64) error {
+		return p.set(param, val)
+	}
+}
+
+// FloatSetter sends float request
+func (p *Socket) FloatSetter(param string) func(float64) error {
+	return func(val float64) error {
+		return p.set(param, val)
+	}
+}
+
+// StringSetter sends string request
+func (p *Socket) StringSetter(param string) func(string) error {
+	return func(val string) error {
+		return p.set(param, val)
+	}
+}
 
-		return jq.Bool(v)
+// BoolSetter sends bool request
+func (p *Socket) BoolSetter(param string) func(bool) error {
+	return func(val bool) error {
+		return p.set(param, val)
 	}
 }
@@ -0,0 +1,577 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role is the access level of an api client
+type Role int
+
+// Roles in ascending order of privileges
+const (
+	RoleNone Role = iota
+	RoleRead
+	RoleControl
+)
+
+var roleNames = map[Role]string{
+	RoleNone:    "none",
+	RoleRead:    "read",
+	RoleControl: "control",
+}
+
+func (r Role) String() string {
+	return roleNames[r]
+}
+
+// RoleString converts string to Role
+func RoleString(s string) (Role, error) {
+	for role, name := range roleNames {
+		if strings.EqualFold(s, name) {
+			return role, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("invalid role: %s", s)
+}
+
+const (
+	sessionCookie   = "evcc_session"
+	sessionLifetime = 7 * 24 * time.Hour
+
+	loginFreeAttempts = 3               // failed logins before throttling
+	loginMaxDelay     = 5 * time.Minute // maximum delay between failed logins
+	loginForget       = 24 * time.Hour  // failed logins are forgotten after
+)
+
+var (
+	errLoginFailed    = errors.New("login failed")
+	errLoginThrottled = errors.New("too many failed logins")
+)
+
+// AuthConfig is the http authentication configuration
+type AuthConfig struct {
+	Password     string            // admin password or bcrypt hash, grants control role
+	Tokens       []AuthTokenConfig // api tokens for automations
+	Anonymous    string            // role of unauthenticated clients, none (default) or read
+	Trusted      []string          // networks granted control role without credentials, e.g. for SEMP
+	Certificates []AuthCertConfig  // roles of verified tls client certificates
+	Session      time.Duration     // session lifetime
+}
+
+// AuthTokenConfig is an api token with its role
+type AuthTokenConfig struct {
+	Token string
+	Role  string // read or control (default)
+}
+
+// AuthCertConfig maps tls client certificate subjects to a role
+type AuthCertConfig struct {
+	CN   string // common name, matches any if empty
+	OU   string // organizational unit, matches any if empty
+	Role string // read or control (default)
+}
+
+// certRole is a client certificate subject with its role
+type certRole struct {
+	cn, ou string
+	role   Role
+}
+
+// match checks if the certificate subject matches
+func (c certRole) match(cert *x509.Certificate) bool {
+	if c.cn != "" && c.cn != cert.Subject.CommonName {
+		return false
+	}
+
+	if c.ou == "" {
+		return true
+	}
+
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		if ou == c.ou {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Enabled returns true if authentication is configured
+func (c AuthConfig) Enabled() bool {
+	return c.Password != "" || len(c.Tokens) > 0 || len(c.Certificates) > 0
+}
+
+type roleContextKey struct{}
+
+// RoleFromContext returns the client role stored in the request context.
+// If authentication is disabled, all clients have control role.
+func RoleFromContext(ctx context.Context) Role {
+	if role, ok := ctx.Value(roleContextKey{}).(Role); ok {
+		return role
+	}
+	return RoleControl
+}
+
+// Auth authenticates http clients by admin session, api token or network
+type Auth struct {
+	mu        sync.Mutex
+	clock     clock.Clock
+	password  string
+	tokens    map[string]Role
+	anonymous Role
+	trusted   []*net.IPNet
+	certs     []certRole
+	lifetime  time.Duration
+	sessions  map[string]time.Time
+	failures  map[string]*loginFailures
+}
+
+// loginFailures tracks failed logins of a remote host
+type loginFailures struct {
+	count   int
+	last    time.Time
+	blocked time.Time // no login attempts accepted before
+}
+
+// NewAuth creates authentication from configuration
+func NewAuth(conf AuthConfig) (*Auth, error) {
+	a := &Auth{
+		clock:    clock.New(),
+		password: conf.Password,
+		tokens:   make(map[string]Role),
+		lifetime: conf.Session,
+		sessions: make(map[string]time.Time),
+		failures: make(map[string]*loginFailures),
+	}
+
+	if a.lifetime == 0 {
+		a.lifetime = sessionLifetime
+	}
+
+	if conf.Anonymous != "" {
+		role, err := RoleString(conf.Anonymous)
+		if err != nil || role == RoleControl {
+			return nil, fmt.Errorf("invalid anonymous role: %s", conf.Anonymous)
+		}
+		a.anonymous = role
+	}
+
+	for _, t := range conf.Tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("empty api token")
+		}
+
+		role := RoleControl
+		if t.Role != "" {
+			var err error
+			if role, err = RoleString(t.Role); err != nil || role == RoleNone {
+				return nil, fmt.Errorf("invalid token role: %s", t.Role)
+			}
+		}
+
+		a.tokens[t.Token] = role
+	}
+
+	for _, cidr := range conf.Trusted {
+		if !strings.Contains(cidr, "/") {
+			if strings.Contains(cidr, ":") {
+				cidr += "/128"
+			} else {
+				cidr += "/32"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted network: %w", err)
+		}
+
+		a.trusted = append(a.trusted, network)
+	}
+
+	for _, c := range conf.Certificates {
+		if c.CN == "" && c.OU == "" {
+			return nil, fmt.Errorf("certificate requires cn or ou")
+		}
+
+		role := RoleControl
+		if c.Role != "" {
+			var err error
+			if role, err = RoleString(c.Role); err != nil || role == RoleNone {
+				return nil, fmt.Errorf("invalid certificate role: %s", c.Role)
+			}
+		}
+
+		a.certs = append(a.certs, certRole{cn: c.CN, ou: c.OU, role: role})
+	}
+
+	return a, nil
+}
+
+// checkPassword validates the admin password in constant time
+func (a *Auth) checkPassword(password string) bool {
+	if a.password == "" {
+		return false
+	}
+
+	if strings.HasPrefix(a.password, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(a.password), []byte(password)) == nil
+	}
+
+	return subtle.ConstantTimeCompare([]byte(a.password), []byte(password)) == 1
+}
+
+// tokenRole returns the role of the api token
+func (a *Auth) tokenRole(token string) Role {
+	for t, role := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return role
+		}
+	}
+	return RoleNone
+}
+
+// remoteHost returns the request's remote host without port
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host
+}
+
+// trustedRemote checks if the request originates from a trusted network
+func (a *Auth) trustedRemote(r *http.Request) bool {
+	ip := net.ParseIP(remoteHost(r))
+	for _, network := range a.trusted {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameOrigin checks that browser requests originate from the server's own pages
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// newSession creates a new session id
+func (a *Auth) newSession() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	id := hex.EncodeToString(b)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// remove expired sessions
+	for s, expiry := range a.sessions {
+		if a.clock.Now().After(expiry) {
+			delete(a.sessions, s)
+		}
+	}
+
+	a.sessions[id] = a.clock.Now().Add(a.lifetime)
+
+	return id, nil
+}
+
+// validSession checks if the session exists and is not expired
+func (a *Auth) validSession(id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	expiry, ok := a.sessions[id]
+	return ok && a.clock.Now().Before(expiry)
+}
+
+func (a *Auth) deleteSession(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sessions, id)
+}
+
+// Role returns the role of the requesting client
+func (a *Auth) Role(r *http.Request) Role {
+	if a.trustedRemote(r) {
+		return RoleControl
+	}
+
+	role := a.credentialRole(r)
+
+	// verified tls client certificate
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		if cert := a.certRole(r.TLS.VerifiedChains[0][0]); cert > role {
+			role = cert
+		}
+	}
+
+	return role
+}
+
+// credentialRole returns the role of the client's api token or session
+func (a *Auth) credentialRole(r *http.Request) Role {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return a.tokenRole(strings.TrimPrefix(h, "Bearer "))
+	}
+
+	// session cookies are not accepted cross-origin to prevent request forgery
+	if c, err := r.Cookie(sessionCookie); err == nil && sameOrigin(r) && a.validSession(c.Value) {
+		return RoleControl
+	}
+
+	return a.anonymous
+}
+
+// certRole returns the role of the first matching certificate config.
+// Certificates without matching config are granted read role.
+func (a *Auth) certRole(cert *x509.Certificate) Role {
+	for _, c := range a.certs {
+		if c.match(cert) {
+			return c.role
+		}
+	}
+
+	return RoleRead
+}
+
+// Require returns middleware that rejects clients without the given role
+func (a *Auth) Require(role Role) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			current := a.Role(r)
+
+			if current < role {
+				status := http.StatusForbidden
+				if current == RoleNone {
+					status = http.StatusUnauthorized
+				}
+
+				log.DEBUG.Printf("httpd: %s %s denied for role %s", r.Method, r.URL.Path, current)
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), roleContextKey{}, current)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// login validates the password and creates a session. Failed logins are throttled per remote
+// host with exponentially increasing delay. The returned duration is the delay until the
+// next login attempt is accepted.
+func (a *Auth) login(r *http.Request, password string) (string, time.Duration, error) {
+	host := remoteHost(r)
+	now := a.clock.Now()
+
+	a.mu.Lock()
+	f, ok := a.failures[host]
+	if ok && now.Before(f.blocked) {
+		a.mu.Unlock()
+		return "", f.blocked.Sub(now), errLoginThrottled
+	}
+	a.mu.Unlock()
+
+	if !a.checkPassword(password) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		// remove forgotten failures
+		for h, f := range a.failures {
+			if now.Sub(f.last) > loginForget {
+				delete(a.failures, h)
+			}
+		}
+
+		f, ok := a.failures[host]
+		if !ok {
+			f = new(loginFailures)
+			a.failures[host] = f
+		}
+
+		f.count++
+		f.last = now
+
+		var delay time.Duration
+		if n := f.count - loginFreeAttempts; n > 0 {
+			delay = loginMaxDelay
+			if n < 16 {
+				delay = time.Duration(math.Min(float64(time.Second<<uint(n-1)), float64(loginMaxDelay)))
+			}
+			f.blocked = now.Add(delay)
+		}
+
+		log.WARN.Printf("httpd: login failed from %s", host)
+
+		return "", delay, errLoginFailed
+	}
+
+	a.mu.Lock()
+	delete(a.failures, host)
+	a.mu.Unlock()
+
+	id, err := a.newSession()
+
+	return id, 0, err
+}
+
+// loginError writes the login error status
+func loginError(w http.ResponseWriter, delay time.Duration, err error) int {
+	switch {
+	case errors.Is(err, errLoginThrottled):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+		return http.StatusTooManyRequests
+	case errors.Is(err, errLoginFailed):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// setSessionCookie stores the session id in the client's cookie
+func (a *Auth) setSessionCookie(w http.ResponseWriter, r *http.Request, id string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(a.lifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// loginHandler creates a session for the admin password
+func (a *Auth) loginHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, delay, err := a.login(r, req.Password)
+	if err != nil {
+		w.WriteHeader(loginError(w, delay, err))
+		return
+	}
+
+	a.setSessionCookie(w, r, id)
+
+	jsonResponse(w, r, authStatusJSON{Enabled: true, Role: RoleControl.String()})
+}
+
+// loginPageHandler serves the login form of the web ui and creates a session for the posted password
+func (a *Auth) loginPageHandler(w http.ResponseWriter, r *http.Request) {
+	var msg string
+	status := http.StatusOK
+
+	if r.Method == http.MethodPost {
+		// prevent login request forgery
+		if !sameOrigin(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		id, delay, err := a.login(r, r.PostFormValue("password"))
+		if err == nil {
+			a.setSessionCookie(w, r, id)
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+
+		status = loginError(w, delay, err)
+
+		msg = "Invalid password"
+		if errors.Is(err, errLoginThrottled) {
+			msg = fmt.Sprintf("Too many failed logins, retry in %v", delay.Round(time.Second))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.WriteHeader(status)
+
+	if err := loginTemplate.Execute(w, msg); err != nil {
+		log.ERROR.Println("httpd: failed to render login page:", err)
+	}
+}
+
+// logoutHandler deletes the client's session
+func (a *Auth) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		a.deleteSession(c.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   sessionCookie,
+		Path:   "/",
+		MaxAge: -1,
+	})
+
+	jsonResponse(w, r, authStatusJSON{Enabled: true, Role: a.Role(r).String()})
+}
+
+type authStatusJSON struct {
+	Enabled bool   `json:"enabled"`
+	Role    string `json:"role"`
+}
+
+// loginTemplate is the login form of the web ui
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>evcc</title>
+<style>
+body { font-family: sans-serif; background: #f8f9fa; }
+form { max-width: 20rem; margin: 4rem auto; display: flex; flex-direction: column; gap: 0.75rem; }
+input, button { font-size: 1rem; padding: 0.5rem; }
+.error { color: #dc3545; }
+</style>
+</head>
+<body>
+<form method="post" action="login">
+<h1>evcc</h1>
+{{if .}}<div class="error">{{.}}</div>{{end}}
+<label for="password">Password</label>
+<input type="password" id="password" name="password" autocomplete="current-password" autofocus required>
+<button type="submit">Login</button>
+</form>
+</body>
+</html>
+`))
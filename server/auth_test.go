@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+func TestAuthRoles(t *testing.T) {
+	auth, err := NewAuth(AuthConfig{
+		Password:  "secret",
+		Anonymous: "read",
+		Tokens: []AuthTokenConfig{
+			{Token: "reader", Role: "read"},
+			{Token: "controller"},
+		},
+		Trusted: []string{"192.168.1.10", "10.0.0.0/8"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := []struct {
+		remote, token string
+		role          Role
+	}{
+		{"192.168.1.1:1234", "", RoleRead},
+		{"192.168.1.1:1234", "reader", RoleRead},
+		{"192.168.1.1:1234", "controller", RoleControl},
+		{"192.168.1.1:1234", "invalid", RoleNone},
+		{"192.168.1.10:1234", "", RoleControl},
+		{"10.1.2.3:1234", "", RoleControl},
+	}
+
+	for _, tc := range tc {
+		t.Logf("%+v", tc)
+
+		r := httptest.NewRequest(http.MethodGet, "/api/state", nil)
+		r.RemoteAddr = tc.remote
+		if tc.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+
+		if role := auth.Role(r); role != tc.role {
+			t.Errorf("expected %v, got %v", tc.role, role)
+		}
+	}
+
+	if _, err := NewAuth(AuthConfig{Anonymous: "control"}); err == nil {
+		t.Error("expected invalid anonymous role")
+	}
+}
+
+func TestAuthSession(t *testing.T) {
+	auth, err := NewAuth(AuthConfig{Password: "secret", Session: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clck := clock.NewMock()
+	auth.clock = clck
+
+	// failed login
+	w := httptest.NewRecorder()
+	auth.loginHandler(w, httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBufferString(`{"password":"wrong"}`)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	// successful login
+	w = httptest.NewRecorder()
+	auth.loginHandler(w, httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBufferString(`{"password":"secret"}`)))
+	if w.Code != http.StatusOK || len(w.Result().Cookies()) != 1 {
+		t.Fatalf("login failed: %d", w.Code)
+	}
+	cookie := w.Result().Cookies()[0]
+
+	handler := auth.Require(RoleControl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if role := RoleFromContext(r.Context()); role != RoleControl {
+			t.Errorf("expected control role in context, got %v", role)
+		}
+	}))
+
+	tc := []struct {
+		origin string
+		offset time.Duration
+		status int
+	}{
+		{"", 0, http.StatusOK},
+		{"http://example.com", 0, http.StatusOK},
+		{"http://attacker.com", 0, http.StatusUnauthorized},
+		{"", 2 * time.Hour, http.StatusUnauthorized},
+	}
+
+	for _, tc := range tc {
+		t.Logf("%+v", tc)
+
+		clck.Add(tc.offset)
+
+		r := httptest.NewRequest(http.MethodPost, "http://example.com/api/loadpoints/0/mode/pv", nil)
+		r.AddCookie(cookie)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != tc.status {
+			t.Errorf("expected %d, got %d", tc.status, w.Code)
+		}
+	}
+}
+
+func TestAuthThrottle(t *testing.T) {
+	auth, err := NewAuth(AuthConfig{Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clck := clock.NewMock()
+	auth.clock = clck
+
+	login := func(password string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		auth.loginHandler(w, httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBufferString(`{"password":"`+password+`"}`)))
+		return w
+	}
+
+	for i := 0; i <= loginFreeAttempts; i++ {
+		if w := login("wrong"); w.Code != http.StatusUnauthorized {
+			t.Errorf("%d: expected %d, got %d", i, http.StatusUnauthorized, w.Code)
+		}
+	}
+
+	// blocked even with correct password
+	w := login("secret")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected %d with retry, got %d (%q)", http.StatusTooManyRequests, w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// delay increases with further failures
+	clck.Add(time.Second)
+	if w := login("wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := login("secret"); w.Header().Get("Retry-After") != "2" {
+		t.Errorf("expected increased delay, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// other clients are not affected
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewBufferString(`{"password":"secret"}`))
+	r.RemoteAddr = "192.168.1.1:1234"
+	auth.loginHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// successful login resets throttling
+	clck.Add(2 * time.Second)
+	if w := login("secret"); w.Code != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, w.Code)
+	}
+	if w := login("wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestAuthLoginPage(t *testing.T) {
+	auth, err := NewAuth(AuthConfig{Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(password, origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(url.Values{"password": {password}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+
+		w := httptest.NewRecorder()
+		auth.loginPageHandler(w, r)
+		return w
+	}
+
+	w := httptest.NewRecorder()
+	auth.loginPageHandler(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `name="password"`) {
+		t.Errorf("expected login form, got %d", w.Code)
+	}
+
+	if w := post("wrong", ""); w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "Invalid password") {
+		t.Errorf("expected %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	if w := post("secret", "http://evil.example"); w.Code != http.StatusForbidden {
+		t.Errorf("expected %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	w = post("secret", "http://example.com")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/" || len(w.Result().Cookies()) != 1 {
+		t.Fatalf("login failed: %d", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(w.Result().Cookies()[0])
+	if role := auth.Role(r); role != RoleControl {
+		t.Errorf("expected %v, got %v", RoleControl, role)
+	}
+}
+
+func TestAuthCertificateRoles(t *testing.T) {
+	auth, err := NewAuth(AuthConfig{
+		Password: "secret",
+		Certificates: []AuthCertConfig{
+			{CN: "wallbox"},
+			{OU: "dashboards", Role: "read"},
+			{OU: "admins"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc := []struct {
+		subject pkix.Name
+		token   string
+		role    Role
+	}{
+		{pkix.Name{CommonName: "wallbox"}, "", RoleControl},
+		{pkix.Name{CommonName: "tablet", OrganizationalUnit: []string{"dashboards"}}, "", RoleRead},
+		{pkix.Name{CommonName: "laptop", OrganizationalUnit: []string{"users", "admins"}}, "", RoleControl},
+		{pkix.Name{CommonName: "unknown"}, "", RoleRead},
+		{pkix.Name{CommonName: "unknown"}, "invalid", RoleRead},
+	}
+
+	for _, tc := range tc {
+		t.Logf("%+v", tc)
+
+		r := httptest.NewRequest(http.MethodGet, "/api/state", nil)
+		r.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: tc.subject}}},
+		}
+		if tc.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+
+		if role := auth.Role(r); role != tc.role {
+			t.Errorf("expected %v, got %v", tc.role, role)
+		}
+	}
+
+	for _, cc := range []AuthCertConfig{{Role: "read"}, {CN: "foo", Role: "none"}} {
+		if _, err := NewAuth(AuthConfig{Certificates: []AuthCertConfig{cc}}); err == nil {
+			t.Errorf("%+v: expected error", cc)
+		}
+	}
+}
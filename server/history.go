@@ -0,0 +1,397 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andig/evcc/util"
+	"github.com/benbjohnson/clock"
+)
+
+// HistoryConfig is the history buffer configuration
+type HistoryConfig struct {
+	Retention  time.Duration // time span of stored values
+	Resolution time.Duration // base resolution of stored values
+	Persist    bool          // store history in data directory
+}
+
+const (
+	historyRetention  = 72 * time.Hour
+	historyResolution = time.Minute
+	historyPersist    = 15 * time.Minute // persistence interval
+	historyFile       = "history.json"
+)
+
+// historyPoint is a downsampled value
+type historyPoint struct {
+	TS  time.Time
+	Val float64
+}
+
+// MarshalJSON encodes point as [unix timestamp, value] tuple
+func (p historyPoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]interface{}{p.TS.Unix(), p.Val})
+}
+
+// UnmarshalJSON decodes point from [unix timestamp, value] tuple
+func (p *historyPoint) UnmarshalJSON(b []byte) error {
+	var tuple [2]float64
+	if err := json.Unmarshal(b, &tuple); err != nil {
+		return err
+	}
+
+	p.TS = time.Unix(int64(tuple[0]), 0)
+	p.Val = tuple[1]
+
+	return nil
+}
+
+// historySeries is a ring buffer of downsampled values
+type historySeries struct {
+	points []historyPoint
+	start  int // index of oldest point
+	count  int
+
+	// currently aggregated bucket
+	bucket time.Time
+	sum    float64
+	n      int
+}
+
+func newHistorySeries(size int) *historySeries {
+	return &historySeries{points: make([]historyPoint, size)}
+}
+
+// push appends point to the ring buffer, overwriting the oldest point if full
+func (s *historySeries) push(p historyPoint) {
+	if s.count < len(s.points) {
+		s.points[(s.start+s.count)%len(s.points)] = p
+		s.count++
+		return
+	}
+
+	s.points[s.start] = p
+	s.start = (s.start + 1) % len(s.points)
+}
+
+// add aggregates the value into its resolution bucket
+func (s *historySeries) add(ts time.Time, resolution time.Duration, val float64) {
+	bucket := ts.Truncate(resolution)
+
+	if s.n > 0 && !bucket.Equal(s.bucket) {
+		s.push(historyPoint{TS: s.bucket, Val: s.sum / float64(s.n)})
+		s.sum, s.n = 0, 0
+	}
+
+	s.bucket = bucket
+	s.sum += val
+	s.n++
+}
+
+// all returns the points in chronological order including the current bucket
+func (s *historySeries) all() []historyPoint {
+	res := make([]historyPoint, 0, s.count+1)
+	for i := 0; i < s.count; i++ {
+		res = append(res, s.points[(s.start+i)%len(s.points)])
+	}
+
+	if s.n > 0 {
+		res = append(res, historyPoint{TS: s.bucket, Val: s.sum / float64(s.n)})
+	}
+
+	return res
+}
+
+// History keeps downsampled site and loadpoint values in memory
+type History struct {
+	mu         sync.Mutex
+	log        *util.Logger
+	clock      clock.Clock
+	resolution time.Duration
+	size       int
+	file       string
+	series     map[string]*historySeries
+}
+
+// NewHistory creates history buffer. If persistence is enabled, history is
+// loaded from and periodically stored to the data directory.
+func NewHistory(conf HistoryConfig, dataDir string) *History {
+	h := &History{
+		log:        util.NewLogger("history"),
+		clock:      clock.New(),
+		resolution: conf.Resolution,
+		series:     make(map[string]*historySeries),
+	}
+
+	if conf.Persist {
+		h.file = filepath.Join(dataDir, historyFile)
+	}
+
+	if h.resolution == 0 {
+		h.resolution = historyResolution
+	}
+
+	retention := conf.Retention
+	if retention == 0 {
+		retention = historyRetention
+	}
+
+	h.size = int(retention / h.resolution)
+	if h.size < 1 {
+		h.size = 1
+	}
+
+	if h.file != "" {
+		if err := h.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			h.log.ERROR.Printf("load: %v", err)
+		}
+	}
+
+	return h
+}
+
+// historyKey returns the api key of the param, e.g. loadpoints.0.chargePower
+func historyKey(p util.Param) string {
+	if p.LoadPoint != nil {
+		return fmt.Sprintf("loadpoints.%d.%s", *p.LoadPoint, p.Key)
+	}
+	return p.Key
+}
+
+// historyValue converts numeric and boolean values
+func historyValue(val interface{}) (float64, bool) {
+	switch val := val.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case bool:
+		if val {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// add adds the param value to its series
+func (h *History) add(p util.Param) {
+	val, ok := historyValue(p.Val)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := historyKey(p)
+
+	s, ok := h.series[key]
+	if !ok {
+		s = newHistorySeries(h.size)
+		h.series[key] = s
+	}
+
+	s.add(h.clock.Now(), h.resolution, val)
+}
+
+// Run adds the param stream to the history and periodically persists the history
+func (h *History) Run(in <-chan util.Param) {
+	ticker := h.clock.Ticker(historyPersist)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case p, ok := <-in:
+			if !ok {
+				return
+			}
+			h.add(p)
+
+		case <-ticker.C:
+			if err := h.Persist(); err != nil {
+				h.log.ERROR.Printf("persist: %v", err)
+			}
+		}
+	}
+}
+
+// Query returns the values of the given keys since from, aggregated to resolution.
+// Without keys all series are returned.
+func (h *History) Query(keys []string, from time.Time, resolution time.Duration) map[string][]historyPoint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(keys) == 0 {
+		for key := range h.series {
+			keys = append(keys, key)
+		}
+	}
+
+	res := make(map[string][]historyPoint)
+	for _, key := range keys {
+		s, ok := h.series[key]
+		if !ok {
+			continue
+		}
+
+		res[key] = downsample(s.all(), from, resolution)
+	}
+
+	return res
+}
+
+// downsample averages points since from into buckets of given resolution
+func downsample(points []historyPoint, from time.Time, resolution time.Duration) []historyPoint {
+	res := make([]historyPoint, 0, len(points))
+
+	var sum float64
+	var n int
+	var bucket time.Time
+
+	for _, p := range points {
+		if p.TS.Before(from) {
+			continue
+		}
+
+		b := p.TS
+		if resolution > 0 {
+			b = p.TS.Truncate(resolution)
+		}
+
+		if n > 0 && !b.Equal(bucket) {
+			res = append(res, historyPoint{TS: bucket, Val: sum / float64(n)})
+			sum, n = 0, 0
+		}
+
+		bucket = b
+		sum += p.Val
+		n++
+	}
+
+	if n > 0 {
+		res = append(res, historyPoint{TS: bucket, Val: sum / float64(n)})
+	}
+
+	return res
+}
+
+// historyFileJSON is the persisted history
+type historyFileJSON struct {
+	Resolution time.Duration             `json:"resolution"`
+	Series     map[string][]historyPoint `json:"series"`
+}
+
+// Persist stores the history to file
+func (h *History) Persist() error {
+	if h.file == "" {
+		return nil
+	}
+
+	h.mu.Lock()
+	data := historyFileJSON{
+		Resolution: h.resolution,
+		Series:     make(map[string][]historyPoint),
+	}
+	for key, s := range h.series {
+		data.Series[key] = s.all()
+	}
+	h.mu.Unlock()
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFileAtomic(h.file, b, 0600)
+}
+
+// load restores history from file, dropping values outside the retention
+func (h *History) load() error {
+	b, err := os.ReadFile(h.file)
+	if err != nil {
+		return err
+	}
+
+	var data historyFileJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+
+	if data.Resolution != h.resolution {
+		return fmt.Errorf("resolution changed from %v to %v", data.Resolution, h.resolution)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	from := h.clock.Now().Add(-time.Duration(h.size) * h.resolution)
+
+	for key, points := range data.Series {
+		s := newHistorySeries(h.size)
+		for _, p := range points {
+			if !p.TS.Before(from) {
+				s.push(p)
+			}
+		}
+		h.series[key] = s
+	}
+
+	return nil
+}
+
+// parseFrom parses absolute RFC3339 time, unix timestamp or relative duration like 6h
+func parseFrom(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(strings.TrimPrefix(s, "-")); err == nil {
+		return now.Add(-d), nil
+	}
+
+	var ts int64
+	if _, err := fmt.Sscanf(s, "%d", &ts); err == nil && fmt.Sprint(ts) == s {
+		return time.Unix(ts, 0), nil
+	}
+
+	return parseTime(s)
+}
+
+// HistoryHandler returns the history of the requested keys
+func HistoryHandler(h *History) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		var keys []string
+		if k := q.Get("keys"); k != "" {
+			keys = strings.Split(k, ",")
+		}
+
+		from, err := parseFrom(q.Get("from"), h.clock.Now())
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, fmt.Errorf("invalid from: %w", err))
+			return
+		}
+
+		var resolution time.Duration
+		if res := q.Get("resolution"); res != "" {
+			if resolution, err = time.ParseDuration(res); err != nil || resolution < 0 {
+				errorResponse(w, http.StatusBadRequest, fmt.Errorf("invalid resolution: %s", res))
+				return
+			}
+		}
+
+		jsonResponse(w, r, h.Query(keys, from, resolution))
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andig/evcc/util"
+	"github.com/benbjohnson/clock"
+)
+
+func TestHistorySeries(t *testing.T) {
+	s := newHistorySeries(3)
+	start := time.Unix(0, 0)
+
+	// 5 buckets with 2 values each, oldest bucket is overwritten
+	for i := 0; i < 5; i++ {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		s.add(ts, time.Minute, float64(i))
+		s.add(ts.Add(30*time.Second), time.Minute, float64(i)+1)
+	}
+
+	res := s.all()
+	if len(res) != 4 {
+		t.Fatalf("expected 4 points, got %d", len(res))
+	}
+
+	for i, p := range res {
+		expected := float64(i+1) + 0.5
+		if p.Val != expected || !p.TS.Equal(start.Add(time.Duration(i+1)*time.Minute)) {
+			t.Errorf("%d: expected %v, got %v at %v", i, expected, p.Val, p.TS)
+		}
+	}
+}
+
+func TestHistoryQuery(t *testing.T) {
+	dir := t.TempDir()
+	conf := HistoryConfig{Retention: 2 * time.Hour, Persist: true}
+
+	h := NewHistory(conf, dir)
+	clck := clock.NewMock()
+	clck.Set(time.Now().Truncate(time.Hour).Add(-10 * time.Minute))
+	h.clock = clck
+
+	lp := 0
+	for i := 0; i < 10; i++ {
+		h.add(util.Param{Key: "gridPower", Val: float64(i)})
+		h.add(util.Param{LoadPoint: &lp, Key: "charging", Val: i%2 == 0})
+		h.add(util.Param{Key: "siteTitle", Val: "home"})
+		clck.Add(time.Minute)
+	}
+
+	res := h.Query([]string{"gridPower", "loadpoints.0.charging", "siteTitle"}, clck.Now().Add(-4*time.Minute), 2*time.Minute)
+	if len(res) != 2 {
+		t.Errorf("expected 2 series, got %v", res)
+	}
+
+	if grid := res["gridPower"]; len(grid) != 2 || grid[0].Val != 6.5 || grid[1].Val != 8.5 {
+		t.Errorf("unexpected gridPower: %v", grid)
+	}
+
+	// persistence
+	if err := h.Persist(); err != nil {
+		t.Fatal(err)
+	}
+
+	h2 := NewHistory(conf, dir)
+	h2.clock = clck
+
+	if len(h2.Query(nil, time.Time{}, 0)["gridPower"]) != 10 {
+		t.Errorf("history not restored: %v", h2.Query(nil, time.Time{}, 0))
+	}
+
+	// handler
+	w := httptest.NewRecorder()
+	HistoryHandler(h).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/history?keys=gridPower&from=3m&resolution=1m", nil))
+
+	var data map[string][][2]float64
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	if grid := data["gridPower"]; len(grid) != 3 || grid[2][1] != 9 || int64(grid[2][0]) != clck.Now().Add(-time.Minute).Unix() {
+		t.Errorf("unexpected response: %v", data)
+	}
+
+	w = httptest.NewRecorder()
+	HistoryHandler(h).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/history?resolution=foo", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
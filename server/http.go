@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/andig/evcc/api"
@@ -313,35 +314,54 @@ func TargetChargeHandler(loadpoint core.LoadPointAPI) http.HandlerFunc {
 }
 
 // SocketHandler attaches websocket handler to uri
-func SocketHandler(hub *SocketHub) http.HandlerFunc {
+func SocketHandler(hub *SocketHub, site core.SiteAPI) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ServeWebsocket(hub, w, r)
+		ServeWebsocket(hub, site, w, r)
 	}
 }
 
 // HTTPd wraps an http.Server and adds the root router
 type HTTPd struct {
 	*http.Server
+	api      *mux.Router
+	auth     *Auth
+	redirect string
 }
 
 // NewHTTPd creates HTTP server with configured routes for loadpoint
 func NewHTTPd(url string, site core.SiteAPI, hub *SocketHub, cache *util.Cache) *HTTPd {
+	router := mux.NewRouter().StrictSlash(true)
+
+	srv := &HTTPd{
+		Server: &http.Server{
+			Addr:         url,
+			Handler:      router,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  120 * time.Second,
+			ErrorLog:     log.ERROR,
+		},
+	}
+	srv.SetKeepAlivesEnabled(true)
+
 	routes := map[string]route{
 		"health":    {[]string{"GET"}, "/health", HealthHandler(site)},
 		"state":     {[]string{"GET"}, "/state", StateHandler(cache)},
 		"templates": {[]string{"GET"}, "/config/templates/{class:[a-z]+}", TemplatesHandler()},
+		"auth":      {[]string{"GET"}, "/auth", srv.authStatusHandler},
+		"login":     {[]string{"POST"}, "/auth/login", srv.loginHandler},
+		"logout":    {[]string{"POST"}, "/auth/logout", srv.logoutHandler},
 	}
 
-	router := mux.NewRouter().StrictSlash(true)
-
 	// websocket
-	router.HandleFunc("/ws", SocketHandler(hub))
+	router.Handle("/ws", srv.Authorize(RoleRead)(SocketHandler(hub, site)))
 
 	// static - individual handlers per root and folders
 	static := router.PathPrefix("/").Subrouter()
 	static.Use(handlers.CompressHandler)
 
-	static.HandleFunc("/", indexHandler(site))
+	static.HandleFunc("/", srv.loginRedirect(indexHandler(site)))
+	static.HandleFunc("/login", srv.loginPageHandler).Methods(http.MethodGet, http.MethodPost)
 	for _, dir := range []string{"css", "js", "ico"} {
 		static.PathPrefix("/" + dir).Handler(http.FileServer(http.FS(Assets)))
 	}
@@ -352,15 +372,23 @@ func NewHTTPd(url string, site core.SiteAPI, hub *SocketHub, cache *util.Cache)
 	api.Use(handlers.CompressHandler)
 	api.Use(handlers.CORS(
 		handlers.AllowedHeaders([]string{
-			"Accept", "Accept-Language", "Content-Language", "Content-Type", "Origin",
+			"Accept", "Accept-Language", "Content-Language", "Content-Type", "Origin", "Authorization",
+		}),
+		handlers.AllowedMethods([]string{
+			http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPatch,
 		}),
 	))
+	api.Use(srv.authorizeMethod)
+	srv.api = api
 
 	// site api
 	for _, r := range routes {
 		api.Methods(r.Methods...).Path(r.Pattern).Handler(r.HandlerFunc)
 	}
 
+	// versioned api
+	registerV1(api, site, cache)
+
 	// loadpoint api
 	for id, lp := range site.LoadPoints() {
 		lpAPI := api.PathPrefix(fmt.Sprintf("/loadpoints/%d", id)).Subrouter()
@@ -381,19 +409,113 @@ func NewHTTPd(url string, site core.SiteAPI, hub *SocketHub, cache *util.Cache)
 		}
 	}
 
-	srv := &HTTPd{
-		Server: &http.Server{
-			Addr:         url,
-			Handler:      router,
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 10 * time.Second,
-			IdleTimeout:  120 * time.Second,
-			ErrorLog:     log.ERROR,
-		},
+	return srv
+}
+
+// WithHistory adds the history api
+func (s *HTTPd) WithHistory(h *History) *HTTPd {
+	s.api.Methods(http.MethodGet).Path("/history").Handler(HistoryHandler(h))
+	return s
+}
+
+// WithStatistics adds the energy statistics api
+func (s *HTTPd) WithStatistics(stats *core.Statistics) *HTTPd {
+	s.api.Methods(http.MethodGet).Path("/statistics").Handler(StatisticsHandler(stats))
+	return s
+}
+
+// WithBaseload adds the household baseload api
+func (s *HTTPd) WithBaseload(baseload *core.Baseload) *HTTPd {
+	s.api.Methods(http.MethodGet).Path("/baseload").Handler(BaseloadHandler(baseload))
+	return s
+}
+
+// WithAuth enables authentication for the api, websocket and control endpoints
+func (s *HTTPd) WithAuth(conf AuthConfig) error {
+	auth, err := NewAuth(conf)
+	if err == nil {
+		s.auth = auth
 	}
-	srv.SetKeepAlivesEnabled(true)
+	return err
+}
 
-	return srv
+// Authorize returns middleware that rejects clients without the given role.
+// Without authentication all clients are accepted.
+func (s *HTTPd) Authorize(role Role) mux.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.auth == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			s.auth.Require(role)(h).ServeHTTP(w, r)
+		})
+	}
+}
+
+// authorizeMethod requires read role for GET and control role for all other api requests
+func (s *HTTPd) authorizeMethod(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// health and login are public
+		if path := strings.TrimPrefix(r.URL.Path, "/api"); path == "/health" || strings.HasPrefix(path, "/auth") {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		role := RoleControl
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			role = RoleRead
+		}
+
+		s.Authorize(role)(h).ServeHTTP(w, r)
+	})
+}
+
+// authStatusHandler returns the authentication status and role of the client
+func (s *HTTPd) authStatusHandler(w http.ResponseWriter, r *http.Request) {
+	res := authStatusJSON{Role: RoleControl.String()}
+	if s.auth != nil {
+		res = authStatusJSON{Enabled: true, Role: s.auth.Role(r).String()}
+	}
+
+	jsonResponse(w, r, res)
+}
+
+func (s *HTTPd) loginHandler(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.auth.loginHandler(w, r)
+}
+
+// loginPageHandler serves the login form of the web ui
+func (s *HTTPd) loginPageHandler(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	s.auth.loginPageHandler(w, r)
+}
+
+// loginRedirect redirects web ui clients without access to the login form
+func (s *HTTPd) loginRedirect(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.auth != nil && s.auth.Role(r) == RoleNone {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *HTTPd) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if s.auth == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.auth.logoutHandler(w, r)
 }
 
 // Router returns the main router
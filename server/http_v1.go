@@ -0,0 +1,475 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/core"
+	"github.com/andig/evcc/util"
+	"github.com/gorilla/mux"
+)
+
+// apiErrorJSON is the structured error response of the v1 api
+type apiErrorJSON struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// errorResponse writes a structured error response
+func errorResponse(w http.ResponseWriter, status int, err error) {
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(apiErrorJSON{Status: status, Error: err.Error()}); err != nil {
+		log.ERROR.Printf("httpd: failed to encode JSON: %v", err)
+	}
+}
+
+// decodeJSON strictly decodes the request body
+func decodeJSON(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+type targetChargeJSON struct {
+	SoC  int    `json:"soc"`
+	Time string `json:"time" format:"date-time"`
+}
+
+type remoteDemandJSON struct {
+	Demand string `json:"demand"`
+	Source string `json:"source"`
+}
+
+// loadpointSettingsJSON are the writable loadpoint settings. Fields that are
+// not set remain unchanged.
+type loadpointSettingsJSON struct {
+	Mode         *api.ChargeMode   `json:"mode,omitempty"`
+	TargetSoC    *int              `json:"targetSoC,omitempty"`
+	MinSoC       *int              `json:"minSoC,omitempty"`
+	MinCurrent   *float64          `json:"minCurrent,omitempty"`
+	MaxCurrent   *float64          `json:"maxCurrent,omitempty"`
+	Phases       *int64            `json:"phases,omitempty"`
+	Vehicle      *string           `json:"vehicle,omitempty"`
+	TargetCharge *targetChargeJSON `json:"targetCharge,omitempty"`
+	RemoteDemand *remoteDemandJSON `json:"remoteDemand,omitempty"`
+}
+
+// siteSettingsJSON are the writable site settings
+type siteSettingsJSON struct {
+	PrioritySoC *float64 `json:"prioritySoC,omitempty"`
+}
+
+// stateJSON is a free-form state object
+type stateJSON map[string]interface{}
+
+// v1Route describes a v1 api route including its documentation
+type v1Route struct {
+	Method   string
+	Pattern  string
+	Summary  string
+	Request  interface{}
+	Response interface{}
+	Handler  http.HandlerFunc
+}
+
+// v1Routes creates the v1 api routes
+func v1Routes(site core.SiteAPI, cache *util.Cache) []v1Route {
+	return []v1Route{
+		{http.MethodGet, "/state", "Site and loadpoint state", nil, stateJSON{}, StateHandler(cache)},
+		{http.MethodPatch, "/site", "Update site settings", siteSettingsJSON{}, siteSettingsJSON{}, siteSettingsHandler(site)},
+		{http.MethodGet, "/loadpoints", "State of all loadpoints", nil, []stateJSON{}, loadpointsHandler(cache)},
+		{http.MethodGet, "/loadpoints/{id:[0-9]+}", "Loadpoint state", nil, stateJSON{}, loadpointHandler(site, cache)},
+		{http.MethodPatch, "/loadpoints/{id:[0-9]+}", "Update loadpoint settings", loadpointSettingsJSON{}, loadpointSettingsJSON{}, loadpointSettingsHandler(site)},
+	}
+}
+
+// loadpointStates returns the cached state of all loadpoints
+func loadpointStates(cache *util.Cache) []map[string]interface{} {
+	lps, _ := cache.State()["loadpoints"].([]map[string]interface{})
+	return lps
+}
+
+func loadpointsHandler(cache *util.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jsonResponse(w, r, loadpointStates(cache))
+	}
+}
+
+// v1LoadPoint returns the loadpoint id and api from the request path
+func v1LoadPoint(site core.SiteAPI, r *http.Request) (int, core.LoadPointAPI, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id < 0 || id >= len(site.LoadPoints()) {
+		return 0, nil, fmt.Errorf("invalid loadpoint: %s", mux.Vars(r)["id"])
+	}
+	return id, site.LoadPoints()[id], nil
+}
+
+func loadpointHandler(site core.SiteAPI, cache *util.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _, err := v1LoadPoint(site, r)
+		if err != nil {
+			errorResponse(w, http.StatusNotFound, err)
+			return
+		}
+
+		res := make(map[string]interface{})
+		if lps := loadpointStates(cache); id < len(lps) && lps[id] != nil {
+			res = lps[id]
+		}
+
+		jsonResponse(w, r, res)
+	}
+}
+
+// validate checks all settings of the request against the loadpoint
+func (req loadpointSettingsJSON) validate(lp core.LoadPointAPI) error {
+	if req.Mode != nil && api.ChargeModeString(string(*req.Mode)) == "" {
+		return fmt.Errorf("invalid mode: %s", *req.Mode)
+	}
+
+	minCurrent, maxCurrent := lp.GetMinCurrent(), lp.GetMaxCurrent()
+	if req.MinCurrent != nil {
+		minCurrent = *req.MinCurrent
+	}
+	if req.MaxCurrent != nil {
+		maxCurrent = *req.MaxCurrent
+	}
+
+	if req.MinCurrent != nil && (minCurrent <= 0 || minCurrent > maxCurrent) {
+		return fmt.Errorf("invalid min current: %v", minCurrent)
+	}
+
+	if req.MaxCurrent != nil && (maxCurrent <= 0 || maxCurrent < minCurrent) {
+		return fmt.Errorf("invalid max current: %v", maxCurrent)
+	}
+
+	if req.Phases != nil && (*req.Phases < 1 || *req.Phases > 3) {
+		return fmt.Errorf("invalid phases: %d", *req.Phases)
+	}
+
+	vehicles := lp.GetVehicles()
+
+	if req.Vehicle != nil {
+		var found bool
+		for _, title := range vehicles {
+			if strings.EqualFold(title, *req.Vehicle) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("unknown vehicle: %s", *req.Vehicle)
+		}
+	}
+
+	for _, soc := range []struct {
+		name  string
+		value *int
+	}{
+		{"min soc", req.MinSoC},
+		{"target soc", req.TargetSoC},
+	} {
+		if soc.value == nil {
+			continue
+		}
+
+		if len(vehicles) == 0 {
+			return fmt.Errorf("%s: %w", soc.name, api.ErrNotAvailable)
+		}
+
+		if *soc.value < 0 || *soc.value > 100 {
+			return fmt.Errorf("invalid %s: %d", soc.name, *soc.value)
+		}
+	}
+
+	if req.TargetCharge != nil {
+		if soc := req.TargetCharge.SoC; soc < 0 || soc > 100 {
+			return fmt.Errorf("invalid target soc: %d", soc)
+		}
+
+		if _, err := parseTime(req.TargetCharge.Time); err != nil {
+			return fmt.Errorf("invalid time: %w", err)
+		}
+	}
+
+	if req.RemoteDemand != nil {
+		if _, err := core.ParseRemoteDemand(req.RemoteDemand.Demand); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply updates the loadpoint with all settings of the request. The request must be validated before.
+func (req loadpointSettingsJSON) apply(lp core.LoadPointAPI) error {
+	if req.Mode != nil {
+		lp.SetMode(api.ChargeModeString(string(*req.Mode)))
+	}
+
+	// raise max current first to keep min current <= max current
+	if req.MaxCurrent != nil && *req.MaxCurrent >= lp.GetMaxCurrent() {
+		lp.SetMaxCurrent(*req.MaxCurrent)
+	}
+
+	if req.MinCurrent != nil {
+		lp.SetMinCurrent(*req.MinCurrent)
+	}
+
+	if req.MaxCurrent != nil {
+		lp.SetMaxCurrent(*req.MaxCurrent)
+	}
+
+	if req.Phases != nil {
+		if err := lp.SetConfiguredPhases(*req.Phases); err != nil {
+			return err
+		}
+	}
+
+	if req.Vehicle != nil {
+		if err := lp.SetVehicle(*req.Vehicle); err != nil {
+			return err
+		}
+	}
+
+	if req.MinSoC != nil {
+		if err := lp.SetMinSoC(*req.MinSoC); err != nil {
+			return err
+		}
+	}
+
+	if req.TargetSoC != nil {
+		if err := lp.SetTargetSoC(*req.TargetSoC); err != nil {
+			return err
+		}
+	}
+
+	if req.TargetCharge != nil {
+		ts, _ := parseTime(req.TargetCharge.Time)
+		lp.SetTargetCharge(ts, req.TargetCharge.SoC)
+	}
+
+	if req.RemoteDemand != nil {
+		demand, _ := core.ParseRemoteDemand(req.RemoteDemand.Demand)
+		lp.RemoteControl(req.RemoteDemand.Source, demand)
+	}
+
+	return nil
+}
+
+// loadpointSettingsHandler updates multiple loadpoint settings at once.
+// All settings are validated before any setting is applied.
+func loadpointSettingsHandler(site core.SiteAPI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, lp, err := v1LoadPoint(site, r)
+		if err != nil {
+			errorResponse(w, http.StatusNotFound, err)
+			return
+		}
+
+		var req loadpointSettingsJSON
+		if err := decodeJSON(r, &req); err != nil {
+			errorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := req.validate(lp); err != nil {
+			errorResponse(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		if err := req.apply(lp); err != nil {
+			errorResponse(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		mode := lp.GetMode()
+		targetSoC := lp.GetTargetSoC()
+		minSoC := lp.GetMinSoC()
+		minCurrent := lp.GetMinCurrent()
+		maxCurrent := lp.GetMaxCurrent()
+		phases := lp.GetPhases()
+
+		res := loadpointSettingsJSON{
+			Mode:         &mode,
+			TargetSoC:    &targetSoC,
+			MinSoC:       &minSoC,
+			MinCurrent:   &minCurrent,
+			MaxCurrent:   &maxCurrent,
+			Phases:       &phases,
+			Vehicle:      req.Vehicle,
+			TargetCharge: req.TargetCharge,
+			RemoteDemand: req.RemoteDemand,
+		}
+
+		jsonResponse(w, r, res)
+	}
+}
+
+func siteSettingsHandler(site core.SiteAPI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req siteSettingsJSON
+		if err := decodeJSON(r, &req); err != nil {
+			errorResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if req.PrioritySoC != nil {
+			if err := site.SetPrioritySoC(*req.PrioritySoC); err != nil {
+				errorResponse(w, http.StatusUnprocessableEntity, err)
+				return
+			}
+		}
+
+		soc := site.GetPrioritySoC()
+		jsonResponse(w, r, siteSettingsJSON{PrioritySoC: &soc})
+	}
+}
+
+// openAPISchema creates the OpenAPI schema of the given type
+func openAPISchema(typ reflect.Type) map[string]interface{} {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ {
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case reflect.TypeOf(api.ChargeMode("")):
+		return map[string]interface{}{"type": "string", "enum": []api.ChargeMode{api.ModeOff, api.ModeNow, api.ModeMinPV, api.ModePV}}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": openAPISchema(typ.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object"}
+	case reflect.Struct:
+		props := make(map[string]interface{})
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+
+			schema := openAPISchema(f.Type)
+			if format := f.Tag.Get("format"); format != "" {
+				schema["format"] = format
+			}
+			props[name] = schema
+		}
+		return map[string]interface{}{"type": "object", "properties": props}
+	}
+
+	return map[string]interface{}{}
+}
+
+// openAPIDocument generates the OpenAPI description of the v1 routes
+func openAPIDocument(routes []v1Route) map[string]interface{} {
+	errorSchema := openAPISchema(reflect.TypeOf(apiErrorJSON{}))
+	paths := make(map[string]map[string]interface{})
+
+	for _, route := range routes {
+		// remove path parameter patterns
+		path := route.Pattern
+		var params []interface{}
+		for _, segment := range strings.Split(path, "/") {
+			if strings.HasPrefix(segment, "{") {
+				name := strings.SplitN(strings.Trim(segment, "{}"), ":", 2)[0]
+				path = strings.Replace(path, segment, "{"+name+"}", 1)
+				params = append(params, map[string]interface{}{
+					"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "integer"},
+				})
+			}
+		}
+		path = "/api/v1" + path
+
+		op := map[string]interface{}{
+			"summary": route.Summary,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": openAPISchema(reflect.TypeOf(route.Response))},
+					},
+				},
+				"default": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": errorSchema},
+					},
+				},
+			},
+		}
+
+		if params != nil {
+			op["parameters"] = params
+		}
+
+		if route.Request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": openAPISchema(reflect.TypeOf(route.Request))},
+				},
+			}
+		}
+
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+		paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "evcc",
+			"version": Version,
+		},
+		"paths": paths,
+	}
+}
+
+// registerV1 adds the v1 api routes to the api router
+func registerV1(router *mux.Router, site core.SiteAPI, cache *util.Cache) {
+	v1 := router.PathPrefix("/v1").Subrouter()
+
+	routes := v1Routes(site, cache)
+	for _, r := range routes {
+		v1.Methods(r.Method).Path(r.Pattern).Handler(r.Handler)
+	}
+
+	doc := openAPIDocument(routes)
+	v1.Methods(http.MethodGet).Path("/openapi.json").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonResponse(w, r, doc)
+	})
+
+	// structured errors for unknown routes
+	v1.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		errorResponse(w, http.StatusNotFound, errors.New("not found"))
+	})
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/core"
+	"github.com/andig/evcc/util"
+)
+
+type v1TestLoadPoint struct {
+	core.LoadPointAPI
+	mode       api.ChargeMode
+	targetSoC  int
+	minCurrent float64
+	maxCurrent float64
+}
+
+func (lp *v1TestLoadPoint) GetMode() api.ChargeMode       { return lp.mode }
+func (lp *v1TestLoadPoint) SetMode(mode api.ChargeMode)   { lp.mode = mode }
+func (lp *v1TestLoadPoint) GetTargetSoC() int             { return lp.targetSoC }
+func (lp *v1TestLoadPoint) GetMinSoC() int                { return 0 }
+func (lp *v1TestLoadPoint) GetMinCurrent() float64        { return lp.minCurrent }
+func (lp *v1TestLoadPoint) SetMinCurrent(current float64) { lp.minCurrent = current }
+func (lp *v1TestLoadPoint) GetVehicles() []string         { return []string{"Zoe"} }
+func (lp *v1TestLoadPoint) GetMaxCurrent() float64        { return lp.maxCurrent }
+func (lp *v1TestLoadPoint) SetMaxCurrent(current float64) { lp.maxCurrent = current }
+func (lp *v1TestLoadPoint) GetPhases() int64              { return 3 }
+func (lp *v1TestLoadPoint) SetTargetSoC(soc int) error    { lp.targetSoC = soc; return nil }
+
+type v1TestSite struct {
+	core.SiteAPI
+	lp *v1TestLoadPoint
+}
+
+func (site *v1TestSite) LoadPoints() []core.LoadPointAPI {
+	return []core.LoadPointAPI{site.lp}
+}
+
+func TestAPIv1(t *testing.T) {
+	lp := 0
+	cache := util.NewCache()
+	p := util.Param{LoadPoint: &lp, Key: "chargePower", Val: 1000.0}
+	cache.Add(p.UniqueID(), p)
+
+	site := &v1TestSite{lp: &v1TestLoadPoint{mode: api.ModeOff, minCurrent: 6, maxCurrent: 16}}
+	httpd := NewHTTPd(":7070", site, NewSocketHub(), cache)
+
+	tc := []struct {
+		method, path, body string
+		status             int
+		res                map[string]interface{}
+	}{
+		{"GET", "/api/v1/loadpoints/0", "", http.StatusOK, map[string]interface{}{"chargePower": 1000.0}},
+		{"GET", "/api/v1/loadpoints/1", "", http.StatusNotFound, map[string]interface{}{"status": 404.0, "error": "invalid loadpoint: 1"}},
+		{"PATCH", "/api/v1/loadpoints/0", `{"mode":"pv","targetSoC":80,"maxCurrent":32}`, http.StatusOK, map[string]interface{}{
+			"mode": "pv", "targetSoC": 80.0, "minSoC": 0.0, "minCurrent": 6.0, "maxCurrent": 32.0, "phases": 3.0,
+		}},
+		{"PATCH", "/api/v1/loadpoints/0", `{"mode":"foo"}`, http.StatusUnprocessableEntity, map[string]interface{}{"status": 422.0, "error": "invalid mode: foo"}},
+		{"PATCH", "/api/v1/loadpoints/0", `{"mode":"now","minCurrent":0}`, http.StatusUnprocessableEntity, map[string]interface{}{"status": 422.0, "error": "invalid min current: 0"}},
+		{"PATCH", "/api/v1/loadpoints/0", `{"mode":"now","minCurrent":-6}`, http.StatusUnprocessableEntity, map[string]interface{}{"status": 422.0, "error": "invalid min current: -6"}},
+		{"PATCH", "/api/v1/loadpoints/0", `{"mode":"now","minCurrent":40}`, http.StatusUnprocessableEntity, map[string]interface{}{"status": 422.0, "error": "invalid min current: 40"}},
+		{"PATCH", "/api/v1/loadpoints/0", `{"mode":"now","maxCurrent":16,"targetSoC":120}`, http.StatusUnprocessableEntity, map[string]interface{}{"status": 422.0, "error": "invalid target soc: 120"}},
+		{"PATCH", "/api/v1/loadpoints/0", `{"mode":"now","vehicle":"foo"}`, http.StatusUnprocessableEntity, map[string]interface{}{"status": 422.0, "error": "unknown vehicle: foo"}},
+		// rejected requests are not applied
+		{"PATCH", "/api/v1/loadpoints/0", `{}`, http.StatusOK, map[string]interface{}{"mode": "pv", "targetSoC": 80.0, "minCurrent": 6.0, "maxCurrent": 32.0}},
+		{"PATCH", "/api/v1/loadpoints/0", `{"minCurrent":40,"maxCurrent":48}`, http.StatusOK, map[string]interface{}{"minCurrent": 40.0, "maxCurrent": 48.0}},
+		{"PATCH", "/api/v1/loadpoints/0", `{"unknown":1}`, http.StatusBadRequest, nil},
+		{"GET", "/api/v1/unknown", "", http.StatusNotFound, map[string]interface{}{"status": 404.0, "error": "not found"}},
+	}
+
+	for _, tc := range tc {
+		t.Logf("%+v", tc)
+
+		w := httptest.NewRecorder()
+		httpd.Handler.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body)))
+
+		if w.Code != tc.status {
+			t.Errorf("expected status %d, got %d", tc.status, w.Code)
+		}
+
+		if tc.res == nil {
+			continue
+		}
+
+		var res map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Error(err)
+		}
+
+		for k, v := range tc.res {
+			if res[k] != v {
+				t.Errorf("%s: expected %v, got %v", k, v, res[k])
+			}
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	doc := openAPIDocument(v1Routes(nil, nil))
+
+	paths := doc["paths"].(map[string]map[string]interface{})
+
+	lp, ok := paths["/api/v1/loadpoints/{id}"]
+	if !ok {
+		t.Fatalf("missing loadpoint path: %v", paths)
+	}
+
+	patch, ok := lp["patch"].(map[string]interface{})
+	if !ok {
+		t.Fatal("missing patch operation")
+	}
+
+	schema := patch["requestBody"].(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+	props := schema["properties"].(map[string]interface{})
+
+	if mode := props["mode"].(map[string]interface{}); mode["type"] != "string" || mode["enum"] == nil {
+		t.Errorf("unexpected mode schema: %v", mode)
+	}
+
+	if _, err := json.Marshal(doc); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,33 +2,54 @@ package server
 
 import (
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/andig/evcc/core"
 	"github.com/andig/evcc/util"
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
 	influxlog "github.com/influxdata/influxdb-client-go/v2/log"
 )
 
 // InfluxConfig is the influx db configuration
 type InfluxConfig struct {
-	URL      string
-	Database string
-	Token    string
-	Org      string
-	User     string
-	Password string
-	Interval time.Duration
+	URL         string
+	Database    string
+	Token       string
+	Org         string
+	User        string
+	Password    string
+	Interval    time.Duration
+	Measurement string   // write all values as fields of single measurement instead of one measurement per value
+	Tags        []string // tags added to each point: site, loadpoint, vehicle
+}
+
+// influxDefaultTags are the tags written if not configured otherwise
+var influxDefaultTags = []string{"loadpoint", "vehicle"}
+
+// influxSessions is the measurement finished charging sessions are written to
+const influxSessions = "sessions"
+
+// influxSession tracks the charging session of a loadpoint
+type influxSession struct {
+	connected         bool
+	start             time.Time
+	chargedEnergy     float64
+	chargeDuration    time.Duration
+	connectedDuration time.Duration
 }
 
 // Influx is a influx publisher
 type Influx struct {
 	sync.Mutex
-	log      *util.Logger
-	client   influxdb2.Client
-	org      string
-	database string
+	log         *util.Logger
+	client      influxdb2.Client
+	org         string
+	database    string
+	measurement string
+	tags        map[string]bool
 }
 
 // NewInfluxClient creates new publisher for influx
@@ -46,30 +67,172 @@ func NewInfluxClient(url, token, org, user, password, database string) *Influx {
 	// handle error logging in writer
 	influxlog.Log = nil
 
-	return &Influx{
+	m := &Influx{
 		log:      log,
 		client:   client,
 		org:      org,
 		database: database,
 	}
+
+	return m.WithTags(influxDefaultTags)
 }
 
-// supportedType checks if type can be written as influx value
-func (m *Influx) supportedType(p util.Param) bool {
-	if p.Val == nil {
-		return true
+// WithMeasurement writes all values as fields of a single measurement named
+// by the given measurement, using the value keys as field names
+func (m *Influx) WithMeasurement(measurement string) *Influx {
+	m.measurement = measurement
+	return m
+}
+
+// WithTags configures the tags added to each point
+func (m *Influx) WithTags(tags []string) *Influx {
+	m.tags = make(map[string]bool)
+	for _, tag := range tags {
+		m.tags[tag] = true
 	}
+	return m
+}
 
-	switch val := p.Val.(type) {
-	case float64:
-		return true
+// influxValue converts value to a type that can be written as influx field.
+// Unsupported values including nil are not written as points without fields are rejected.
+func influxValue(val interface{}) (interface{}, bool) {
+	switch val := val.(type) {
+	case float64, bool, string:
+		return val, true
+	case int:
+		return int64(val), true
+	case int64:
+		return val, true
+	case time.Duration:
+		return val.Seconds(), true
 	case [3]float64:
-		return true
+		return val[:], true
 	case []float64:
-		return len(val) == 3
-	default:
-		return false
+		return val, len(val) == 3
+	case time.Time:
+		return nil, false
+	}
+
+	// string types like api.ChargeMode
+	if v := reflect.ValueOf(val); v.Kind() == reflect.String {
+		return v.String(), true
+	}
+
+	return nil, false
+}
+
+// fields converts value to point fields. Phase values are added as l1..l3
+// with their total as main value.
+func (m *Influx) fields(key string, val interface{}) map[string]interface{} {
+	// main value is named by key for single measurement
+	name := "value"
+	if m.measurement != "" {
+		name = key
+	}
+
+	fields := make(map[string]interface{})
+
+	// add slice as phase values
+	if phases, ok := val.([]float64); ok {
+		var total float64
+		for i, v := range phases {
+			total += v
+			phase := fmt.Sprintf("l%d", i+1)
+			if m.measurement != "" {
+				phase = fmt.Sprintf("%sL%d", key, i+1)
+			}
+			fields[phase] = v
+		}
+
+		// add total as main value
+		val = total
+	}
+
+	fields[name] = val
+
+	return fields
+}
+
+// point creates a point for the given key
+func (m *Influx) point(key string, tags map[string]string, fields map[string]interface{}, ts time.Time) *write.Point {
+	measurement := key
+	if m.measurement != "" {
+		measurement = m.measurement
+	}
+
+	return influxdb2.NewPoint(measurement, tags, fields, ts)
+}
+
+// tagSet returns the configured tags for a site or loadpoint value
+func (m *Influx) tagSet(site, loadpoint, vehicle string, isLoadpoint bool) map[string]string {
+	tags := make(map[string]string)
+
+	if m.tags["site"] {
+		tags["site"] = site
+	}
+	if isLoadpoint {
+		if m.tags["loadpoint"] {
+			tags["loadpoint"] = loadpoint
+		}
+		if m.tags["vehicle"] {
+			tags["vehicle"] = vehicle
+		}
 	}
+
+	return tags
+}
+
+// update tracks the loadpoint's session and returns the fields of the
+// finished session when the vehicle is disconnected
+func (s *influxSession) update(param util.Param, now time.Time) map[string]interface{} {
+	switch param.Key {
+	case "chargedEnergy":
+		if val, ok := param.Val.(float64); ok {
+			s.chargedEnergy = val
+		}
+
+	case "chargeDuration":
+		if val, ok := param.Val.(time.Duration); ok {
+			s.chargeDuration = val
+		}
+
+	case "connectedDuration":
+		if val, ok := param.Val.(time.Duration); ok {
+			s.connectedDuration = val
+		}
+
+	case "connected":
+		val, ok := param.Val.(bool)
+		if !ok || val == s.connected {
+			break
+		}
+
+		s.connected = val
+		if val {
+			s.start = now
+			s.connectedDuration = 0
+			break
+		}
+
+		// vehicle disconnected
+		if s.start.IsZero() {
+			break
+		}
+
+		duration := s.connectedDuration
+		if duration == 0 {
+			duration = now.Sub(s.start)
+		}
+
+		return map[string]interface{}{
+			"start":             s.start.Unix(),
+			"chargedEnergy":     s.chargedEnergy,
+			"chargeDuration":    s.chargeDuration.Seconds(),
+			"connectedDuration": duration.Seconds(),
+		}
+	}
+
+	return nil
 }
 
 // Run Influx publisher
@@ -83,55 +246,54 @@ func (m *Influx) Run(loadPoints []core.LoadPointAPI, in <-chan util.Param) {
 		}
 	}()
 
-	// track active vehicle per loadpoint
+	// track site title, active vehicle and session per loadpoint
+	var site string
 	vehicles := make(map[int]string)
+	sessions := make(map[int]*influxSession)
 
 	// add points to batch for async writing
 	for param := range in {
-		// vehicle name
+		now := time.Now()
+
+		var loadpoint, vehicle string
 		if param.LoadPoint != nil {
+			id := *param.LoadPoint
+			loadpoint = loadPoints[id].Name()
+
+			// vehicle name
 			if name, ok := param.Val.(string); ok && param.Key == "socTitle" {
-				vehicles[*param.LoadPoint] = name
-				continue
+				vehicles[id] = name
 			}
-		}
-
-		if !m.supportedType(param) {
-			continue
-		}
-
-		tags := map[string]string{}
-		if param.LoadPoint != nil {
-			tags["loadpoint"] = loadPoints[*param.LoadPoint].Name()
-			tags["vehicle"] = vehicles[*param.LoadPoint]
-		}
+			vehicle = vehicles[id]
 
-		fields := map[string]interface{}{}
+			// charging session
+			session, ok := sessions[id]
+			if !ok {
+				session = new(influxSession)
+				sessions[id] = session
+			}
 
-		// array to slice
-		val := param.Val
-		if v, ok := val.([3]float64); ok {
-			val = v[:]
-		}
+			if fields := session.update(param, now); fields != nil {
+				tags := m.tagSet(site, loadpoint, vehicle, true)
 
-		// add slice as phase values
-		if phases, ok := val.([]float64); ok {
-			var total float64
-			for i, v := range phases {
-				total += v
-				fields[fmt.Sprintf("l%d", i+1)] = v
+				m.log.TRACE.Printf("write session %v (%v)", fields, tags)
+				writer.WritePoint(influxdb2.NewPoint(influxSessions, tags, fields, now))
 			}
+		} else if name, ok := param.Val.(string); ok && param.Key == "siteTitle" {
+			site = name
+		}
 
-			// add total as "value"
-			val = total
+		val, ok := influxValue(param.Val)
+		if !ok {
+			continue
 		}
 
-		fields["value"] = val
+		tags := m.tagSet(site, loadpoint, vehicle, param.LoadPoint != nil)
+		fields := m.fields(param.Key, val)
 
 		// write asynchronously
 		m.log.TRACE.Printf("write %s=%v (%v)", param.Key, param.Val, tags)
-		p := influxdb2.NewPoint(param.Key, tags, fields, time.Now())
-		writer.WritePoint(p)
+		writer.WritePoint(m.point(param.Key, tags, fields, now))
 	}
 
 	m.client.Close()
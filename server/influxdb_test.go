@@ -0,0 +1,87 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/util"
+)
+
+func TestInfluxFields(t *testing.T) {
+	tc := []struct {
+		measurement string
+		key         string
+		val         interface{}
+		ok          bool
+		fields      map[string]interface{}
+	}{
+		{"", "gridPower", 1.0, true, map[string]interface{}{"value": 1.0}},
+		{"", "charging", true, true, map[string]interface{}{"value": true}},
+		{"", "mode", api.ModePV, true, map[string]interface{}{"value": "pv"}},
+		{"", "phases", int64(3), true, map[string]interface{}{"value": int64(3)}},
+		{"", "chargeDuration", time.Minute, true, map[string]interface{}{"value": 60.0}},
+		{"", "targetTime", time.Now(), false, nil},
+		{"", "vehicleTitle", nil, false, nil},
+		{"", "chargeCurrents", []float64{1, 2}, false, nil},
+		{"", "chargeCurrents", []float64{1, 2, 3}, true, map[string]interface{}{"value": 6.0, "l1": 1.0, "l2": 2.0, "l3": 3.0}},
+		{"evcc", "gridPower", 1.0, true, map[string]interface{}{"gridPower": 1.0}},
+		{"evcc", "gridCurrents", [3]float64{1, 2, 3}, true, map[string]interface{}{
+			"gridCurrents": 6.0, "gridCurrentsL1": 1.0, "gridCurrentsL2": 2.0, "gridCurrentsL3": 3.0,
+		}},
+	}
+
+	for _, tc := range tc {
+		t.Logf("%+v", tc)
+
+		m := &Influx{measurement: tc.measurement}
+
+		val, ok := influxValue(tc.val)
+		if ok != tc.ok {
+			t.Errorf("expected supported %v, got %v", tc.ok, ok)
+		}
+		if !ok {
+			continue
+		}
+
+		if fields := m.fields(tc.key, val); !reflect.DeepEqual(fields, tc.fields) {
+			t.Errorf("expected %v, got %v", tc.fields, fields)
+		}
+	}
+}
+
+func TestInfluxSession(t *testing.T) {
+	s := new(influxSession)
+	start := time.Now()
+
+	for _, p := range []util.Param{
+		{Key: "connected", Val: true},
+		{Key: "chargedEnergy", Val: 0.0},
+		{Key: "chargedEnergy", Val: 5000.0},
+		{Key: "chargeDuration", Val: time.Hour},
+		{Key: "connected", Val: true},
+	} {
+		if res := s.update(p, start); res != nil {
+			t.Errorf("unexpected session %v", res)
+		}
+	}
+
+	res := s.update(util.Param{Key: "connected", Val: false}, start.Add(2*time.Hour))
+
+	expected := map[string]interface{}{
+		"start":             start.Unix(),
+		"chargedEnergy":     5000.0,
+		"chargeDuration":    3600.0,
+		"connectedDuration": 7200.0,
+	}
+
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+
+	// no session without connect
+	if res := new(influxSession).update(util.Param{Key: "connected", Val: false}, start); res != nil {
+		t.Errorf("unexpected session %v", res)
+	}
+}
@@ -0,0 +1,394 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/core"
+	"github.com/andig/evcc/util"
+)
+
+// ModbusServerConfig is the modbus server configuration
+type ModbusServerConfig struct {
+	Port int
+}
+
+// modbus function and exception codes
+const (
+	mbReadHoldingRegisters   = 0x03
+	mbReadInputRegisters     = 0x04
+	mbWriteSingleRegister    = 0x06
+	mbWriteMultipleRegisters = 0x10
+
+	mbIllegalFunction    = 0x01
+	mbIllegalAddress     = 0x02
+	mbIllegalValue       = 0x03
+	mbSlaveDeviceFailure = 0x04
+)
+
+// register layout
+const (
+	mbLoadpointOffset = 100 // address of the first loadpoint block
+	mbLoadpointSize   = 100 // number of registers per loadpoint block
+)
+
+// modbus register encodings
+const (
+	mbUint16  = "uint16"  // 1 register
+	mbFloat32 = "float32" // 2 registers, IEEE754 big endian
+)
+
+var (
+	mbStatuses = []api.ChargeStatus{api.StatusA, api.StatusB, api.StatusC}
+	mbModes    = []api.ChargeMode{api.ModeOff, api.ModeNow, api.ModeMinPV, api.ModePV}
+)
+
+// modbusRegister maps a cached value to holding register address relative to its block
+type modbusRegister struct {
+	addr     uint16
+	key      string
+	encoding string
+	write    func(lp core.LoadPointAPI, val uint16) error
+}
+
+// mbSiteRegisters is the site register layout starting at address 0
+var mbSiteRegisters = []modbusRegister{
+	{0, "gridPower", mbFloat32, nil},
+	{2, "pvPower", mbFloat32, nil},
+	{4, "batteryPower", mbFloat32, nil},
+	{6, "batterySoC", mbFloat32, nil},
+	{8, "loadpoints", mbUint16, nil},
+}
+
+// mbLoadpointRegisters is the loadpoint register layout relative to the loadpoint's block
+var mbLoadpointRegisters = []modbusRegister{
+	{0, "status", mbUint16, nil},
+	{1, "mode", mbUint16, func(lp core.LoadPointAPI, val uint16) error {
+		if int(val) >= len(mbModes) {
+			return fmt.Errorf("invalid mode: %d", val)
+		}
+		lp.SetMode(mbModes[val])
+		return nil
+	}},
+	{2, "targetSoC", mbUint16, func(lp core.LoadPointAPI, val uint16) error {
+		return lp.SetTargetSoC(int(val))
+	}},
+	{3, "maxCurrent", mbUint16, func(lp core.LoadPointAPI, val uint16) error {
+		if float64(val) < lp.GetMinCurrent() {
+			return fmt.Errorf("invalid max current: %d", val)
+		}
+		lp.SetMaxCurrent(float64(val))
+		return nil
+	}},
+	{4, "enabled", mbUint16, nil},
+	{5, "activePhases", mbUint16, nil},
+	{10, "chargePower", mbFloat32, nil},
+	{12, "chargedEnergy", mbFloat32, nil},
+	{14, "socCharge", mbFloat32, nil},
+	{16, "chargeCurrent", mbFloat32, nil},
+}
+
+// modbusError is a modbus exception response
+type modbusError byte
+
+func (e modbusError) Error() string {
+	return fmt.Sprintf("modbus exception: %d", byte(e))
+}
+
+// ModbusServer is a modbus tcp server exposing site and loadpoint values as holding registers
+type ModbusServer struct {
+	log      *util.Logger
+	listener net.Listener
+	site     core.SiteAPI
+	cache    *util.Cache
+}
+
+// NewModbusServer creates modbus server listening on the given address
+func NewModbusServer(addr string, site core.SiteAPI, cache *util.Cache) (*ModbusServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &ModbusServer{
+		log:      util.NewLogger("modbus"),
+		listener: listener,
+		site:     site,
+		cache:    cache,
+	}
+
+	return srv, nil
+}
+
+// Addr returns the server's listening address
+func (m *ModbusServer) Addr() net.Addr {
+	return m.listener.Addr()
+}
+
+// Run accepts and serves client connections
+func (m *ModbusServer) Run() {
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			m.log.ERROR.Println(err)
+			return
+		}
+
+		go m.serve(conn)
+	}
+}
+
+// serve handles modbus tcp frames of a single client
+func (m *ModbusServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	header := make([]byte, 7)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			if !errors.Is(err, io.EOF) {
+				m.log.DEBUG.Printf("%s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		// protocol identifier and length including unit id
+		length := binary.BigEndian.Uint16(header[4:])
+		if binary.BigEndian.Uint16(header[2:]) != 0 || length < 2 || length > 254 {
+			m.log.DEBUG.Printf("%s: invalid frame", conn.RemoteAddr())
+			return
+		}
+
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			m.log.DEBUG.Printf("%s: %v", conn.RemoteAddr(), err)
+			return
+		}
+
+		res := m.handle(pdu)
+
+		frame := make([]byte, 7, 7+len(res))
+		copy(frame, header[:4])
+		binary.BigEndian.PutUint16(frame[4:], uint16(len(res)+1))
+		frame[6] = header[6]
+
+		if _, err := conn.Write(append(frame, res...)); err != nil {
+			m.log.DEBUG.Printf("%s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// handle executes the request pdu and returns the response pdu
+func (m *ModbusServer) handle(pdu []byte) []byte {
+	fc := pdu[0]
+
+	res, err := m.execute(fc, pdu[1:])
+	if err != nil {
+		code := modbusError(mbSlaveDeviceFailure)
+		if mbErr, ok := err.(modbusError); ok {
+			code = mbErr
+		} else {
+			m.log.ERROR.Printf("function %d: %v", fc, err)
+		}
+
+		return []byte{fc | 0x80, byte(code)}
+	}
+
+	return append([]byte{fc}, res...)
+}
+
+func (m *ModbusServer) execute(fc byte, data []byte) ([]byte, error) {
+	switch fc {
+	case mbReadHoldingRegisters, mbReadInputRegisters:
+		if len(data) != 4 {
+			return nil, modbusError(mbIllegalValue)
+		}
+
+		addr := binary.BigEndian.Uint16(data)
+		qty := binary.BigEndian.Uint16(data[2:])
+		if qty == 0 || qty > 125 {
+			return nil, modbusError(mbIllegalValue)
+		}
+
+		regs, err := m.read(addr, qty)
+		if err != nil {
+			return nil, err
+		}
+
+		res := make([]byte, 1+2*len(regs))
+		res[0] = byte(2 * len(regs))
+		for i, reg := range regs {
+			binary.BigEndian.PutUint16(res[1+2*i:], reg)
+		}
+
+		return res, nil
+
+	case mbWriteSingleRegister:
+		if len(data) != 4 {
+			return nil, modbusError(mbIllegalValue)
+		}
+
+		addr := binary.BigEndian.Uint16(data)
+		if err := m.write(addr, binary.BigEndian.Uint16(data[2:])); err != nil {
+			return nil, err
+		}
+
+		return data, nil
+
+	case mbWriteMultipleRegisters:
+		if len(data) < 5 {
+			return nil, modbusError(mbIllegalValue)
+		}
+
+		addr := binary.BigEndian.Uint16(data)
+		qty := binary.BigEndian.Uint16(data[2:])
+		if qty == 0 || int(data[4]) != 2*int(qty) || len(data) != 5+2*int(qty) {
+			return nil, modbusError(mbIllegalValue)
+		}
+
+		for i := uint16(0); i < qty; i++ {
+			if err := m.write(addr+i, binary.BigEndian.Uint16(data[5+2*i:])); err != nil {
+				return nil, err
+			}
+		}
+
+		return data[:4], nil
+
+	default:
+		return nil, modbusError(mbIllegalFunction)
+	}
+}
+
+// block resolves the register address to the site (id -1) or loadpoint block and its register layout
+func (m *ModbusServer) block(addr uint16) (int, uint16, []modbusRegister) {
+	if addr < mbLoadpointOffset {
+		return -1, addr, mbSiteRegisters
+	}
+
+	addr -= mbLoadpointOffset
+	return int(addr / mbLoadpointSize), addr % mbLoadpointSize, mbLoadpointRegisters
+}
+
+// read returns qty register values starting at addr. Unmapped registers read as 0.
+func (m *ModbusServer) read(addr, qty uint16) ([]uint16, error) {
+	end := uint32(addr) + uint32(qty)
+	if end > uint32(mbLoadpointOffset+mbLoadpointSize*len(m.site.LoadPoints())) {
+		return nil, modbusError(mbIllegalAddress)
+	}
+
+	res := make([]uint16, 0, qty)
+	for a := uint32(addr); a < end; a++ {
+		id, offset, registers := m.block(uint16(a))
+		res = append(res, m.register(id, offset, registers))
+	}
+
+	return res, nil
+}
+
+// register returns the value of the register at the block offset
+func (m *ModbusServer) register(id int, offset uint16, registers []modbusRegister) uint16 {
+	for _, reg := range registers {
+		switch {
+		case reg.encoding == mbUint16 && offset == reg.addr:
+			if val := m.value(id, reg.key); val > 0 {
+				return uint16(val)
+			}
+			return 0
+
+		case reg.encoding == mbFloat32 && offset == reg.addr:
+			return uint16(math.Float32bits(float32(m.value(id, reg.key))) >> 16)
+
+		case reg.encoding == mbFloat32 && offset == reg.addr+1:
+			return uint16(math.Float32bits(float32(m.value(id, reg.key))))
+		}
+	}
+
+	return 0
+}
+
+// write forwards register value to the loadpoint
+func (m *ModbusServer) write(addr, val uint16) error {
+	id, offset, registers := m.block(addr)
+	if id < 0 || id >= len(m.site.LoadPoints()) {
+		return modbusError(mbIllegalAddress)
+	}
+
+	for _, reg := range registers {
+		if reg.addr == offset && reg.write != nil {
+			m.log.DEBUG.Printf("lp-%d set %s: %d", id+1, reg.key, val)
+
+			if err := reg.write(m.site.LoadPoints()[id], val); err != nil {
+				m.log.ERROR.Printf("lp-%d set %s: %v", id+1, reg.key, err)
+				return modbusError(mbIllegalValue)
+			}
+
+			return nil
+		}
+	}
+
+	return modbusError(mbIllegalAddress)
+}
+
+// value returns the numeric register value for the site (id -1) or loadpoint value
+func (m *ModbusServer) value(id int, key string) float64 {
+	switch {
+	case id < 0 && key == "loadpoints":
+		return float64(len(m.site.LoadPoints()))
+
+	case id >= 0 && key == "status":
+		current := api.StatusA
+		if m.loadpointValue(id, "charging") == true {
+			current = api.StatusC
+		} else if m.loadpointValue(id, "connected") == true {
+			current = api.StatusB
+		}
+
+		for i, status := range mbStatuses {
+			if status == current {
+				return float64(i)
+			}
+		}
+	}
+
+	var val interface{}
+	if id < 0 {
+		val = m.cache.Get(util.Param{Key: key}.UniqueID()).Val
+	} else {
+		val = m.loadpointValue(id, key)
+	}
+
+	switch val := val.(type) {
+	case float64:
+		return val
+	case int:
+		return float64(val)
+	case int64:
+		return float64(val)
+	case bool:
+		if val {
+			return 1
+		}
+	case api.ChargeMode:
+		for i, mode := range mbModes {
+			if strings.EqualFold(string(mode), string(val)) {
+				return float64(i)
+			}
+		}
+	}
+
+	return 0
+}
+
+func (m *ModbusServer) loadpointValue(id int, key string) interface{} {
+	p, err := m.cache.GetChecked(id, key)
+	if err != nil {
+		return nil
+	}
+	return p.Val
+}
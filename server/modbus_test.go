@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/core"
+	"github.com/andig/evcc/util"
+	"github.com/andig/evcc/util/modbus"
+)
+
+type modbusLoadPoint struct {
+	core.LoadPointAPI
+	mode       api.ChargeMode
+	targetSoC  int
+	maxCurrent float64
+}
+
+func (lp *modbusLoadPoint) SetMode(mode api.ChargeMode) { lp.mode = mode }
+
+func (lp *modbusLoadPoint) SetTargetSoC(soc int) error {
+	lp.targetSoC = soc
+	return nil
+}
+
+func (lp *modbusLoadPoint) GetMinCurrent() float64 { return 6 }
+
+func (lp *modbusLoadPoint) SetMaxCurrent(current float64) { lp.maxCurrent = current }
+
+type modbusSite struct {
+	core.SiteAPI
+	lp *modbusLoadPoint
+}
+
+func (site *modbusSite) LoadPoints() []core.LoadPointAPI {
+	return []core.LoadPointAPI{site.lp}
+}
+
+func TestModbusServer(t *testing.T) {
+	lp := 0
+	cache := util.NewCache()
+	for _, p := range []util.Param{
+		{Key: "gridPower", Val: -1500.0},
+		{Key: "batterySoC", Val: 50.0},
+		{LoadPoint: &lp, Key: "connected", Val: true},
+		{LoadPoint: &lp, Key: "charging", Val: true},
+		{LoadPoint: &lp, Key: "mode", Val: api.ModeMinPV},
+		{LoadPoint: &lp, Key: "targetSoC", Val: 80},
+		{LoadPoint: &lp, Key: "chargePower", Val: 11000.0},
+	} {
+		cache.Add(p.UniqueID(), p)
+	}
+
+	site := &modbusSite{lp: new(modbusLoadPoint)}
+
+	srv, err := NewModbusServer("127.0.0.1:0", site, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Run()
+
+	conn, err := modbus.NewConnection(srv.Addr().String(), "", "", 0, false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	float := func(b []byte) float64 {
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	}
+
+	// site
+	b, err := conn.ReadHoldingRegisters(0, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f := float(b[0:]); f != -1500 {
+		t.Errorf("gridPower: expected -1500, got %v", f)
+	}
+	if f := float(b[12:]); f != 50 {
+		t.Errorf("batterySoC: expected 50, got %v", f)
+	}
+	if u := binary.BigEndian.Uint16(b[16:]); u != 1 {
+		t.Errorf("loadpoints: expected 1, got %v", u)
+	}
+
+	// loadpoint
+	b, err = conn.ReadHoldingRegisters(100, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u := binary.BigEndian.Uint16(b[0:]); u != 2 {
+		t.Errorf("status: expected 2, got %v", u)
+	}
+	if u := binary.BigEndian.Uint16(b[2:]); u != 2 {
+		t.Errorf("mode: expected 2, got %v", u)
+	}
+	if u := binary.BigEndian.Uint16(b[4:]); u != 80 {
+		t.Errorf("targetSoC: expected 80, got %v", u)
+	}
+	if f := float(b[20:]); f != 11000 {
+		t.Errorf("chargePower: expected 11000, got %v", f)
+	}
+
+	// out of range
+	if _, err := conn.ReadHoldingRegisters(200, 1); err == nil {
+		t.Error("expected illegal address error")
+	}
+
+	// writes
+	if _, err := conn.WriteSingleRegister(101, 3); err != nil {
+		t.Error(err)
+	}
+	if _, err := conn.WriteMultipleRegisters(102, 2, []byte{0, 90, 0, 16}); err != nil {
+		t.Error(err)
+	}
+
+	if site.lp.mode != api.ModePV || site.lp.targetSoC != 90 || site.lp.maxCurrent != 16 {
+		t.Errorf("unexpected loadpoint settings: %+v", site.lp)
+	}
+
+	// invalid values and read-only registers
+	if _, err := conn.WriteSingleRegister(101, 4); err == nil {
+		t.Error("expected illegal value error")
+	}
+	if _, err := conn.WriteSingleRegister(103, 1); err == nil {
+		t.Error("expected illegal value error")
+	}
+	if _, err := conn.WriteSingleRegister(110, 1); err == nil {
+		t.Error("expected illegal address error")
+	}
+}
@@ -1,8 +1,10 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/andig/evcc/api"
@@ -11,10 +13,34 @@ import (
 	"github.com/andig/evcc/util"
 )
 
+// MQTTConfig contains the optional MQTT publishing settings
+type MQTTConfig struct {
+	Retain   bool                       // retain published values
+	Envelope bool                       // publish values as json including unit and timestamp
+	Topics   map[string]MQTTTopicConfig // publishing options by value key
+	State    MQTTStateConfig            // aggregated json state
+}
+
+// MQTTTopicConfig contains the publishing options of a single value
+type MQTTTopicConfig struct {
+	Retain *bool
+	Qos    *byte
+}
+
+// MQTTStateConfig contains the aggregated json state settings
+type MQTTStateConfig struct {
+	Topic    string        // topic relative to root topic, publishing is disabled if empty
+	Interval time.Duration // publish interval in addition to publishing on change
+}
+
 // MQTT is the MQTT server. It uses the MQTT client for publishing.
 type MQTT struct {
-	Handler *mqtt.Client
-	root    string
+	Handler   *mqtt.Client
+	root      string
+	conf      MQTTConfig
+	cache     *util.Cache
+	state     map[string]interface{}
+	discovery *discovery
 }
 
 // NewMQTT creates MQTT server
@@ -25,6 +51,27 @@ func NewMQTT(root string) *MQTT {
 	}
 }
 
+// WithDiscovery enables publishing Home Assistant discovery configs using the given prefix
+func (m *MQTT) WithDiscovery(prefix string) *MQTT {
+	m.discovery = newDiscovery(m, prefix)
+	return m
+}
+
+// WithConfig applies the publishing settings. The cache is used for publishing the aggregated state.
+func (m *MQTT) WithConfig(conf MQTTConfig, cache *util.Cache) *MQTT {
+	// config keys are case-insensitive
+	topics := make(map[string]MQTTTopicConfig)
+	for k, v := range conf.Topics {
+		topics[strings.ToLower(k)] = v
+	}
+	conf.Topics = topics
+
+	m.conf = conf
+	m.cache = cache
+
+	return m
+}
+
 func (m *MQTT) encode(v interface{}) string {
 	var s string
 	switch val := v.(type) {
@@ -48,6 +95,91 @@ func (m *MQTT) publishSingleValue(topic string, retained bool, payload interface
 	go m.Handler.WaitForToken(token)
 }
 
+// mqttEnvelope is the json payload format of published values
+type mqttEnvelope struct {
+	Value     interface{} `json:"value"`
+	Unit      string      `json:"unit,omitempty"`
+	Timestamp int64       `json:"ts"`
+}
+
+// envelope encodes the value as json including unit and timestamp
+func (m *MQTT) envelope(key string, v interface{}) string {
+	res := mqttEnvelope{
+		Value:     jsonValue(v),
+		Unit:      haEntities[key].Unit,
+		Timestamp: time.Now().Unix(),
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		return m.encode(v)
+	}
+
+	return string(b)
+}
+
+// jsonValue converts values into their json representation as used by the MQTT api
+func jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case time.Time:
+		return val.Unix()
+	case time.Duration:
+		return int64(val.Seconds())
+	case fmt.Stringer:
+		return val.String()
+	default:
+		return v
+	}
+}
+
+// topicConfig returns the publishing options for the given value key
+func (m *MQTT) topicConfig(key string) (retained bool, qos byte) {
+	retained, qos = m.conf.Retain, m.Handler.Qos
+
+	if conf, ok := m.conf.Topics[strings.ToLower(key)]; ok {
+		if conf.Retain != nil {
+			retained = *conf.Retain
+		}
+		if conf.Qos != nil {
+			qos = *conf.Qos
+		}
+	}
+
+	return retained, qos
+}
+
+// publishValue publishes a single parameter value honouring the topic configuration
+func (m *MQTT) publishValue(topic, key string, val interface{}) {
+	retained, qos := m.topicConfig(key)
+
+	payload := m.encode(val)
+	if m.conf.Envelope {
+		payload = m.envelope(key, val)
+	}
+
+	token := m.Handler.Client.Publish(topic, qos, retained, payload)
+	go m.Handler.WaitForToken(token)
+}
+
+// publishParam publishes a parameter value including phase values
+func (m *MQTT) publishParam(topic string, p util.Param) {
+	val := p.Val
+
+	if slice, ok := val.([]float64); ok && len(slice) == 3 {
+		// publish phase values
+		var total float64
+		for i, v := range slice {
+			total += v
+			m.publishValue(fmt.Sprintf("%s/l%d", topic, i+1), p.Key, v)
+		}
+
+		// publish sum value
+		val = total
+	}
+
+	m.publishValue(topic, p.Key, val)
+}
+
 func (m *MQTT) publish(topic string, retained bool, payload interface{}) {
 	if slice, ok := payload.([]float64); ok && len(slice) == 3 {
 		// publish phase values
@@ -64,21 +196,162 @@ func (m *MQTT) publish(topic string, retained bool, payload interface{}) {
 	m.publishSingleValue(topic, retained, payload)
 }
 
+// setterResult is published to the setter's result topic
+type setterResult struct {
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// listenSetter attaches the setter handler to the topic and publishes its result or error
+func (m *MQTT) listenSetter(topic string, handler func(string) (interface{}, error)) {
+	m.Handler.Listen(topic, func(payload string) {
+		res := setterResult{}
+
+		val, err := handler(strings.TrimSpace(payload))
+		if err == nil {
+			res.Result = val
+		} else {
+			log.ERROR.Printf("mqtt: %s: %v", topic, err)
+			res.Error = err.Error()
+		}
+
+		b, err := json.Marshal(res)
+		if err != nil {
+			log.ERROR.Printf("mqtt: %s: %v", topic, err)
+			return
+		}
+
+		m.publishSingleValue(topic+"/result", false, string(b))
+	})
+}
+
+// parseTime parses time in RFC3339 or local time format
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05", s, timezone())
+}
+
 func (m *MQTT) listenSetters(topic string, apiHandler core.LoadPointAPI) {
-	m.Handler.Listen(topic+"/mode/set", func(payload string) {
-		apiHandler.SetMode(api.ChargeMode(payload))
+	m.listenSetter(topic+"/mode/set", func(payload string) (interface{}, error) {
+		mode := api.ChargeModeString(payload)
+		if mode == "" {
+			return nil, fmt.Errorf("invalid mode: %s", payload)
+		}
+
+		apiHandler.SetMode(mode)
+		return apiHandler.GetMode(), nil
 	})
-	m.Handler.Listen(topic+"/minSoC/set", func(payload string) {
+
+	m.listenSetter(topic+"/minSoC/set", func(payload string) (interface{}, error) {
 		soc, err := strconv.Atoi(payload)
 		if err == nil {
-			_ = apiHandler.SetMinSoC(soc)
+			err = apiHandler.SetMinSoC(soc)
 		}
+		return apiHandler.GetMinSoC(), err
 	})
-	m.Handler.Listen(topic+"/targetSoC/set", func(payload string) {
+
+	m.listenSetter(topic+"/targetSoC/set", func(payload string) (interface{}, error) {
 		soc, err := strconv.Atoi(payload)
 		if err == nil {
-			_ = apiHandler.SetTargetSoC(soc)
+			err = apiHandler.SetTargetSoC(soc)
+		}
+		return apiHandler.GetTargetSoC(), err
+	})
+
+	m.listenSetter(topic+"/targetCharge/set", func(payload string) (interface{}, error) {
+		var req struct {
+			SoC  int    `json:"soc"`
+			Time string `json:"time"`
+		}
+
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			return nil, fmt.Errorf("invalid payload: %w", err)
+		}
+
+		finishAt, err := parseTime(req.Time)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time: %w", err)
 		}
+
+		apiHandler.SetTargetCharge(finishAt, req.SoC)
+
+		return struct {
+			SoC  int       `json:"soc"`
+			Time time.Time `json:"time"`
+		}{
+			SoC:  req.SoC,
+			Time: finishAt,
+		}, nil
+	})
+
+	m.listenSetter(topic+"/remoteDemand/set", func(payload string) (interface{}, error) {
+		req := struct {
+			Demand string `json:"demand"`
+			Source string `json:"source"`
+		}{
+			Demand: payload,
+			Source: "mqtt",
+		}
+
+		if strings.HasPrefix(payload, "{") {
+			if err := json.Unmarshal([]byte(payload), &req); err != nil {
+				return nil, fmt.Errorf("invalid payload: %w", err)
+			}
+		}
+
+		demand, err := core.ParseRemoteDemand(req.Demand)
+		if err != nil {
+			return nil, err
+		}
+
+		apiHandler.RemoteControl(req.Source, demand)
+
+		return struct {
+			Demand core.RemoteDemand `json:"demand"`
+			Source string            `json:"source"`
+		}{
+			Demand: demand,
+			Source: req.Source,
+		}, nil
+	})
+
+	m.listenSetter(topic+"/minCurrent/set", func(payload string) (interface{}, error) {
+		current, err := strconv.ParseFloat(payload, 64)
+		if err == nil && (current <= 0 || current > apiHandler.GetMaxCurrent()) {
+			err = fmt.Errorf("invalid current: %s", payload)
+		}
+		if err == nil {
+			apiHandler.SetMinCurrent(current)
+		}
+		return apiHandler.GetMinCurrent(), err
+	})
+
+	m.listenSetter(topic+"/maxCurrent/set", func(payload string) (interface{}, error) {
+		current, err := strconv.ParseFloat(payload, 64)
+		if err == nil && current < apiHandler.GetMinCurrent() {
+			err = fmt.Errorf("invalid current: %s", payload)
+		}
+		if err == nil {
+			apiHandler.SetMaxCurrent(current)
+		}
+		return apiHandler.GetMaxCurrent(), err
+	})
+
+	m.listenSetter(topic+"/phases/set", func(payload string) (interface{}, error) {
+		phases, err := strconv.ParseInt(payload, 10, 64)
+		if err == nil {
+			err = apiHandler.SetConfiguredPhases(phases)
+		}
+		return apiHandler.GetPhases(), err
+	})
+
+	m.listenSetter(topic+"/vehicle/set", func(payload string) (interface{}, error) {
+		if err := apiHandler.SetVehicle(payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
 	})
 }
 
@@ -88,12 +361,19 @@ func (m *MQTT) Run(site core.SiteAPI, in <-chan util.Param) {
 	topic := fmt.Sprintf("%s/status", m.root)
 	m.publish(topic, true, "online")
 
+	// home assistant discovery
+	if m.discovery != nil {
+		m.discovery.loadpoints = site.LoadPoints()
+		m.discovery.collect()
+	}
+
 	// site setters
-	m.Handler.Listen(fmt.Sprintf("%s/site/prioritySoC/set", m.root), func(payload string) {
-		soc, err := strconv.Atoi(payload)
+	m.listenSetter(fmt.Sprintf("%s/site/prioritySoC/set", m.root), func(payload string) (interface{}, error) {
+		soc, err := strconv.ParseFloat(payload, 64)
 		if err == nil {
-			_ = site.SetPrioritySoC(float64(soc))
+			err = site.SetPrioritySoC(soc)
 		}
+		return soc, err
 	})
 
 	// number of loadpoints
@@ -110,22 +390,51 @@ func (m *MQTT) Run(site core.SiteAPI, in <-chan util.Param) {
 	updated := time.Now().Unix()
 	m.publish(fmt.Sprintf("%s/updated", m.root), true, updated)
 
+	// aggregated state
+	var stateTicker, stateChanged <-chan time.Time
+	if m.stateEnabled() && m.conf.State.Interval > 0 {
+		stateTicker = time.NewTicker(m.conf.State.Interval).C
+	}
+
 	// publish
-	for p := range in {
-		topic := fmt.Sprintf("%s/site", m.root)
-		if p.LoadPoint != nil {
-			id := *p.LoadPoint + 1
-			topic = fmt.Sprintf("%s/loadpoints/%d", m.root, id)
-		}
+	for {
+		select {
+		case p, ok := <-in:
+			if !ok {
+				return // break if channel closed
+			}
 
-		// alive indicator
-		if now := time.Now().Unix(); now != updated {
-			updated = now
-			m.publish(fmt.Sprintf("%s/updated", m.root), true, updated)
-		}
+			// alive indicator
+			if now := time.Now().Unix(); now != updated {
+				updated = now
+				m.publish(fmt.Sprintf("%s/updated", m.root), true, updated)
+			}
+
+			// home assistant discovery
+			if m.discovery != nil {
+				m.discovery.announce(p)
+			}
+
+			// value
+			topic := fmt.Sprintf("%s/site", m.root)
+			if p.LoadPoint != nil {
+				id := *p.LoadPoint + 1
+				topic = fmt.Sprintf("%s/loadpoints/%d", m.root, id)
+			}
+
+			m.publishParam(topic+"/"+p.Key, p)
 
-		// value
-		topic += "/" + p.Key
-		m.publish(topic, false, p.Val)
+			// debounce state publishing on change
+			if m.stateEnabled() && stateChanged == nil && m.stateChanged(p) {
+				stateChanged = time.After(stateDebounce)
+			}
+
+		case <-stateChanged:
+			stateChanged = nil
+			m.publishState()
+
+		case <-stateTicker:
+			m.publishState()
+		}
 	}
 }
@@ -0,0 +1,405 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/core"
+	"github.com/andig/evcc/util"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// discoveryCleanupDelay is the time after which retained discovery configs
+// that have not been re-announced are considered stale and removed
+const discoveryCleanupDelay = time.Minute
+
+// haEntity describes how a published value is represented in Home Assistant
+type haEntity struct {
+	Name          string
+	Component     string // sensor (default), binary_sensor, select or number
+	DeviceClass   string
+	StateClass    string
+	Unit          string
+	ValueTemplate string
+	Vehicle       bool // value belongs to the loadpoint's active vehicle
+}
+
+// haEntities maps known parameter keys to their Home Assistant representation.
+// Keys that are not listed are announced as plain sensors.
+var haEntities = map[string]haEntity{
+	// site
+	"gridPower":                   {DeviceClass: "power", StateClass: "measurement", Unit: "W"},
+	"pvPower":                     {Name: "PV Power", DeviceClass: "power", StateClass: "measurement", Unit: "W"},
+	"homePower":                   {DeviceClass: "power", StateClass: "measurement", Unit: "W"},
+	"residualPower":               {DeviceClass: "power", StateClass: "measurement", Unit: "W"},
+	"baseloadPower":               {DeviceClass: "power", StateClass: "measurement", Unit: "W"},
+	"baseloadStdDev":              {DeviceClass: "power", StateClass: "measurement", Unit: "W"},
+	"batteryPower":                {DeviceClass: "power", StateClass: "measurement", Unit: "W"},
+	"gridEnergy":                  {DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"},
+	"pvEnergy":                    {Name: "PV Energy", DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"},
+	"batteryEnergy":               {DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"},
+	"pvEnergyToday":               {Name: "PV Energy Today", DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"},
+	"gridImportEnergyToday":       {DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"},
+	"gridExportEnergyToday":       {DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"},
+	"batteryChargeEnergyToday":    {DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"},
+	"batteryDischargeEnergyToday": {DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"},
+	"homeEnergyToday":             {DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"},
+	"selfConsumptionToday":        {StateClass: "measurement", Unit: "%"},
+	"autarkyToday":                {StateClass: "measurement", Unit: "%"},
+	"batterySoC":                  {Name: "Battery SoC", DeviceClass: "battery", StateClass: "measurement", Unit: "%"},
+	"gridCurrents":                {DeviceClass: "current", StateClass: "measurement", Unit: "A"},
+	"prioritySoC":                 {Name: "Priority SoC", Component: "number", Unit: "%"},
+	"gridConfigured":              {Component: "binary_sensor"},
+	"pvConfigured":                {Name: "PV Configured", Component: "binary_sensor"},
+	"batteryConfigured":           {Component: "binary_sensor"},
+
+	// loadpoint
+	"mode":                 {Component: "select"},
+	"targetSoC":            {Name: "Target SoC", Component: "number", Unit: "%"},
+	"minSoC":               {Name: "Min SoC", Component: "number", Unit: "%"},
+	"minCurrent":           {DeviceClass: "current", Unit: "A"},
+	"maxCurrent":           {DeviceClass: "current", Unit: "A"},
+	"chargeCurrent":        {DeviceClass: "current", StateClass: "measurement", Unit: "A"},
+	"chargeCurrents":       {DeviceClass: "current", StateClass: "measurement", Unit: "A"},
+	"chargePower":          {DeviceClass: "power", StateClass: "measurement", Unit: "W"},
+	"chargedEnergy":        {DeviceClass: "energy", StateClass: "total_increasing", Unit: "Wh"},
+	"chargeTotalEnergy":    {DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"},
+	"chargeEnergyToday":    {DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"},
+	"chargeDuration":       {DeviceClass: "duration", Unit: "s"},
+	"connectedDuration":    {DeviceClass: "duration", Unit: "s"},
+	"connected":            {Component: "binary_sensor", DeviceClass: "plug"},
+	"charging":             {Component: "binary_sensor", DeviceClass: "battery_charging"},
+	"enabled":              {Component: "binary_sensor"},
+	"hasVehicle":           {Component: "binary_sensor"},
+	"chargeConfigured":     {Component: "binary_sensor"},
+	"targetTime":           {DeviceClass: "timestamp", ValueTemplate: "{{ as_datetime(value | int) }}"},
+	"remoteDisabled":       {},
+	"remoteDisabledSource": {},
+	"readErrors":           {StateClass: "total_increasing"},
+
+	// vehicle
+	"socTitle":              {Name: "Vehicle", Vehicle: true},
+	"socCapacity":           {Name: "Capacity", Unit: "kWh", Vehicle: true},
+	"socCharge":             {Name: "SoC", DeviceClass: "battery", StateClass: "measurement", Unit: "%", Vehicle: true},
+	"chargeEstimate":        {DeviceClass: "duration", Unit: "s", Vehicle: true},
+	"chargeRemainingEnergy": {DeviceClass: "energy", Unit: "Wh", Vehicle: true},
+	"range":                 {DeviceClass: "distance", Unit: "km", Vehicle: true},
+	"climater":              {Vehicle: true},
+}
+
+// haIgnore lists keys that are not announced
+var haIgnore = []string{"title", "siteTitle", "warn", "error", "fatal", "sponsor", "availableVersion", "releaseNotes"}
+
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+	ViaDevice    string   `json:"via_device,omitempty"`
+}
+
+type haConfig struct {
+	Name              string   `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	StateTopic        string   `json:"state_topic"`
+	CommandTopic      string   `json:"command_topic,omitempty"`
+	AvailabilityTopic string   `json:"availability_topic"`
+	DeviceClass       string   `json:"device_class,omitempty"`
+	StateClass        string   `json:"state_class,omitempty"`
+	Unit              string   `json:"unit_of_measurement,omitempty"`
+	ValueTemplate     string   `json:"value_template,omitempty"`
+	PayloadOn         string   `json:"payload_on,omitempty"`
+	PayloadOff        string   `json:"payload_off,omitempty"`
+	Options           []string `json:"options,omitempty"`
+	Min               *float64 `json:"min,omitempty"`
+	Max               *float64 `json:"max,omitempty"`
+	Step              *float64 `json:"step,omitempty"`
+	Device            haDevice `json:"device"`
+}
+
+// discovery publishes Home Assistant MQTT discovery configurations
+type discovery struct {
+	mux        sync.Mutex
+	mqtt       *MQTT
+	prefix     string
+	node       string
+	site       string
+	loadpoints []core.LoadPointAPI
+	vehicles   map[int]string
+	seen       map[string]util.Param
+	published  map[string]string
+	retained   map[string]struct{}
+}
+
+var haNodeRE = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+func newDiscovery(m *MQTT, prefix string) *discovery {
+	return &discovery{
+		mqtt:      m,
+		prefix:    prefix,
+		node:      haNodeRE.ReplaceAllString(m.root, "_"),
+		site:      "evcc",
+		vehicles:  make(map[int]string),
+		seen:      make(map[string]util.Param),
+		published: make(map[string]string),
+		retained:  make(map[string]struct{}),
+	}
+}
+
+// collect subscribes to existing discovery configs and schedules removal of stale ones
+func (d *discovery) collect() {
+	topic := fmt.Sprintf("%s/+/%s/+/config", d.prefix, d.node)
+
+	token := d.mqtt.Handler.Client.Subscribe(topic, d.mqtt.Handler.Qos, func(c paho.Client, msg paho.Message) {
+		if len(msg.Payload()) > 0 {
+			d.mux.Lock()
+			d.retained[msg.Topic()] = struct{}{}
+			d.mux.Unlock()
+		}
+	})
+	d.mqtt.Handler.WaitForToken(token)
+
+	time.AfterFunc(discoveryCleanupDelay, func() {
+		d.mqtt.Handler.WaitForToken(d.mqtt.Handler.Client.Unsubscribe(topic))
+		d.cleanup()
+	})
+}
+
+// cleanup removes retained configs that have not been announced by the current configuration
+func (d *discovery) cleanup() {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	for topic := range d.retained {
+		if _, ok := d.published[topic]; !ok {
+			log.DEBUG.Printf("remove stale discovery config: %s", topic)
+			d.mqtt.publishSingleValue(topic, true, "")
+		}
+	}
+
+	d.retained = make(map[string]struct{})
+}
+
+// title converts a camel case key into a human-readable name
+func (d *discovery) title(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		if i == 0 {
+			r = unicode.ToUpper(r)
+		} else if unicode.IsUpper(r) {
+			b.WriteRune(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (d *discovery) siteDevice() haDevice {
+	return haDevice{
+		Identifiers:  []string{d.node},
+		Name:         d.site,
+		Manufacturer: "evcc",
+		Model:        "Site",
+		SWVersion:    Version,
+	}
+}
+
+func (d *discovery) loadpointDevice(id int) haDevice {
+	name := fmt.Sprintf("Loadpoint %d", id+1)
+	if id < len(d.loadpoints) && d.loadpoints[id].Name() != "" {
+		name = d.loadpoints[id].Name()
+	}
+
+	return haDevice{
+		Identifiers:  []string{fmt.Sprintf("%s_lp%d", d.node, id+1)},
+		Name:         name,
+		Manufacturer: "evcc",
+		Model:        "Loadpoint",
+		SWVersion:    Version,
+		ViaDevice:    d.node,
+	}
+}
+
+func (d *discovery) vehicleDevice(title string) haDevice {
+	return haDevice{
+		Identifiers:  []string{fmt.Sprintf("%s_vehicle_%s", d.node, strings.ToLower(haNodeRE.ReplaceAllString(title, "_")))},
+		Name:         title,
+		Manufacturer: "evcc",
+		Model:        "Vehicle",
+		ViaDevice:    d.node,
+	}
+}
+
+// haValueRE matches the value variable of Home Assistant value templates
+var haValueRE = regexp.MustCompile(`\bvalue\b`)
+
+// envelopeTemplate returns the entity's value template for values published as json envelope
+func envelopeTemplate(entity haEntity) string {
+	switch {
+	case entity.ValueTemplate != "":
+		return haValueRE.ReplaceAllString(entity.ValueTemplate, "value_json.value")
+	case entity.Component == "binary_sensor":
+		// json booleans render as True/False
+		return "{{ value_json.value | lower }}"
+	default:
+		return "{{ value_json.value }}"
+	}
+}
+
+// configs creates the discovery configs for the given parameter indexed by config topic
+func (d *discovery) configs(p util.Param) map[string]haConfig {
+	for _, key := range haIgnore {
+		if p.Key == key {
+			return nil
+		}
+	}
+
+	entity, ok := haEntities[p.Key]
+	if !ok {
+		if _, isBool := p.Val.(bool); isBool {
+			entity.Component = "binary_sensor"
+		}
+	}
+	if entity.Component == "" {
+		entity.Component = "sensor"
+	}
+	if entity.Name == "" {
+		entity.Name = d.title(p.Key)
+	}
+	if d.mqtt.conf.Envelope {
+		entity.ValueTemplate = envelopeTemplate(entity)
+	}
+
+	stateTopic := fmt.Sprintf("%s/site", d.mqtt.root)
+	objectID := "site"
+	device := d.siteDevice()
+
+	if p.LoadPoint != nil {
+		id := *p.LoadPoint
+		stateTopic = fmt.Sprintf("%s/loadpoints/%d", d.mqtt.root, id+1)
+		objectID = fmt.Sprintf("lp%d", id+1)
+		device = d.loadpointDevice(id)
+
+		if title := d.vehicles[id]; entity.Vehicle && title != "" {
+			device = d.vehicleDevice(title)
+		}
+	}
+
+	stateTopic += "/" + p.Key
+	objectID = fmt.Sprintf("%s_%s_%s", d.node, objectID, strings.ToLower(p.Key))
+
+	conf := haConfig{
+		Name:              fmt.Sprintf("%s %s", device.Name, entity.Name),
+		UniqueID:          objectID,
+		StateTopic:        stateTopic,
+		AvailabilityTopic: fmt.Sprintf("%s/status", d.mqtt.root),
+		DeviceClass:       entity.DeviceClass,
+		StateClass:        entity.StateClass,
+		Unit:              entity.Unit,
+		ValueTemplate:     entity.ValueTemplate,
+		Device:            device,
+	}
+
+	switch entity.Component {
+	case "binary_sensor":
+		conf.PayloadOn = "true"
+		conf.PayloadOff = "false"
+	case "select":
+		conf.CommandTopic = stateTopic + "/set"
+		for _, mode := range []api.ChargeMode{api.ModeOff, api.ModeNow, api.ModeMinPV, api.ModePV} {
+			conf.Options = append(conf.Options, string(mode))
+		}
+	case "number":
+		min, max, step := 0.0, 100.0, 1.0
+		conf.CommandTopic = stateTopic + "/set"
+		conf.Min, conf.Max, conf.Step = &min, &max, &step
+	}
+
+	topic := func(objectID string) string {
+		return fmt.Sprintf("%s/%s/%s/%s/config", d.prefix, entity.Component, d.node, objectID)
+	}
+
+	res := map[string]haConfig{
+		topic(objectID): conf,
+	}
+
+	// phase values
+	if slice, ok := p.Val.([]float64); ok && len(slice) == 3 {
+		for i := 1; i <= 3; i++ {
+			phase := conf
+			phase.Name = fmt.Sprintf("%s L%d", conf.Name, i)
+			phase.UniqueID = fmt.Sprintf("%s_l%d", conf.UniqueID, i)
+			phase.StateTopic = fmt.Sprintf("%s/l%d", conf.StateTopic, i)
+			res[topic(phase.UniqueID)] = phase
+		}
+	}
+
+	return res
+}
+
+// announce publishes discovery configs for the given parameter if required
+func (d *discovery) announce(p util.Param) {
+	if p.Val == nil {
+		return
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	// device names
+	switch {
+	case p.Key == "siteTitle" && p.LoadPoint == nil:
+		if title, ok := p.Val.(string); ok && title != "" && title != d.site {
+			d.site = title
+			d.reannounce(func(p util.Param) bool { return p.LoadPoint == nil })
+		}
+
+	case p.Key == "socTitle" && p.LoadPoint != nil:
+		id := *p.LoadPoint
+		if title, ok := p.Val.(string); ok && title != d.vehicles[id] {
+			d.vehicles[id] = title
+			d.reannounce(func(p util.Param) bool {
+				return p.LoadPoint != nil && *p.LoadPoint == id && haEntities[p.Key].Vehicle
+			})
+		}
+	}
+
+	if _, ok := d.seen[p.UniqueID()]; ok {
+		return
+	}
+
+	d.seen[p.UniqueID()] = p
+	d.publish(p)
+}
+
+// reannounce publishes discovery configs for all previously seen parameters matching the filter
+func (d *discovery) reannounce(filter func(util.Param) bool) {
+	for _, p := range d.seen {
+		if filter(p) {
+			d.publish(p)
+		}
+	}
+}
+
+// publish publishes changed discovery configs for the given parameter
+func (d *discovery) publish(p util.Param) {
+	for topic, conf := range d.configs(p) {
+		b, err := json.Marshal(conf)
+		if err != nil {
+			log.ERROR.Printf("discovery: %v", err)
+			continue
+		}
+
+		if payload := string(b); d.published[topic] != payload {
+			d.published[topic] = payload
+			d.mqtt.publishSingleValue(topic, true, payload)
+		}
+	}
+}
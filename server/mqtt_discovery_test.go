@@ -0,0 +1,146 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/andig/evcc/util"
+)
+
+func TestDiscoveryConfigs(t *testing.T) {
+	d := newDiscovery(&MQTT{root: "evcc"}, "homeassistant")
+
+	lp := 0
+	d.vehicles[lp] = "My Car"
+
+	tc := []struct {
+		param       util.Param
+		topic       string
+		state       string
+		command     string
+		deviceClass string
+		device      string
+		configs     int
+	}{
+		{
+			util.Param{Key: "gridPower", Val: 1.0},
+			"homeassistant/sensor/evcc/evcc_site_gridpower/config",
+			"evcc/site/gridPower", "", "power", "evcc", 1,
+		},
+		{
+			util.Param{Key: "gridCurrents", Val: []float64{1, 2, 3}},
+			"homeassistant/sensor/evcc/evcc_site_gridcurrents/config",
+			"evcc/site/gridCurrents", "", "current", "evcc", 4,
+		},
+		{
+			util.Param{LoadPoint: &lp, Key: "mode", Val: "pv"},
+			"homeassistant/select/evcc/evcc_lp1_mode/config",
+			"evcc/loadpoints/1/mode", "evcc/loadpoints/1/mode/set", "", "evcc_lp1", 1,
+		},
+		{
+			util.Param{LoadPoint: &lp, Key: "charging", Val: true},
+			"homeassistant/binary_sensor/evcc/evcc_lp1_charging/config",
+			"evcc/loadpoints/1/charging", "", "battery_charging", "evcc_lp1", 1,
+		},
+		{
+			util.Param{LoadPoint: &lp, Key: "socCharge", Val: 50.0},
+			"homeassistant/sensor/evcc/evcc_lp1_soccharge/config",
+			"evcc/loadpoints/1/socCharge", "", "battery", "evcc_vehicle_my_car", 1,
+		},
+		{
+			util.Param{LoadPoint: &lp, Key: "unknownFlag", Val: false},
+			"homeassistant/binary_sensor/evcc/evcc_lp1_unknownflag/config",
+			"evcc/loadpoints/1/unknownFlag", "", "", "evcc_lp1", 1,
+		},
+	}
+
+	for _, tc := range tc {
+		t.Logf("%+v", tc.param)
+
+		res := d.configs(tc.param)
+		if len(res) != tc.configs {
+			t.Errorf("expected %d configs, got %d", tc.configs, len(res))
+		}
+
+		conf, ok := res[tc.topic]
+		if !ok {
+			t.Errorf("missing config topic %s", tc.topic)
+			continue
+		}
+
+		if conf.StateTopic != tc.state {
+			t.Errorf("expected state topic %s, got %s", tc.state, conf.StateTopic)
+		}
+		if conf.CommandTopic != tc.command {
+			t.Errorf("expected command topic %s, got %s", tc.command, conf.CommandTopic)
+		}
+		if conf.DeviceClass != tc.deviceClass {
+			t.Errorf("expected device class %s, got %s", tc.deviceClass, conf.DeviceClass)
+		}
+		if conf.Device.Identifiers[0] != tc.device {
+			t.Errorf("expected device %s, got %s", tc.device, conf.Device.Identifiers[0])
+		}
+	}
+
+	// ignored keys
+	if res := d.configs(util.Param{Key: "siteTitle", Val: "Home"}); len(res) != 0 {
+		t.Errorf("unexpected configs for ignored key: %v", res)
+	}
+}
+
+func TestDiscoveryEnvelope(t *testing.T) {
+	lp := 0
+
+	tc := []struct {
+		param            util.Param
+		topic            string
+		plain, enveloped string
+	}{
+		{
+			util.Param{Key: "gridPower", Val: 1.0},
+			"homeassistant/sensor/evcc/evcc_site_gridpower/config",
+			"", "{{ value_json.value }}",
+		},
+		{
+			util.Param{LoadPoint: &lp, Key: "charging", Val: true},
+			"homeassistant/binary_sensor/evcc/evcc_lp1_charging/config",
+			"", "{{ value_json.value | lower }}",
+		},
+		{
+			util.Param{LoadPoint: &lp, Key: "mode", Val: "pv"},
+			"homeassistant/select/evcc/evcc_lp1_mode/config",
+			"", "{{ value_json.value }}",
+		},
+		{
+			util.Param{LoadPoint: &lp, Key: "targetTime", Val: 0},
+			"homeassistant/sensor/evcc/evcc_lp1_targettime/config",
+			"{{ as_datetime(value | int) }}", "{{ as_datetime(value_json.value | int) }}",
+		},
+	}
+
+	for _, envelope := range []bool{false, true} {
+		m := (&MQTT{root: "evcc"}).WithConfig(MQTTConfig{Envelope: envelope}, nil)
+		d := newDiscovery(m, "homeassistant")
+
+		for _, tc := range tc {
+			conf, ok := d.configs(tc.param)[tc.topic]
+			if !ok {
+				t.Errorf("missing config topic %s", tc.topic)
+				continue
+			}
+
+			expected := tc.plain
+			if envelope {
+				expected = tc.enveloped
+			}
+
+			if conf.ValueTemplate != expected {
+				t.Errorf("%s (envelope %v): expected value template %q, got %q", tc.param.Key, envelope, expected, conf.ValueTemplate)
+			}
+
+			// binary sensor states are matched after applying the template
+			if conf.PayloadOn != "" && conf.PayloadOn != "true" {
+				t.Errorf("%s: unexpected payload on %q", tc.param.Key, conf.PayloadOn)
+			}
+		}
+	}
+}
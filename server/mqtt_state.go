@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/andig/evcc/util"
+)
+
+// stateDebounce is the delay for publishing the aggregated state after values have changed
+const stateDebounce = time.Second
+
+// stateExclude lists keys that are not published as part of the aggregated state
+var stateExclude = []string{"releaseNotes", "warn", "error", "fatal"}
+
+func (m *MQTT) stateEnabled() bool {
+	return m.cache != nil && m.conf.State.Topic != ""
+}
+
+// stateChanged checks if the parameter value differs from the previously received one
+func (m *MQTT) stateChanged(p util.Param) bool {
+	if m.state == nil {
+		m.state = make(map[string]interface{})
+	}
+
+	key := p.UniqueID()
+	if val, ok := m.state[key]; ok && reflect.DeepEqual(val, p.Val) {
+		return false
+	}
+
+	m.state[key] = p.Val
+	return true
+}
+
+// publishState publishes the aggregated state as retained json document
+func (m *MQTT) publishState() {
+	res := m.cache.State()
+	for _, k := range stateExclude {
+		delete(res, k)
+	}
+
+	// convert values to api representation
+	for k, v := range res {
+		res[k] = jsonValue(v)
+	}
+	if lps, ok := res["loadpoints"].([]map[string]interface{}); ok {
+		for _, lp := range lps {
+			for k, v := range lp {
+				lp[k] = jsonValue(v)
+			}
+		}
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		log.ERROR.Printf("mqtt: state: %v", err)
+		return
+	}
+
+	topic := fmt.Sprintf("%s/%s", m.root, m.conf.State.Topic)
+	m.publishSingleValue(topic, true, string(b))
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/andig/evcc/provider/mqtt"
+	"github.com/andig/evcc/util"
+)
+
+func TestMqttTopicConfig(t *testing.T) {
+	qos := byte(2)
+	retain, noRetain := true, false
+
+	m := (&MQTT{Handler: &mqtt.Client{Qos: 1}}).WithConfig(MQTTConfig{
+		Retain: true,
+		Topics: map[string]MQTTTopicConfig{
+			"gridPower": {Qos: &qos},
+			"mode":      {Retain: &retain},
+			"pvPower":   {Retain: &noRetain},
+		},
+	}, nil)
+
+	tc := []struct {
+		key    string
+		retain bool
+		qos    byte
+	}{
+		{"homePower", true, 1},
+		{"gridPower", true, 2}, // global retain applies if not set
+		{"mode", true, 1},
+		{"pvPower", false, 1},
+	}
+
+	for _, tc := range tc {
+		retain, qos := m.topicConfig(tc.key)
+		if retain != tc.retain || qos != tc.qos {
+			t.Errorf("%s: expected retain %v qos %d, got %v %d", tc.key, tc.retain, tc.qos, retain, qos)
+		}
+	}
+}
+
+func TestMqttEnvelope(t *testing.T) {
+	m := &MQTT{}
+
+	var res mqttEnvelope
+	if err := json.Unmarshal([]byte(m.envelope("gridPower", 1.5)), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Value != 1.5 || res.Unit != "W" || res.Timestamp == 0 {
+		t.Errorf("unexpected envelope: %+v", res)
+	}
+
+	if err := json.Unmarshal([]byte(m.envelope("chargeDuration", time.Hour)), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Value != float64(3600) || res.Unit != "s" {
+		t.Errorf("unexpected envelope: %+v", res)
+	}
+}
+
+func TestMqttStateChanged(t *testing.T) {
+	m := &MQTT{}
+	lp := 0
+
+	tc := []struct {
+		p       util.Param
+		changed bool
+	}{
+		{util.Param{Key: "gridPower", Val: 1.0}, true},
+		{util.Param{Key: "gridPower", Val: 1.0}, false},
+		{util.Param{LoadPoint: &lp, Key: "gridPower", Val: 1.0}, true},
+		{util.Param{Key: "gridCurrents", Val: []float64{1, 2, 3}}, true},
+		{util.Param{Key: "gridCurrents", Val: []float64{1, 2, 3}}, false},
+		{util.Param{Key: "gridCurrents", Val: []float64{1, 2, 4}}, true},
+	}
+
+	for _, tc := range tc {
+		if changed := m.stateChanged(tc.p); changed != tc.changed {
+			t.Errorf("%s: expected changed %v, got %v", tc.p.UniqueID(), tc.changed, changed)
+		}
+	}
+}
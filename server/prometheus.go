@@ -0,0 +1,303 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/core"
+	"github.com/andig/evcc/util"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const promNamespace = "evcc"
+
+var (
+	promPhases   = []string{"l1", "l2", "l3"}
+	promStatuses = []api.ChargeStatus{api.StatusA, api.StatusB, api.StatusC}
+	promModes    = []api.ChargeMode{api.ModeOff, api.ModeNow, api.ModeMinPV, api.ModePV}
+)
+
+// Prometheus exposes site and loadpoint values as prometheus metrics
+type Prometheus struct {
+	loadpoints []core.LoadPointAPI
+
+	// loadpoint state
+	vehicles   map[int]string
+	connected  map[int]bool
+	charging   map[int]bool
+	enabled    map[int]bool
+	energy     map[int]float64
+	readErrors map[string]int64
+
+	sitePower       *prometheus.GaugeVec
+	siteCurrent     *prometheus.GaugeVec
+	batterySoC      prometheus.Gauge
+	chargePower     *prometheus.GaugeVec
+	chargeCurrent   *prometheus.GaugeVec
+	vehicleSoC      *prometheus.GaugeVec
+	status          *prometheus.GaugeVec
+	mode            *prometheus.GaugeVec
+	chargedEnergy   *prometheus.CounterVec
+	enableCycles    *prometheus.CounterVec
+	readErrorsTotal *prometheus.CounterVec
+}
+
+// NewPrometheus creates prometheus collector and registers its metrics with the default registry
+func NewPrometheus(loadpoints []core.LoadPointAPI) *Prometheus {
+	lpLabels := []string{"loadpoint", "vehicle"}
+
+	p := &Prometheus{
+		loadpoints: loadpoints,
+		vehicles:   make(map[int]string),
+		connected:  make(map[int]bool),
+		charging:   make(map[int]bool),
+		enabled:    make(map[int]bool),
+		energy:     make(map[int]float64),
+		readErrors: make(map[string]int64),
+
+		sitePower: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "site_power_watts",
+			Help:      "Site power by meter. Grid: positive values are import, battery: positive values are discharge, home: household consumption excluding loadpoints.",
+		}, []string{"meter"}),
+		siteCurrent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "site_grid_current_amperes",
+			Help:      "Grid current by phase.",
+		}, []string{"phase"}),
+		batterySoC: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "site_battery_soc_percent",
+			Help:      "Home battery state of charge.",
+		}),
+		chargePower: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "loadpoint_charge_power_watts",
+			Help:      "Loadpoint charge power.",
+		}, lpLabels),
+		chargeCurrent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "loadpoint_charge_current_amperes",
+			Help:      "Loadpoint charge current by phase.",
+		}, append(lpLabels, "phase")),
+		vehicleSoC: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "vehicle_soc_percent",
+			Help:      "Vehicle state of charge.",
+		}, lpLabels),
+		status: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "loadpoint_status",
+			Help:      "Loadpoint charge status (A: disconnected, B: connected, C: charging). The active status has value 1.",
+		}, append(lpLabels, "status")),
+		mode: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "loadpoint_mode",
+			Help:      "Loadpoint charge mode. The active mode has value 1.",
+		}, append(lpLabels, "mode")),
+		chargedEnergy: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "loadpoint_charged_energy_wh_total",
+			Help:      "Loadpoint charged energy.",
+		}, lpLabels),
+		enableCycles: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "loadpoint_charger_enable_total",
+			Help:      "Number of times the charger has been enabled.",
+		}, lpLabels),
+		readErrorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "read_errors_total",
+			Help:      "Device read errors. Site meter errors have an empty loadpoint label.",
+		}, lpLabels),
+	}
+
+	prometheus.MustRegister(
+		p.sitePower, p.siteCurrent, p.batterySoC,
+		p.chargePower, p.chargeCurrent, p.vehicleSoC, p.status, p.mode,
+		p.chargedEnergy, p.enableCycles, p.readErrorsTotal,
+	)
+
+	return p
+}
+
+// labels returns the loadpoint and vehicle labels
+func (p *Prometheus) labels(id int) prometheus.Labels {
+	name := fmt.Sprintf("%d", id+1)
+	if id < len(p.loadpoints) && p.loadpoints[id].Name() != "" {
+		name = p.loadpoints[id].Name()
+	}
+
+	return prometheus.Labels{"loadpoint": name, "vehicle": p.vehicles[id]}
+}
+
+// withLabel returns a copy of the labels with additional label added
+func withLabel(labels prometheus.Labels, name, value string) prometheus.Labels {
+	res := prometheus.Labels{name: value}
+	for k, v := range labels {
+		res[k] = v
+	}
+	return res
+}
+
+// deleteVehicleGauges removes gauges of the previously active vehicle
+func (p *Prometheus) deleteVehicleGauges(labels prometheus.Labels) {
+	p.chargePower.Delete(labels)
+	p.vehicleSoC.Delete(labels)
+
+	for _, phase := range promPhases {
+		p.chargeCurrent.Delete(withLabel(labels, "phase", phase))
+	}
+	for _, status := range promStatuses {
+		p.status.Delete(withLabel(labels, "status", string(status)))
+	}
+	for _, mode := range promModes {
+		p.mode.Delete(withLabel(labels, "mode", string(mode)))
+	}
+}
+
+// updateStatus updates the derived loadpoint status
+func (p *Prometheus) updateStatus(id int) {
+	current := api.StatusA
+	if p.charging[id] {
+		current = api.StatusC
+	} else if p.connected[id] {
+		current = api.StatusB
+	}
+
+	labels := p.labels(id)
+	for _, status := range promStatuses {
+		var val float64
+		if status == current {
+			val = 1
+		}
+		p.status.With(withLabel(labels, "status", string(status))).Set(val)
+	}
+}
+
+// updateReadErrors adds the increase of the published read error counter
+func (p *Prometheus) updateReadErrors(param util.Param, labels prometheus.Labels) {
+	val, ok := param.Val.(int64)
+	if !ok {
+		return
+	}
+
+	key := param.UniqueID()
+	if delta := val - p.readErrors[key]; delta > 0 {
+		p.readErrorsTotal.With(labels).Add(float64(delta))
+	}
+	p.readErrors[key] = val
+}
+
+func (p *Prometheus) siteParam(param util.Param) {
+	switch param.Key {
+	case "gridPower", "pvPower", "batteryPower", "homePower":
+		if val, ok := param.Val.(float64); ok {
+			meter := param.Key[:len(param.Key)-len("Power")]
+			p.sitePower.WithLabelValues(meter).Set(val)
+		}
+
+	case "gridCurrents":
+		if val, ok := param.Val.([]float64); ok && len(val) == len(promPhases) {
+			for i, phase := range promPhases {
+				p.siteCurrent.WithLabelValues(phase).Set(val[i])
+			}
+		}
+
+	case "batterySoC":
+		if val, ok := param.Val.(float64); ok {
+			p.batterySoC.Set(val)
+		}
+
+	case "readErrors":
+		p.updateReadErrors(param, prometheus.Labels{"loadpoint": "", "vehicle": ""})
+	}
+}
+
+func (p *Prometheus) loadpointParam(id int, param util.Param) {
+	labels := p.labels(id)
+
+	switch param.Key {
+	case "socTitle":
+		if val, ok := param.Val.(string); ok && val != p.vehicles[id] {
+			p.deleteVehicleGauges(labels)
+			p.vehicles[id] = val
+		}
+
+	case "chargePower":
+		if val, ok := param.Val.(float64); ok {
+			p.chargePower.With(labels).Set(val)
+		}
+
+	case "chargeCurrents":
+		if val, ok := param.Val.([]float64); ok && len(val) == len(promPhases) {
+			for i, phase := range promPhases {
+				p.chargeCurrent.With(withLabel(labels, "phase", phase)).Set(val[i])
+			}
+		}
+
+	case "socCharge":
+		if val, ok := param.Val.(float64); ok && val >= 0 {
+			p.vehicleSoC.With(labels).Set(val)
+		}
+
+	case "connected":
+		if val, ok := param.Val.(bool); ok {
+			p.connected[id] = val
+			p.updateStatus(id)
+		}
+
+	case "charging":
+		if val, ok := param.Val.(bool); ok {
+			p.charging[id] = val
+			p.updateStatus(id)
+		}
+
+	case "mode":
+		if val, ok := param.Val.(api.ChargeMode); ok {
+			for _, mode := range promModes {
+				var active float64
+				if mode == val {
+					active = 1
+				}
+				p.mode.With(withLabel(labels, "mode", string(mode))).Set(active)
+			}
+		}
+
+	case "enabled":
+		if val, ok := param.Val.(bool); ok {
+			// don't count initial state
+			if enabled, ok := p.enabled[id]; ok && val && !enabled {
+				p.enableCycles.With(labels).Inc()
+			}
+			p.enabled[id] = val
+		}
+
+	case "chargedEnergy":
+		if val, ok := param.Val.(float64); ok {
+			// charged energy is reset when vehicle connects
+			delta := val - p.energy[id]
+			if delta < 0 {
+				delta = val
+			}
+			if delta > 0 {
+				p.chargedEnergy.With(labels).Add(delta)
+			}
+			p.energy[id] = val
+		}
+
+	case "readErrors":
+		p.updateReadErrors(param, labels)
+	}
+}
+
+// Run updates metrics from the param stream
+func (p *Prometheus) Run(in <-chan util.Param) {
+	for param := range in {
+		if param.LoadPoint == nil {
+			p.siteParam(param)
+		} else {
+			p.loadpointParam(*param.LoadPoint, param)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/core"
+	"github.com/andig/evcc/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPrometheus(t *testing.T) {
+	p := NewPrometheus([]core.LoadPointAPI{})
+	defer func() {
+		for _, c := range []prometheus.Collector{
+			p.sitePower, p.siteCurrent, p.batterySoC,
+			p.chargePower, p.chargeCurrent, p.vehicleSoC, p.status, p.mode,
+			p.chargedEnergy, p.enableCycles, p.readErrorsTotal,
+		} {
+			prometheus.Unregister(c)
+		}
+	}()
+
+	lp := 0
+	labels := prometheus.Labels{"loadpoint": "1", "vehicle": "My Car"}
+
+	for _, param := range []util.Param{
+		{Key: "gridPower", Val: 1000.0},
+		{Key: "readErrors", Val: int64(2)},
+		{LoadPoint: &lp, Key: "socTitle", Val: "My Car"},
+		{LoadPoint: &lp, Key: "enabled", Val: true},
+		{LoadPoint: &lp, Key: "enabled", Val: false},
+		{LoadPoint: &lp, Key: "enabled", Val: true},
+		{LoadPoint: &lp, Key: "connected", Val: true},
+		{LoadPoint: &lp, Key: "charging", Val: true},
+		{LoadPoint: &lp, Key: "mode", Val: api.ModePV},
+		{LoadPoint: &lp, Key: "chargedEnergy", Val: 500.0},
+		{LoadPoint: &lp, Key: "chargedEnergy", Val: 800.0},
+		{LoadPoint: &lp, Key: "chargedEnergy", Val: 100.0}, // reset
+		{LoadPoint: &lp, Key: "readErrors", Val: int64(1)},
+		{LoadPoint: &lp, Key: "readErrors", Val: int64(3)},
+	} {
+		if param.LoadPoint == nil {
+			p.siteParam(param)
+		} else {
+			p.loadpointParam(*param.LoadPoint, param)
+		}
+	}
+
+	tc := []struct {
+		name     string
+		c        prometheus.Collector
+		expected float64
+	}{
+		{"grid power", p.sitePower.WithLabelValues("grid"), 1000},
+		{"site read errors", p.readErrorsTotal.With(prometheus.Labels{"loadpoint": "", "vehicle": ""}), 2},
+		{"enable cycles", p.enableCycles.With(labels), 1},
+		{"charged energy", p.chargedEnergy.With(labels), 900},
+		{"status C", p.status.With(withLabel(labels, "status", "C")), 1},
+		{"status B", p.status.With(withLabel(labels, "status", "B")), 0},
+		{"mode pv", p.mode.With(withLabel(labels, "mode", "pv")), 1},
+		{"mode now", p.mode.With(withLabel(labels, "mode", "now")), 0},
+		{"loadpoint read errors", p.readErrorsTotal.With(labels), 3},
+	}
+
+	for _, tc := range tc {
+		if res := testutil.ToFloat64(tc.c); res != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, res)
+		}
+	}
+}
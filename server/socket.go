@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/andig/evcc/core"
 	"github.com/andig/evcc/util"
 	"github.com/gorilla/websocket"
 )
@@ -14,12 +15,16 @@ import (
 const (
 	// Time allowed to write a message to the peer
 	socketWriteTimeout = 10 * time.Second
+
+	// Maximum size of client messages
+	socketMaxMessageSize = 4096
 )
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin:     func(r *http.Request) bool { return true },
+	CheckOrigin:     sameOrigin, // prevent cross-site websocket hijacking
+	Subprotocols:    []string{socketProtocolV1},
 }
 
 // SocketClient is a middleman between the websocket connection and the hub.
@@ -31,6 +36,43 @@ type SocketClient struct {
 
 	// Buffered channel of outbound messages.
 	send chan []byte
+
+	// Negotiated subprotocol, empty for plain value stream
+	protocol string
+
+	// Role and loadpoints for executing commands
+	role       Role
+	loadpoints []core.LoadPointAPI
+
+	// Subscribed values, nil for all
+	filter *socketFilter
+}
+
+// readPump pumps messages from the websocket connection to the hub.
+// Clients using the plain value stream are not expected to send messages.
+func (c *SocketClient) readPump() {
+	defer func() {
+		c.hub.unregister <- c
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(socketMaxMessageSize)
+
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if c.protocol != socketProtocolV1 {
+			continue
+		}
+
+		res := c.handle(msg)
+		if b, err := json.Marshal(res); err == nil {
+			c.hub.reply <- reply{client: c, msg: b}
+		}
+	}
 }
 
 // writePump pumps messages from the hub to the websocket connection.
@@ -51,17 +93,37 @@ func (c *SocketClient) writePump() {
 }
 
 // ServeWebsocket handles websocket requests from the peer.
-func ServeWebsocket(hub *SocketHub, w http.ResponseWriter, r *http.Request) {
+func ServeWebsocket(hub *SocketHub, site core.SiteAPI, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.ERROR.Println(err)
 		return
 	}
-	client := &SocketClient{hub: hub, conn: conn, send: make(chan []byte, 256)}
+	client := &SocketClient{
+		hub:        hub,
+		conn:       conn,
+		send:       make(chan []byte, 256),
+		protocol:   conn.Subprotocol(),
+		role:       RoleFromContext(r.Context()),
+		loadpoints: site.LoadPoints(),
+	}
 	client.hub.register <- client
 
-	// run writing to client in goroutine
+	// run writing to and reading from client in goroutines
 	go client.writePump()
+	go client.readPump()
+}
+
+// subscription changes the values sent to a client
+type subscription struct {
+	client *SocketClient
+	filter *socketFilter
+}
+
+// reply is a message for a single client
+type reply struct {
+	client *SocketClient
+	msg    []byte
 }
 
 // SocketHub maintains the set of active clients and broadcasts messages to the
@@ -75,6 +137,12 @@ type SocketHub struct {
 
 	// Unregister requests from clients.
 	unregister chan *SocketClient
+
+	// Subscription changes from clients.
+	subscribe chan subscription
+
+	// Replies to client requests.
+	reply chan reply
 }
 
 // NewSocketHub creates a web socket hub that distributes meter status and
@@ -83,6 +151,8 @@ func NewSocketHub() *SocketHub {
 	return &SocketHub{
 		register:   make(chan *SocketClient),
 		unregister: make(chan *SocketClient),
+		subscribe:  make(chan subscription),
+		reply:      make(chan reply),
 		clients:    make(map[*SocketClient]bool),
 	}
 }
@@ -124,33 +194,49 @@ func kv(p util.Param) string {
 func (h *SocketHub) welcome(client *SocketClient, params []util.Param) {
 	h.clients[client] = true
 
-	var msg strings.Builder
-	msg.WriteString("{")
+	filtered := make([]util.Param, 0, len(params))
 	for _, p := range params {
-		if msg.Len() > 1 {
-			msg.WriteString(",")
+		if client.filter.match(p) {
+			filtered = append(filtered, p)
 		}
-		msg.WriteString(kv(p))
 	}
-	msg.WriteString("}")
 
+	h.send(client, []byte(socketMessage(client.protocol, filtered)))
+}
+
+// send sends message to client and removes the client if it is not receiving
+func (h *SocketHub) send(client *SocketClient, msg []byte) {
 	select {
-	case client.send <- []byte(msg.String()):
+	case client.send <- msg:
 	default:
+		h.remove(client)
+	}
+}
+
+// remove closes the client's send channel and removes it from the hub
+func (h *SocketHub) remove(client *SocketClient) {
+	if _, ok := h.clients[client]; ok {
 		close(client.send)
+		delete(h.clients, client)
 	}
 }
 
 func (h *SocketHub) broadcast(p util.Param) {
 	if len(h.clients) > 0 {
-		msg := "{" + kv(p) + "}"
+		msg := make(map[string][]byte)
 
 		for client := range h.clients {
-			select {
-			case client.send <- []byte(msg):
-			default:
-				h.unregister <- client
+			if !client.filter.match(p) {
+				continue
 			}
+
+			b, ok := msg[client.protocol]
+			if !ok {
+				b = []byte(socketMessage(client.protocol, []util.Param{p}))
+				msg[client.protocol] = b
+			}
+
+			h.send(client, b)
 		}
 	}
 }
@@ -162,9 +248,15 @@ func (h *SocketHub) Run(in <-chan util.Param, cache *util.Cache) {
 		case client := <-h.register:
 			h.welcome(client, cache.All())
 		case client := <-h.unregister:
-			if _, ok := h.clients[client]; ok {
-				close(client.send)
-				delete(h.clients, client)
+			h.remove(client)
+		case sub := <-h.subscribe:
+			if _, ok := h.clients[sub.client]; ok {
+				sub.client.filter = sub.filter
+				h.welcome(sub.client, cache.All())
+			}
+		case r := <-h.reply:
+			if _, ok := h.clients[r.client]; ok {
+				h.send(r.client, r.msg)
 			}
 		case msg, ok := <-in:
 			if !ok {
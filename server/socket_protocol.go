@@ -0,0 +1,219 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/core"
+	"github.com/andig/evcc/util"
+)
+
+// socketProtocolV1 is the websocket subprotocol for bidirectional communication.
+// Clients not requesting the subprotocol receive the plain value stream.
+const socketProtocolV1 = "evcc.v1"
+
+// socket message types
+const (
+	socketUpdate      = "update"
+	socketResult      = "result"
+	socketError       = "error"
+	socketCommand     = "command"
+	socketSubscribe   = "subscribe"
+	socketUnsubscribe = "unsubscribe"
+)
+
+// socketRequest is a client message
+type socketRequest struct {
+	ID        int             `json:"id"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command,omitempty"`
+	LoadPoint *int            `json:"loadpoint,omitempty"`
+	Value     json.RawMessage `json:"value,omitempty"`
+
+	// subscription
+	Keys       []string `json:"keys,omitempty"`
+	LoadPoints []int    `json:"loadpoints,omitempty"`
+}
+
+// socketResponse is the server's answer to a client request
+type socketResponse struct {
+	ID     int         `json:"id"`
+	Type   string      `json:"type"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// socketFilter restricts the values sent to a client
+type socketFilter struct {
+	keys       map[string]bool
+	loadpoints map[int]bool
+}
+
+// newSocketFilter creates a filter. Empty keys or loadpoints match all.
+func newSocketFilter(keys []string, loadpoints []int) *socketFilter {
+	f := &socketFilter{
+		keys:       make(map[string]bool),
+		loadpoints: make(map[int]bool),
+	}
+
+	for _, k := range keys {
+		f.keys[k] = true
+	}
+	for _, id := range loadpoints {
+		f.loadpoints[id] = true
+	}
+
+	return f
+}
+
+// match checks if the param passes the filter
+func (f *socketFilter) match(p util.Param) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.keys) > 0 && !f.keys[p.Key] {
+		return false
+	}
+
+	if len(f.loadpoints) > 0 && p.LoadPoint != nil && !f.loadpoints[*p.LoadPoint] {
+		return false
+	}
+
+	return true
+}
+
+// socketMessage creates the message for the given params
+func socketMessage(protocol string, params []util.Param) string {
+	var msg strings.Builder
+	for _, p := range params {
+		if msg.Len() > 0 {
+			msg.WriteString(",")
+		}
+		msg.WriteString(kv(p))
+	}
+
+	if protocol == socketProtocolV1 {
+		return `{"type":"` + socketUpdate + `","data":{` + msg.String() + "}}"
+	}
+
+	return "{" + msg.String() + "}"
+}
+
+// socketTargetCharge is the value of the setTargetCharge command
+type socketTargetCharge struct {
+	SoC  int    `json:"soc"`
+	Time string `json:"time"`
+}
+
+// socketCommands are the loadpoint commands available to websocket clients
+var socketCommands = map[string]func(lp core.LoadPointAPI, value json.RawMessage) (interface{}, error){
+	"setMode": func(lp core.LoadPointAPI, value json.RawMessage) (interface{}, error) {
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return nil, err
+		}
+
+		mode := api.ChargeModeString(s)
+		if mode == "" {
+			return nil, fmt.Errorf("invalid mode: %s", s)
+		}
+
+		lp.SetMode(mode)
+		return lp.GetMode(), nil
+	},
+
+	"setTargetSoC": func(lp core.LoadPointAPI, value json.RawMessage) (interface{}, error) {
+		var soc int
+		err := json.Unmarshal(value, &soc)
+		if err == nil {
+			err = lp.SetTargetSoC(soc)
+		}
+		return lp.GetTargetSoC(), err
+	},
+
+	"setMinSoC": func(lp core.LoadPointAPI, value json.RawMessage) (interface{}, error) {
+		var soc int
+		err := json.Unmarshal(value, &soc)
+		if err == nil {
+			err = lp.SetMinSoC(soc)
+		}
+		return lp.GetMinSoC(), err
+	},
+
+	"setTargetCharge": func(lp core.LoadPointAPI, value json.RawMessage) (interface{}, error) {
+		var req socketTargetCharge
+		if err := json.Unmarshal(value, &req); err != nil {
+			return nil, err
+		}
+
+		ts, err := parseTime(req.Time)
+		if err != nil {
+			return nil, err
+		}
+
+		lp.SetTargetCharge(ts, req.SoC)
+		return socketTargetCharge{SoC: req.SoC, Time: ts.Format(time.RFC3339)}, nil
+	},
+
+	"setVehicle": func(lp core.LoadPointAPI, value json.RawMessage) (interface{}, error) {
+		var title string
+		if err := json.Unmarshal(value, &title); err != nil {
+			return nil, err
+		}
+		return title, lp.SetVehicle(title)
+	},
+}
+
+// command executes the client's command on the requested loadpoint
+func (c *SocketClient) command(req socketRequest) (interface{}, error) {
+	if c.role < RoleControl {
+		return nil, errors.New("not authorized")
+	}
+
+	handler, ok := socketCommands[req.Command]
+	if !ok {
+		return nil, fmt.Errorf("invalid command: %s", req.Command)
+	}
+
+	if req.LoadPoint == nil || *req.LoadPoint < 0 || *req.LoadPoint >= len(c.loadpoints) {
+		return nil, errors.New("invalid loadpoint")
+	}
+
+	return handler(c.loadpoints[*req.LoadPoint], req.Value)
+}
+
+// handle processes a client message and returns the response
+func (c *SocketClient) handle(b []byte) socketResponse {
+	var req socketRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		return socketResponse{Type: socketError, Error: err.Error()}
+	}
+
+	var res interface{}
+	var err error
+
+	switch req.Type {
+	case socketCommand:
+		res, err = c.command(req)
+
+	case socketSubscribe:
+		c.hub.subscribe <- subscription{client: c, filter: newSocketFilter(req.Keys, req.LoadPoints)}
+
+	case socketUnsubscribe:
+		c.hub.subscribe <- subscription{client: c}
+
+	default:
+		err = fmt.Errorf("invalid type: %s", req.Type)
+	}
+
+	if err != nil {
+		return socketResponse{ID: req.ID, Type: socketError, Error: err.Error()}
+	}
+
+	return socketResponse{ID: req.ID, Type: socketResult, Result: res}
+}
@@ -1,9 +1,17 @@
 package server
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/andig/evcc/api"
+	"github.com/andig/evcc/core"
+	"github.com/andig/evcc/util"
+	"github.com/gorilla/websocket"
 )
 
 func TestEncode(t *testing.T) {
@@ -31,3 +39,154 @@ func TestEncode(t *testing.T) {
 		}
 	}
 }
+
+type socketLoadPoint struct {
+	core.LoadPointAPI
+	mode api.ChargeMode
+}
+
+func (lp *socketLoadPoint) GetMode() api.ChargeMode     { return lp.mode }
+func (lp *socketLoadPoint) SetMode(mode api.ChargeMode) { lp.mode = mode }
+
+type socketSite struct {
+	core.SiteAPI
+	lp *socketLoadPoint
+}
+
+func (site *socketSite) LoadPoints() []core.LoadPointAPI {
+	return []core.LoadPointAPI{site.lp}
+}
+
+func TestSocketProtocol(t *testing.T) {
+	lp := 0
+	cache := util.NewCache()
+	for _, p := range []util.Param{
+		{Key: "gridPower", Val: 1000.0},
+		{LoadPoint: &lp, Key: "chargePower", Val: 500.0},
+	} {
+		cache.Add(p.UniqueID(), p)
+	}
+
+	in := make(chan util.Param)
+	hub := NewSocketHub()
+	go hub.Run(in, cache)
+
+	site := &socketSite{lp: new(socketLoadPoint)}
+	srv := httptest.NewServer(SocketHandler(hub, site))
+	defer srv.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{socketProtocolV1}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	read := func() map[string]interface{} {
+		var res map[string]interface{}
+		if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.ReadJSON(&res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// welcome
+	if res := read(); res["type"] != socketUpdate || len(res["data"].(map[string]interface{})) != 2 {
+		t.Errorf("unexpected welcome: %v", res)
+	}
+
+	// command
+	if err := conn.WriteJSON(map[string]interface{}{
+		"id": 1, "type": socketCommand, "command": "setMode", "loadpoint": 0, "value": "pv",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := read(); res["id"] != 1.0 || res["type"] != socketResult || res["result"] != "pv" || site.lp.mode != api.ModePV {
+		t.Errorf("unexpected result: %v", res)
+	}
+
+	// invalid command
+	if err := conn.WriteJSON(map[string]interface{}{
+		"id": 2, "type": socketCommand, "command": "setMode", "loadpoint": 1, "value": "pv",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := read(); res["id"] != 2.0 || res["type"] != socketError || res["error"] != "invalid loadpoint" {
+		t.Errorf("unexpected result: %v", res)
+	}
+
+	// subscribe
+	if err := conn.WriteJSON(map[string]interface{}{
+		"id": 3, "type": socketSubscribe, "keys": []string{"chargePower"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := read(); res["type"] != socketUpdate || res["data"].(map[string]interface{})["loadpoints.0.chargePower"] != 500.0 {
+		t.Errorf("unexpected update: %v", res)
+	}
+
+	if res := read(); res["id"] != 3.0 || res["type"] != socketResult {
+		t.Errorf("unexpected result: %v", res)
+	}
+
+	in <- util.Param{Key: "gridPower", Val: 2000.0}
+	in <- util.Param{LoadPoint: &lp, Key: "chargePower", Val: 600.0}
+
+	if res := read(); res["type"] != socketUpdate || len(res["data"].(map[string]interface{})) != 1 {
+		t.Errorf("unexpected update: %v", res)
+	}
+}
+
+func TestSocketCommandRole(t *testing.T) {
+	c := &SocketClient{role: RoleRead, loadpoints: []core.LoadPointAPI{new(socketLoadPoint)}}
+
+	res := c.handle([]byte(`{"id":1,"type":"command","command":"setMode","loadpoint":0,"value":"pv"}`))
+	if res.Type != socketError || res.Error != "not authorized" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
+
+func TestSocketOrigin(t *testing.T) {
+	hub := NewSocketHub()
+	go hub.Run(make(chan util.Param), util.NewCache())
+
+	srv := httptest.NewServer(SocketHandler(hub, &socketSite{lp: new(socketLoadPoint)}))
+	defer srv.Close()
+
+	uri := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	tc := []struct {
+		origin string
+		status int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{srv.URL, http.StatusSwitchingProtocols},
+		{"http://evil.example", http.StatusForbidden},
+	}
+
+	for _, tc := range tc {
+		header := make(http.Header)
+		if tc.origin != "" {
+			header.Set("Origin", tc.origin)
+		}
+
+		conn, resp, err := websocket.DefaultDialer.Dial(uri, header)
+		if conn != nil {
+			conn.Close()
+		}
+
+		if resp == nil {
+			t.Fatalf("%q: %v", tc.origin, err)
+		}
+
+		if resp.StatusCode != tc.status {
+			t.Errorf("%q: expected %d, got %d", tc.origin, tc.status, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/andig/evcc/core"
+)
+
+type statisticsJSON struct {
+	Today  core.EnergyTotals            `json:"today"`
+	Days   map[string]core.EnergyTotals `json:"days"`
+	Months map[string]core.EnergyTotals `json:"months"`
+}
+
+// BaseloadHandler returns the learned household baseload by hour of the week starting Sunday 0:00
+func BaseloadHandler(baseload *core.Baseload) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jsonResponse(w, r, baseload.Profile())
+	}
+}
+
+// StatisticsHandler returns today's, daily and monthly energy totals
+func StatisticsHandler(stats *core.Statistics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res := statisticsJSON{
+			Today:  stats.Today(),
+			Days:   stats.Days(),
+			Months: stats.Months(),
+		}
+
+		jsonResponse(w, r, res)
+	}
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/andig/evcc/util"
+)
+
+const (
+	selfSignedCert     = "evcc.crt"
+	selfSignedKey      = "evcc.key"
+	selfSignedValidity = 10 * 365 * 24 * time.Hour
+)
+
+// TLSConfig is the https configuration
+type TLSConfig struct {
+	Cert       string // certificate file
+	Key        string // private key file
+	SelfSigned bool   // create self-signed certificate in data directory if no certificate is configured
+	Redirect   string // listen address of plain http server redirecting to https, e.g. :80
+	ClientCA   string // ca certificate file for verifying client certificates
+	ClientAuth string // optional (default) or require client certificates
+}
+
+// Enabled returns true if https is configured
+func (c TLSConfig) Enabled() bool {
+	return c.Cert != "" || c.SelfSigned
+}
+
+// Validate checks the tls configuration against the authentication configuration. Without
+// authentication, optional client certificates have no effect as they only grant roles.
+func (c TLSConfig) Validate(auth AuthConfig) error {
+	if c.ClientCA != "" && !auth.Enabled() && !strings.EqualFold(c.ClientAuth, "require") {
+		return errors.New("tls: optional client certificates require auth to be configured")
+	}
+
+	return nil
+}
+
+// WithTLS enables https. The self-signed certificate is stored in dataDir.
+func (s *HTTPd) WithTLS(conf TLSConfig, dataDir string) error {
+	var cert tls.Certificate
+	var err error
+
+	switch {
+	case conf.Cert != "" || conf.Key != "":
+		cert, err = tls.LoadX509KeyPair(conf.Cert, conf.Key)
+	case conf.SelfSigned:
+		cert, err = selfSignedCertificate(dataDir)
+	default:
+		err = errors.New("missing certificate")
+	}
+
+	if err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if conf.ClientCA != "" {
+		pem, err := os.ReadFile(conf.ClientCA)
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls: no certificates found in %s", conf.ClientCA)
+		}
+
+		tlsConfig.ClientCAs = pool
+
+		switch strings.ToLower(conf.ClientAuth) {
+		case "", "optional":
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		case "require":
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		default:
+			return fmt.Errorf("tls: invalid client auth: %s", conf.ClientAuth)
+		}
+	}
+
+	s.TLSConfig = tlsConfig
+	s.redirect = conf.Redirect
+
+	return nil
+}
+
+// ListenAndServe starts the http server or https server if tls is enabled
+func (s *HTTPd) ListenAndServe() error {
+	if s.TLSConfig == nil {
+		return s.Server.ListenAndServe()
+	}
+
+	if s.redirect != "" {
+		go func() {
+			log.ERROR.Println(http.ListenAndServe(s.redirect, redirectHandler(s.Addr)))
+		}()
+	}
+
+	return s.Server.ListenAndServeTLS("", "")
+}
+
+// redirectHandler redirects all requests to the https server listening at addr
+func redirectHandler(addr string) http.Handler {
+	_, port, _ := net.SplitHostPort(addr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
+
+// selfSignedCertificate loads the self-signed certificate from dir or creates it
+func selfSignedCertificate(dir string) (tls.Certificate, error) {
+	certFile := filepath.Join(dir, selfSignedCert)
+	keyFile := filepath.Join(dir, selfSignedKey)
+
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		return cert, nil
+	}
+
+	certPEM, keyPEM, err := createCertificate(time.Now())
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if err := util.WriteFileAtomic(keyFile, keyPEM, 0600); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := util.WriteFileAtomic(certFile, certPEM, 0644); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	log.INFO.Printf("created self-signed certificate %s", certFile)
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// createCertificate creates a self-signed certificate valid for the host's names and addresses
+func createCertificate(now time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"evcc"}, CommonName: "evcc"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				template.IPAddresses = append(template.IPAddresses, ipnet.IP)
+			}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return certPEM, keyPEM, nil
+}
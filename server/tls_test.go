@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelfSignedCertificate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "evcc") // data directory is created on first write
+
+	cert, err := selfSignedCertificate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// persisted certificate is reused
+	cert2, err := selfSignedCertificate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(cert.Certificate[0]) != string(cert2.Certificate[0]) {
+		t.Error("certificate not reused")
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := leaf.VerifyHostname("localhost"); err != nil {
+		t.Error(err)
+	}
+
+	if fi, err := os.Stat(filepath.Join(dir, selfSignedKey)); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("invalid key file: %v", err)
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tc := []struct {
+		addr, host, uri, location string
+	}{
+		{":443", "evcc.local", "/api/state", "https://evcc.local/api/state"},
+		{":7070", "evcc.local:80", "/?foo=bar", "https://evcc.local:7070/?foo=bar"},
+		{"0.0.0.0:8443", "192.168.1.2", "/", "https://192.168.1.2:8443/"},
+	}
+
+	for _, tc := range tc {
+		r := httptest.NewRequest(http.MethodGet, tc.uri, nil)
+		r.Host = tc.host
+
+		w := httptest.NewRecorder()
+		redirectHandler(tc.addr).ServeHTTP(w, r)
+
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("expected %d, got %d", http.StatusMovedPermanently, w.Code)
+		}
+		if loc := w.Header().Get("Location"); loc != tc.location {
+			t.Errorf("expected %s, got %s", tc.location, loc)
+		}
+	}
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	auth := AuthConfig{Password: "secret"}
+
+	tc := []struct {
+		tls  TLSConfig
+		auth AuthConfig
+		err  bool
+	}{
+		{TLSConfig{SelfSigned: true}, AuthConfig{}, false},
+		{TLSConfig{SelfSigned: true, ClientCA: "ca.crt"}, auth, false},
+		{TLSConfig{SelfSigned: true, ClientCA: "ca.crt"}, AuthConfig{}, true},
+		{TLSConfig{SelfSigned: true, ClientCA: "ca.crt", ClientAuth: "optional"}, AuthConfig{}, true},
+		{TLSConfig{SelfSigned: true, ClientCA: "ca.crt", ClientAuth: "require"}, AuthConfig{}, false},
+	}
+
+	for _, tc := range tc {
+		if err := tc.tls.Validate(tc.auth); (err != nil) != tc.err {
+			t.Errorf("%+v: expected error %v, got %v", tc.tls, tc.err, err)
+		}
+	}
+}
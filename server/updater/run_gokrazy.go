@@ -23,7 +23,7 @@ func Run(log *util.Logger, httpd webServer, tee util.TeeAttacher, outChan chan<-
 		repo:    NewRepo(server.Owner, server.Repository),
 	}
 
-	httpd.Router().PathPrefix("/api/update").HandlerFunc(u.updateHandler)
+	httpd.Router().PathPrefix("/api/update").Handler(httpd.Authorize(server.RoleControl)(http.HandlerFunc(u.updateHandler)))
 
 	c := make(chan *github.RepositoryRelease, 1)
 	go u.watchReleases(server.Version, c) // endless
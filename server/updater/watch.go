@@ -4,6 +4,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/andig/evcc/server"
 	"github.com/andig/evcc/util"
 	"github.com/google/go-github/v32/github"
 	"github.com/gorilla/mux"
@@ -12,6 +13,7 @@ import (
 
 type webServer interface {
 	Router() *mux.Router
+	Authorize(server.Role) mux.MiddlewareFunc
 }
 
 type watch struct {
@@ -0,0 +1,81 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// CSV selects a single field of a CSV document
+type CSV struct {
+	Delimiter string // field delimiter, default ,
+	Header    bool   // first row contains column names
+	Column    string // zero-based column index or column name if header is used, default 0
+	Row       int    // zero-based data row, negative rows count from the end
+}
+
+// field returns the selected field
+func (c *CSV) field(b []byte) (string, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+
+	if c.Delimiter != "" {
+		delim, size := utf8.DecodeRuneInString(c.Delimiter)
+		if size != len(c.Delimiter) {
+			return "", fmt.Errorf("csv: invalid delimiter: %s", c.Delimiter)
+		}
+		r.Comma = delim
+	}
+
+	rows, err := r.ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("csv: %w", err)
+	}
+
+	column := c.Column
+	if column == "" {
+		column = "0"
+	}
+
+	col := -1
+	if c.Header {
+		if len(rows) == 0 {
+			return "", fmt.Errorf("csv: missing header")
+		}
+
+		for i, name := range rows[0] {
+			if strings.TrimSpace(name) == column {
+				col = i
+				break
+			}
+		}
+
+		rows = rows[1:]
+	}
+
+	if col < 0 {
+		if col, err = strconv.Atoi(column); err != nil || col < 0 {
+			return "", fmt.Errorf("csv: invalid column: %s", column)
+		}
+	}
+
+	row := c.Row
+	if row < 0 {
+		row += len(rows)
+	}
+
+	if row < 0 || row >= len(rows) {
+		return "", fmt.Errorf("csv: row %d not found", c.Row)
+	}
+
+	if col >= len(rows[row]) {
+		return "", fmt.Errorf("csv: column %s not found", column)
+	}
+
+	return rows[row][col], nil
+}
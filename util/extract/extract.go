@@ -0,0 +1,122 @@
+// Package extract implements value extraction from JSON, XML, HTML and CSV documents
+// shared by the text-based providers.
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/andig/evcc/util/jq"
+	"github.com/antchfx/xpath"
+	"github.com/itchyny/gojq"
+)
+
+// Config is the extraction config. Jq, XPath and CSV select a value from the document,
+// Regex is applied to the selected value or the raw document.
+type Config struct {
+	Jq    string // jq query for JSON documents
+	XPath string // xpath expression for XML and HTML documents
+	Regex string // regular expression, returns first capture group if available
+	CSV   *CSV   // field of CSV document
+}
+
+// Extractor extracts a single value from a document
+type Extractor struct {
+	jq    *gojq.Query
+	xpath *xpath.Expr
+	csv   *CSV
+	re    *regexp.Regexp
+}
+
+// New creates an extractor. Without extraction options the trimmed document is returned.
+func New(cc Config) (*Extractor, error) {
+	var n int
+	for _, ok := range []bool{cc.Jq != "", cc.XPath != "", cc.CSV != nil} {
+		if ok {
+			n++
+		}
+	}
+
+	if n > 1 {
+		return nil, errors.New("can only use one of jq, xpath or csv")
+	}
+
+	e := &Extractor{csv: cc.CSV}
+
+	if cc.Jq != "" {
+		op, err := gojq.Parse(cc.Jq)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jq query '%s': %w", cc.Jq, err)
+		}
+
+		e.jq = op
+	}
+
+	if cc.XPath != "" {
+		x, err := xpath.Compile(cc.XPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid xpath '%s': %w", cc.XPath, err)
+		}
+
+		e.xpath = x
+	}
+
+	if cc.Regex != "" {
+		re, err := regexp.Compile(cc.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex '%s': %w", cc.Regex, err)
+		}
+
+		e.re = re
+	}
+
+	return e, nil
+}
+
+// Extract returns the extracted value as trimmed string
+func (e *Extractor) Extract(b []byte) (string, error) {
+	s := string(b)
+
+	switch {
+	case e.jq != nil:
+		v, err := jq.Query(e.jq, b)
+		if err != nil {
+			return "", err
+		}
+
+		s = fmt.Sprintf("%v", v)
+
+	case e.xpath != nil:
+		nav, err := navigator(b)
+		if err != nil {
+			return "", fmt.Errorf("xpath: %w", err)
+		}
+
+		if s, err = evaluate(e.xpath, nav); err != nil {
+			return "", fmt.Errorf("xpath: %w", err)
+		}
+
+	case e.csv != nil:
+		var err error
+		if s, err = e.csv.field(b); err != nil {
+			return "", err
+		}
+	}
+
+	if e.re != nil {
+		match := e.re.FindStringSubmatch(s)
+		if match == nil {
+			return "", errors.New("regex: no match")
+		}
+
+		// use first capture group if available
+		s = match[0]
+		if len(match) > 1 {
+			s = match[1]
+		}
+	}
+
+	return strings.TrimSpace(s), nil
+}
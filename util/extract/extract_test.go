@@ -0,0 +1,137 @@
+package extract
+
+import (
+	"testing"
+)
+
+// Fronius legacy solar api
+const froniusXML = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<Device xmlns:ns="urn:fronius">
+  <Inverter id="1" state="running">
+    <ns:Power unit="W">2345</ns:Power>
+    <Energy unit="Wh">123456</Energy>
+  </Inverter>
+  <Inverter id="2" state="standby">
+    <ns:Power unit="W">0</ns:Power>
+    <Energy unit="Wh">7890</Energy>
+  </Inverter>
+  <Value><Name>grid</Name><Data>-120</Data></Value>
+  <Value><Name>load</Name><Data>850</Data></Value>
+</Device>`
+
+// ISO-8859-1 encoded document with non-ASCII text
+const latin1XML = "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+	"<Meters><Meter><Name>Netz</Name><Power>-200</Power></Meter>" +
+	"<Meter><Name>Z\xe4hler</Name><Power>1500</Power></Meter></Meters>"
+
+// older Kostal inverter web interface
+const kostalHTML = `<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 4.01 Transitional//EN">
+<html><head><title>PV Webserver</title></head>
+<body>
+<table>
+<tr><td>aktuell</td><td>Leistung: 1234 W</td>
+<tr><td>Gesamtenergie</td><td>45678</td><td>kWh</td>
+<tr><td>Status</td><td id="status">Einspeisen MPP</td>
+</table>
+</body></html>`
+
+const solarlogCSV = `Datum;Uhrzeit;Pac;DaySum
+01.06.21;12:00:00;3100;12.5
+01.06.21;12:05:00;3250;12.8
+`
+
+func TestExtract(t *testing.T) {
+	tc := []struct {
+		doc      string
+		config   Config
+		expected string
+	}{
+		// raw
+		{" 42\n", Config{}, "42"},
+		{"power=1234 W", Config{Regex: `power=(\d+)`}, "1234"},
+		{"power=1234 W", Config{Regex: `\d+ W`}, "1234 W"},
+		// jq
+		{`{"power":1.5}`, Config{Jq: ".power"}, "1.5"},
+		{`{"status":"ok 200"}`, Config{Jq: ".status", Regex: `\d+`}, "200"},
+		// xml
+		{latin1XML, Config{XPath: "//Meter[Name='Zähler']/Power"}, "1500"},
+		{latin1XML, Config{XPath: "//Meter[2]/Name"}, "Zähler"},
+		{froniusXML, Config{XPath: "/Device/Inverter[1]/ns:Power"}, "2345"},
+		{froniusXML, Config{XPath: "//Inverter[@state='standby']/Energy"}, "7890"},
+		{froniusXML, Config{XPath: "//Inverter[last()]/@id"}, "2"},
+		{froniusXML, Config{XPath: "//ns:Power/@unit"}, "W"},
+		{froniusXML, Config{XPath: "//Value[Name='load']/Data"}, "850"},
+		{froniusXML, Config{XPath: "//Name[.='grid']/../Data/text()"}, "-120"},
+		// html
+		{kostalHTML, Config{XPath: "//tr[1]/td[2]", Regex: `(\d+) W`}, "1234"},
+		{kostalHTML, Config{XPath: "//tr[td='Gesamtenergie']/td[2]"}, "45678"},
+		{kostalHTML, Config{XPath: "//td[@id='status']"}, "Einspeisen MPP"},
+		{kostalHTML, Config{XPath: "//title"}, "PV Webserver"},
+		{"<table><tr><td>Leistung<td>980</table>", Config{XPath: "//td[2]"}, "980"},
+		{"<div><span class=\"power\">512</span></div>", Config{XPath: "//span[@class='power']"}, "512"},
+		// functions
+		{froniusXML, Config{XPath: "count(//Inverter)"}, "2"},
+		{froniusXML, Config{XPath: "sum(//Energy)"}, "131346"},
+		// csv
+		{solarlogCSV, Config{CSV: &CSV{Delimiter: ";", Header: true, Column: "Pac", Row: -1}}, "3250"},
+		{solarlogCSV, Config{CSV: &CSV{Delimiter: ";", Header: true, Column: "3"}}, "12.5"},
+		{solarlogCSV, Config{CSV: &CSV{Delimiter: ";", Column: "2"}}, "Pac"},
+		{"a,b\n1,\"2,5\"\n", Config{CSV: &CSV{Header: true, Column: "b"}}, "2,5"},
+		{"1200\n1300\n1250\n", Config{CSV: &CSV{Row: -1}}, "1250"},
+		{solarlogCSV, Config{CSV: &CSV{Delimiter: ";", Header: true}}, "01.06.21"},
+	}
+
+	for _, tc := range tc {
+		e, err := New(tc.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := e.Extract([]byte(tc.doc))
+		if err != nil {
+			t.Errorf("%+v: %v", tc.config, err)
+		} else if res != tc.expected {
+			t.Errorf("%+v: expected %q, got %q", tc.config, tc.expected, res)
+		}
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	tc := []struct {
+		doc    string
+		config Config
+	}{
+		{"power", Config{Regex: `\d+`}},
+		{`{"power":`, Config{Jq: ".power"}},
+		{froniusXML, Config{XPath: "//Inverter[3]"}},
+		{"<device><power></device>", Config{XPath: "//power"}},
+		{solarlogCSV, Config{CSV: &CSV{Delimiter: ";", Header: true, Column: "Pac", Row: 2}}},
+		{solarlogCSV, Config{CSV: &CSV{Delimiter: ";", Header: true, Column: "Voltage"}}},
+		{solarlogCSV, Config{CSV: &CSV{Delimiter: ";", Column: "9"}}},
+	}
+
+	for _, tc := range tc {
+		e, err := New(tc.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res, err := e.Extract([]byte(tc.doc)); err == nil {
+			t.Errorf("%+v: expected error, got %q", tc.config, res)
+		}
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, cc := range []Config{
+		{Jq: ".", XPath: "/a"},
+		{XPath: "//a[@b"},
+		{XPath: "/a/"},
+		{XPath: "count(//a"},
+		{Regex: "("},
+	} {
+		if _, err := New(cc); err == nil {
+			t.Errorf("%+v: expected error", cc)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// htmlRegex identifies complete HTML documents
+var htmlRegex = regexp.MustCompile(`(?i)^\s*(<\?xml[^>]*>\s*)?<(!doctype\s+html|html)`)
+
+// elementRegex matches the first element of a document
+var elementRegex = regexp.MustCompile(`<([a-zA-Z][\w.:-]*)`)
+
+// isHTML identifies HTML documents and fragments starting with an HTML element like <table>
+func isHTML(b []byte) bool {
+	if htmlRegex.Match(b) {
+		return true
+	}
+
+	match := elementRegex.FindSubmatch(b)
+	return match != nil && atom.Lookup(bytes.ToLower(match[1])) != 0
+}
+
+// navigator parses HTML documents leniently and XML documents strictly. XML fragments that
+// are not well-formed, e.g. HTML fragments with unclosed elements, are parsed as HTML.
+func navigator(b []byte) (xpath.NodeNavigator, error) {
+	html := isHTML(b)
+
+	if !html || !htmlRegex.Match(b) {
+		doc, err := xmlquery.Parse(bytes.NewReader(b))
+		if err == nil {
+			return xmlquery.CreateXPathNavigator(doc), nil
+		}
+
+		if !html {
+			return nil, err
+		}
+	}
+
+	// decode charset declared by meta element or byte order mark
+	r, err := charset.NewReader(bytes.NewReader(b), "")
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := htmlquery.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return htmlquery.CreateXPathNavigator(doc), nil
+}
+
+// evaluate returns the string value of the expression's first node or its result
+func evaluate(expr *xpath.Expr, nav xpath.NodeNavigator) (string, error) {
+	switch res := expr.Evaluate(nav).(type) {
+	case *xpath.NodeIterator:
+		if !res.MoveNext() {
+			return "", errors.New("empty result")
+		}
+
+		return res.Current().Value(), nil
+
+	case float64:
+		return strconv.FormatFloat(res, 'f', -1, 64), nil
+
+	default:
+		return fmt.Sprintf("%v", res), nil
+	}
+}
@@ -0,0 +1,58 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data", "test.json")
+
+	// directory is created
+	if err := WriteFileAtomic(file, []byte("foo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// concurrent writers
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := WriteFileAtomic(file, []byte(fmt.Sprintf("value %d", i)), 0600); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found bool
+	for i := 0; i < 10; i++ {
+		found = found || string(b) == fmt.Sprintf("value %d", i)
+	}
+	if !found {
+		t.Errorf("unexpected content %q", b)
+	}
+
+	// no temporary files are left
+	entries, err := os.ReadDir(filepath.Dir(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected single file, got %d", len(entries))
+	}
+
+	if fi, err := os.Stat(file); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("unexpected file mode: %v %v", fi.Mode(), err)
+	}
+}
@@ -0,0 +1,88 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/andig/evcc/util"
+	"golang.org/x/oauth2"
+)
+
+// TokenDir is the directory tokens are persisted to. Tokens are not persisted if empty.
+var TokenDir string
+
+// TokenFile returns the token file for the given identifying keys or empty string if tokens are not persisted
+func TokenFile(keys ...string) string {
+	if TokenDir == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return filepath.Join(TokenDir, fmt.Sprintf("token-%x.json", hash[:8]))
+}
+
+// LoadToken reads a persisted token from file
+func LoadToken(file string) (*oauth2.Token, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var token oauth2.Token
+	if err := json.Unmarshal(b, &token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// saveToken writes the token to file atomically
+func saveToken(file string, token *oauth2.Token) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFileAtomic(file, b, 0600)
+}
+
+type persistentTokenSource struct {
+	mu     sync.Mutex
+	log    *util.Logger
+	file   string
+	ts     oauth2.TokenSource
+	access string
+}
+
+// PersistentTokenSource serializes access to the token source and stores the token to file
+// whenever it changes. If file is empty, tokens are not persisted.
+func PersistentTokenSource(log *util.Logger, file string, ts oauth2.TokenSource) oauth2.TokenSource {
+	return &persistentTokenSource{
+		log:  log,
+		file: file,
+		ts:   ts,
+	}
+}
+
+func (ts *persistentTokenSource) Token() (*oauth2.Token, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	token, err := ts.ts.Token()
+	if err != nil || ts.file == "" || token.AccessToken == ts.access {
+		return token, err
+	}
+
+	if err := saveToken(ts.file, token); err != nil {
+		ts.log.ERROR.Printf("persist token: %v", err)
+	} else {
+		ts.access = token.AccessToken
+	}
+
+	return token, nil
+}
@@ -0,0 +1,229 @@
+package request
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// digestChallenge is a parsed WWW-Authenticate digest challenge
+type digestChallenge struct {
+	realm, nonce, opaque, algorithm, qop string
+}
+
+// parseDigestChallenge parses the parameters of a digest WWW-Authenticate header
+func parseDigestChallenge(header string) (*digestChallenge, bool) {
+	const prefix = "digest "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return nil, false
+	}
+
+	c := new(digestChallenge)
+
+	for _, param := range splitParams(header[len(prefix):]) {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		val := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "realm":
+			c.realm = val
+		case "nonce":
+			c.nonce = val
+		case "opaque":
+			c.opaque = val
+		case "algorithm":
+			c.algorithm = val
+		case "qop":
+			// prefer auth, auth-int is not supported
+			for _, qop := range strings.Split(val, ",") {
+				if strings.TrimSpace(qop) == "auth" {
+					c.qop = "auth"
+				}
+			}
+		}
+	}
+
+	return c, c.nonce != ""
+}
+
+// splitParams splits comma-separated parameters respecting quoted strings
+func splitParams(s string) []string {
+	var res []string
+	var quoted bool
+
+	start := 0
+	for i, c := range s {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			res = append(res, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(res, s[start:])
+}
+
+// hash returns the challenge's hash function
+func (c *digestChallenge) hash() (func() hash.Hash, error) {
+	switch strings.TrimSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+	case "", "MD5":
+		return md5.New, nil
+	case "SHA-256":
+		return sha256.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm: %s", c.algorithm)
+	}
+}
+
+// authorization creates the authorization header value for the request
+func (c *digestChallenge) authorization(user, password, method, uri string, nc int, cnonce string) (string, error) {
+	newHash, err := c.hash()
+	if err != nil {
+		return "", err
+	}
+
+	h := func(s string) string {
+		hash := newHash()
+		_, _ = io.WriteString(hash, s)
+		return hex.EncodeToString(hash.Sum(nil))
+	}
+
+	ha1 := h(user + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+
+	ha2 := h(method + ":" + uri)
+
+	res := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s"`, user, c.realm, c.nonce, uri)
+
+	if c.qop == "" {
+		res += fmt.Sprintf(`, response="%s"`, h(ha1+":"+c.nonce+":"+ha2))
+	} else {
+		count := fmt.Sprintf("%08x", nc)
+		response := h(ha1 + ":" + c.nonce + ":" + count + ":" + cnonce + ":" + c.qop + ":" + ha2)
+		res += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s", response="%s"`, c.qop, count, cnonce, response)
+	}
+
+	if c.algorithm != "" {
+		res += ", algorithm=" + c.algorithm
+	}
+
+	if c.opaque != "" {
+		res += fmt.Sprintf(`, opaque="%s"`, c.opaque)
+	}
+
+	return res, nil
+}
+
+// cnonce creates a random client nonce
+func cnonce() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type digestTransport struct {
+	mu             sync.Mutex
+	base           http.RoundTripper
+	user, password string
+	challenge      *digestChallenge
+	nc             int
+	cnonce         func() string
+}
+
+// NewDigestTransport creates a round tripper implementing HTTP digest authentication.
+// The server's challenge is cached and re-used for subsequent requests.
+func NewDigestTransport(base http.RoundTripper, user, password string) http.RoundTripper {
+	return &digestTransport{
+		base:     base,
+		user:     user,
+		password: password,
+		cnonce:   cnonce,
+	}
+}
+
+// authorize clones the request adding the authorization header from the cached challenge
+func (t *digestTransport) authorize(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.challenge != nil {
+		t.nc++
+
+		auth, err := t.challenge.authorization(t.user, t.password, req.Method, req.URL.RequestURI(), t.nc, t.cnonce())
+		if err != nil {
+			return nil, err
+		}
+
+		clone.Header.Set("Authorization", auth)
+	}
+
+	return clone, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clone, err := t.authorize(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(clone)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// request body cannot be replayed
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	var challenge *digestChallenge
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		if c, ok := parseDigestChallenge(header); ok {
+			challenge = c
+			break
+		}
+	}
+
+	if challenge == nil {
+		return resp, nil
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	t.mu.Lock()
+	t.challenge, t.nc = challenge, 0
+	t.mu.Unlock()
+
+	if clone, err = t.authorize(req); err != nil {
+		return nil, err
+	}
+
+	return t.base.RoundTrip(clone)
+}
@@ -0,0 +1,121 @@
+package request
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDigestAuthorization(t *testing.T) {
+	// RFC 2617 3.5 example
+	c, ok := parseDigestChallenge(`Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+	if !ok {
+		t.Fatal("invalid challenge")
+	}
+
+	auth, err := c.authorization("Mufasa", "Circle Of Life", http.MethodGet, "/dir/index.html", 1, "0a4f113b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		`response="6629fae49393a05397450978507c4ef1"`,
+		`nc=00000001`,
+		`qop=auth,`,
+		`opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+	} {
+		if !strings.Contains(auth, expected) {
+			t.Errorf("expected %s in %s", expected, auth)
+		}
+	}
+}
+
+func TestDigestChallenge(t *testing.T) {
+	tc := []struct {
+		header             string
+		ok                 bool
+		realm, algo, nonce string
+	}{
+		{`Basic realm="foo"`, false, "", "", ""},
+		{`Digest realm="a, b", nonce="123"`, true, "a, b", "", "123"},
+		{`digest qop="auth", realm="shelly", nonce="60dc", algorithm=SHA-256`, true, "shelly", "SHA-256", "60dc"},
+	}
+
+	for _, tc := range tc {
+		c, ok := parseDigestChallenge(tc.header)
+		if ok != tc.ok {
+			t.Errorf("%s: expected %v, got %v", tc.header, tc.ok, ok)
+		}
+
+		if !ok {
+			continue
+		}
+
+		if c.realm != tc.realm || c.algorithm != tc.algo || c.nonce != tc.nonce {
+			t.Errorf("%s: unexpected challenge %+v", tc.header, c)
+		}
+	}
+}
+
+func TestDigestTransport(t *testing.T) {
+	challenge := &digestChallenge{realm: "test", nonce: "abc", qop: "auth", algorithm: "SHA-256"}
+
+	var challenges int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+
+		var cnonce, nc string
+		for _, param := range splitParams(strings.TrimPrefix(auth, "Digest ")) {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			switch kv[0] {
+			case "cnonce":
+				cnonce = strings.Trim(kv[1], `"`)
+			case "nc":
+				nc = kv[1]
+			}
+		}
+
+		var count int
+		if _, err := fmt.Sscanf(nc, "%x", &count); auth == "" || err != nil {
+			challenges++
+			w.Header().Add("WWW-Authenticate", `Basic realm="test"`)
+			w.Header().Add("WWW-Authenticate", `Digest realm="test", nonce="abc", qop="auth", algorithm=SHA-256`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		expected, _ := challenge.authorization("user", "pass", r.Method, r.URL.RequestURI(), count, cnonce)
+		if auth != expected {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewDigestTransport(http.DefaultTransport, "user", "pass")}
+
+	for i := 0; i < 2; i++ {
+		req, _ := New(http.MethodPost, srv.URL+"/rpc?id=1", strings.NewReader("payload"))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := ReadBody(resp)
+		if err != nil || string(body) != "payload" {
+			t.Errorf("unexpected response: %d %s %v", resp.StatusCode, body, err)
+		}
+	}
+
+	// challenge is re-used
+	if challenges != 1 {
+		t.Errorf("expected 1 challenge, got %d", challenges)
+	}
+}
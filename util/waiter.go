@@ -3,6 +3,8 @@ package util
 import (
 	"sync"
 	"time"
+
+	"github.com/benbjohnson/clock"
 )
 
 const waitTimeout = 50 * time.Millisecond // polling interval when waiting for initial value
@@ -10,6 +12,7 @@ const waitTimeout = 50 * time.Millisecond // polling interval when waiting for i
 // Waiter provides monitoring of receive timeouts and reception of initial value
 type Waiter struct {
 	sync.Mutex
+	clock   clock.Clock
 	log     func()
 	once    sync.Once
 	updated time.Time
@@ -19,6 +22,7 @@ type Waiter struct {
 // NewWaiter creates new waiter
 func NewWaiter(timeout time.Duration, logInitialWait func()) *Waiter {
 	return &Waiter{
+		clock:   clock.New(),
 		log:     logInitialWait,
 		timeout: timeout,
 	}
@@ -27,7 +31,7 @@ func NewWaiter(timeout time.Duration, logInitialWait func()) *Waiter {
 // Update is called when client has received data. Update resets the timeout counter.
 // It is client responsibility to ensure that the waiter is not locked when Update is called.
 func (p *Waiter) Update() {
-	p.updated = time.Now()
+	p.updated = p.clock.Now()
 }
 
 // waitForInitialValue blocks until Update has been called at least once.
@@ -37,14 +41,14 @@ func (p *Waiter) waitForInitialValue() {
 		p.log()
 
 		// wait for initial update
-		waitStarted := time.Now()
+		waitStarted := p.clock.Now()
 		for p.updated.IsZero() {
 			p.Unlock()
 			time.Sleep(waitTimeout)
 			p.Lock()
 
 			// abort initial wait with error
-			if p.timeout != 0 && time.Since(waitStarted) > p.timeout {
+			if p.timeout != 0 && p.clock.Since(waitStarted) > p.timeout {
 				p.updated = waitStarted
 				return
 			}
@@ -59,7 +63,7 @@ func (p *Waiter) LockWithTimeout() time.Duration {
 	// waiting assumes lock acquired and returns with lock
 	p.once.Do(p.waitForInitialValue)
 
-	if elapsed := time.Since(p.updated); p.timeout != 0 && elapsed > p.timeout {
+	if elapsed := p.clock.Since(p.updated); p.timeout != 0 && elapsed > p.timeout {
 		return elapsed
 	}
 
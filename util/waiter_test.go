@@ -0,0 +1,39 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+func TestWaiterTimeout(t *testing.T) {
+	clck := clock.NewMock()
+
+	w := NewWaiter(time.Minute, func() {})
+	w.clock = clck
+
+	w.Lock()
+	w.Update()
+	w.Unlock()
+
+	tc := []struct {
+		add      time.Duration
+		outdated bool
+	}{
+		{0, false},
+		{time.Minute, false},
+		{time.Second, true},
+	}
+
+	for _, tc := range tc {
+		clck.Add(tc.add)
+
+		elapsed := w.LockWithTimeout()
+		w.Unlock()
+
+		if outdated := elapsed > 0; outdated != tc.outdated {
+			t.Errorf("%v: expected outdated %v, got %v", tc.add, tc.outdated, elapsed)
+		}
+	}
+}
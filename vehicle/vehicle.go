@@ -48,11 +48,12 @@ func init() {
 // NewConfigurableFromConfig creates a new Vehicle
 func NewConfigurableFromConfig(other map[string]interface{}) (api.Vehicle, error) {
 	cc := struct {
-		embed  `mapstructure:",squash"`
-		Charge provider.Config
-		Status *provider.Config
-		Range  *provider.Config
-		Cache  time.Duration
+		embed   `mapstructure:",squash"`
+		Charge  provider.Config
+		Status  *provider.Config
+		Range   *provider.Config
+		Cache   time.Duration
+		Timeout time.Duration // keep last charge value on errors until timeout
 	}{
 		Cache: interval,
 	}
@@ -73,7 +74,7 @@ func NewConfigurableFromConfig(other map[string]interface{}) (api.Vehicle, error
 	}
 
 	if cc.Cache > 0 {
-		getter = provider.NewCached(getter, cc.Cache).FloatGetter()
+		getter = provider.NewCached(getter, cc.Cache).WithTimeout(cc.Timeout).FloatGetter()
 	}
 
 	v := &Vehicle{